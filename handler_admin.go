@@ -0,0 +1,281 @@
+package main
+
+import (
+    "crypto/rand"
+    "database/sql"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "log/slog"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+)
+
+// Registration modes, set with REGISTRATION_MODE. Whatever the mode, the
+// first user can always register and becomes an admin.
+const (
+    registrationOpen   = "open"
+    registrationInvite = "invite"
+    registrationClosed = "closed"
+)
+
+const (
+    defaultAdminUserLimit = 50
+    maxAdminUserLimit     = 500
+)
+
+// AdminUser is a user as admins see them.
+type AdminUser struct {
+    ID         uuid.UUID  `json:"id"`
+    CreatedAt  time.Time  `json:"created_at"`
+    UpdatedAt  time.Time  `json:"updated_at"`
+    Name       string     `json:"name"`
+    Username   *string    `json:"username"`
+    IsAdmin    bool       `json:"is_admin"`
+    DisabledAt *time.Time `json:"disabled_at"`
+}
+
+type Invite struct {
+    ID        uuid.UUID  `json:"id"`
+    CreatedAt time.Time  `json:"created_at"`
+    CreatedBy *uuid.UUID `json:"created_by"`
+    Code      string     `json:"code"`
+    Note      string     `json:"note"`
+    MaxUses   int32      `json:"max_uses"`
+    Uses      int32      `json:"uses"`
+    ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (cfg *apiConfig) handleAdminGetUsers(w http.ResponseWriter, r *http.Request, admin database.User) {
+    limit, offset, err := parsePagination(r, defaultAdminUserLimit, maxAdminUserLimit)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    users, err := cfg.DB.GetUsers(r.Context(), database.GetUsersParams{
+        RowOffset: offset,
+        RowLimit:  limit,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get users")
+        return
+    }
+
+    out := make([]AdminUser, 0, len(users))
+    for _, u := range users {
+        out = append(out, databaseUserToAdminUser(u))
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+// handleAdminSetUserDisabled disables (disabled=true) or re-enables a user.
+// A disabled user's keys and sessions stop working, and their sessions
+// are ended.
+func (cfg *apiConfig) handleAdminSetUserDisabled(disabled bool) authedHandler {
+    return func(w http.ResponseWriter, r *http.Request, admin database.User) {
+        id, ok := adminTargetUserID(w, r, admin)
+        if !ok {
+            return
+        }
+
+        now := time.Now().UTC()
+        var user database.User
+        err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+            if disabled {
+                if err := checkNotLastAdmin(r.Context(), q, id); err != nil {
+                    return err
+                }
+            }
+            var err error
+            user, err = q.SetUserDisabled(r.Context(), database.SetUserDisabledParams{
+                DisabledAt: sql.NullTime{Time: now, Valid: disabled},
+                UpdatedAt:  now,
+                ID:         id,
+            })
+            return err
+        })
+        if errors.Is(err, errLastAdmin) {
+            httputil.RespondWithError(w, http.StatusConflict, "can't disable the only admin")
+            return
+        }
+        if err != nil {
+            httputil.RespondWithError(w, http.StatusNotFound, "user not found")
+            return
+        }
+
+        if disabled {
+            if err := cfg.DB.DeleteSessionsForUser(r.Context(), user.ID); err != nil {
+                slog.ErrorContext(r.Context(), "deleting sessions of disabled user failed", "target_user_id", user.ID, logging.Err(err))
+            }
+        }
+
+        httputil.RespondWithJSON(w, http.StatusOK, databaseUserToAdminUser(user))
+    }
+}
+
+func (cfg *apiConfig) handleAdminDeleteUser(w http.ResponseWriter, r *http.Request, admin database.User) {
+    id, ok := adminTargetUserID(w, r, admin)
+    if !ok {
+        return
+    }
+
+    err := cfg.deleteUser(r.Context(), id)
+    if errors.Is(err, sql.ErrNoRows) {
+        httputil.RespondWithError(w, http.StatusNotFound, "user not found")
+        return
+    }
+    if errors.Is(err, errLastAdmin) {
+        httputil.RespondWithError(w, http.StatusConflict, "can't delete the only admin")
+        return
+    }
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not delete user")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// adminTargetUserID reads the user an admin action applies to. Admins
+// can't use these endpoints on themselves, so they can't lock everyone out.
+func adminTargetUserID(w http.ResponseWriter, r *http.Request, admin database.User) (uuid.UUID, bool) {
+    id, err := uuid.Parse(chi.URLParam(r, "userID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid userID")
+        return uuid.Nil, false
+    }
+    if id == admin.ID {
+        httputil.RespondWithError(w, http.StatusBadRequest, "admins can't disable or delete themselves")
+        return uuid.Nil, false
+    }
+    return id, true
+}
+
+// handleAdminCreateInvite issues an invite code, single-use unless max_uses
+// says otherwise.
+func (cfg *apiConfig) handleAdminCreateInvite(w http.ResponseWriter, r *http.Request, admin database.User) {
+    type requestBody struct {
+        Note      string     `json:"note"`
+        MaxUses   *int32     `json:"max_uses"`
+        ExpiresAt *time.Time `json:"expires_at"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    maxUses := int32(1)
+    if params.MaxUses != nil {
+        if *params.MaxUses < 1 {
+            httputil.RespondWithError(w, http.StatusBadRequest, "max_uses must be at least 1")
+            return
+        }
+        maxUses = *params.MaxUses
+    }
+
+    now := time.Now().UTC()
+
+    var expiresAt sql.NullTime
+    if params.ExpiresAt != nil {
+        if !params.ExpiresAt.After(now) {
+            httputil.RespondWithError(w, http.StatusBadRequest, "expires_at must be in the future")
+            return
+        }
+        expiresAt = sql.NullTime{Time: params.ExpiresAt.UTC(), Valid: true}
+    }
+
+    code := make([]byte, 12)
+    if _, err := rand.Read(code); err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create invite")
+        return
+    }
+
+    invite, err := cfg.DB.CreateInvite(r.Context(), database.CreateInviteParams{
+        ID:        uuid.New(),
+        CreatedAt: now,
+        CreatedBy: uuid.NullUUID{UUID: admin.ID, Valid: true},
+        Code:      hex.EncodeToString(code),
+        Note:      strings.TrimSpace(params.Note),
+        MaxUses:   maxUses,
+        ExpiresAt: expiresAt,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create invite")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusCreated, databaseInviteToInvite(invite))
+}
+
+func (cfg *apiConfig) handleAdminGetInvites(w http.ResponseWriter, r *http.Request, admin database.User) {
+    invites, err := cfg.DB.GetInvites(r.Context())
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get invites")
+        return
+    }
+
+    out := make([]Invite, 0, len(invites))
+    for _, i := range invites {
+        out = append(out, databaseInviteToInvite(i))
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handleAdminDeleteInvite(w http.ResponseWriter, r *http.Request, admin database.User) {
+    id, err := uuid.Parse(chi.URLParam(r, "inviteID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid inviteID")
+        return
+    }
+
+    n, err := cfg.DB.DeleteInvite(r.Context(), id)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not delete invite")
+        return
+    }
+    if n == 0 {
+        httputil.RespondWithError(w, http.StatusNotFound, "invite not found")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func databaseUserToAdminUser(u database.User) AdminUser {
+    return AdminUser{
+        ID:         u.ID,
+        CreatedAt:  u.CreatedAt,
+        UpdatedAt:  u.UpdatedAt,
+        Name:       u.Name,
+        Username:   nullStringPtr(u.Username),
+        IsAdmin:    u.IsAdmin,
+        DisabledAt: nullTimePtr(u.DisabledAt),
+    }
+}
+
+func databaseInviteToInvite(i database.Invite) Invite {
+    return Invite{
+        ID:        i.ID,
+        CreatedAt: i.CreatedAt,
+        CreatedBy: nullUUIDPtr(i.CreatedBy),
+        Code:      i.Code,
+        Note:      i.Note,
+        MaxUses:   i.MaxUses,
+        Uses:      i.Uses,
+        ExpiresAt: nullTimePtr(i.ExpiresAt),
+    }
+}
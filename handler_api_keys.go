@@ -0,0 +1,155 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/auth"
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+)
+
+// APIKey describes one of a user's keys. Key is only set in the response
+// that creates it; afterwards only the prefix identifies it.
+type APIKey struct {
+    ID         uuid.UUID  `json:"id"`
+    CreatedAt  time.Time  `json:"created_at"`
+    Name       string     `json:"name"`
+    Prefix     string     `json:"prefix"`
+    Scopes     []string   `json:"scopes"`
+    Key        string     `json:"key,omitempty"`
+    LastUsedAt *time.Time `json:"last_used_at"`
+    ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+func (cfg *apiConfig) handleCreateAPIKey(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        Name      string     `json:"name"`
+        Scopes    []string   `json:"scopes"`
+        ExpiresAt *time.Time `json:"expires_at"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    params.Name = strings.TrimSpace(params.Name)
+    if params.Name == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "name is required")
+        return
+    }
+
+    // Without scopes the key has full access, like keys made before scopes
+    // existed.
+    if params.Scopes == nil {
+        params.Scopes = []string{auth.ScopeAccount}
+    }
+    if len(params.Scopes) == 0 {
+        httputil.RespondWithError(w, http.StatusBadRequest, "scopes must not be empty")
+        return
+    }
+    for _, scope := range params.Scopes {
+        if !auth.ValidScope(scope) {
+            httputil.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("unknown scope %q: must be one of %s", scope, strings.Join(auth.AllScopes, ", ")))
+            return
+        }
+    }
+
+    now := time.Now().UTC()
+
+    var expiresAt sql.NullTime
+    if params.ExpiresAt != nil {
+        if !params.ExpiresAt.After(now) {
+            httputil.RespondWithError(w, http.StatusBadRequest, "expires_at must be in the future")
+            return
+        }
+        expiresAt = sql.NullTime{Time: params.ExpiresAt.UTC(), Valid: true}
+    }
+
+    key, prefix, hash, err := auth.NewAPIKey()
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create api key")
+        return
+    }
+
+    apiKey, err := cfg.DB.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+        ID:        uuid.New(),
+        CreatedAt: now,
+        UserID:    user.ID,
+        Name:      params.Name,
+        Prefix:    prefix,
+        KeyHash:   hash,
+        Scopes:    params.Scopes,
+        ExpiresAt: expiresAt,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create api key")
+        return
+    }
+
+    out := databaseAPIKeyToAPIKey(apiKey)
+    out.Key = key
+    httputil.RespondWithJSON(w, http.StatusCreated, out)
+}
+
+func (cfg *apiConfig) handleGetAPIKeys(w http.ResponseWriter, r *http.Request, user database.User) {
+    keys, err := cfg.DB.GetAPIKeysForUser(r.Context(), user.ID)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get api keys")
+        return
+    }
+
+    out := make([]APIKey, 0, len(keys))
+    for _, k := range keys {
+        out = append(out, databaseAPIKeyToAPIKey(k))
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+// handleDeleteAPIKey revokes a key. It stops working immediately, including
+// if it is the key making this request.
+func (cfg *apiConfig) handleDeleteAPIKey(w http.ResponseWriter, r *http.Request, user database.User) {
+    id, err := uuid.Parse(chi.URLParam(r, "apiKeyID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid apiKeyID")
+        return
+    }
+
+    n, err := cfg.DB.DeleteAPIKey(r.Context(), database.DeleteAPIKeyParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not revoke api key")
+        return
+    }
+    if n == 0 {
+        httputil.RespondWithError(w, http.StatusNotFound, "api key not found")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func databaseAPIKeyToAPIKey(k database.ApiKey) APIKey {
+    return APIKey{
+        ID:         k.ID,
+        CreatedAt:  k.CreatedAt,
+        Name:       k.Name,
+        Prefix:     k.Prefix,
+        Scopes:     k.Scopes,
+        LastUsedAt: nullTimePtr(k.LastUsedAt),
+        ExpiresAt:  nullTimePtr(k.ExpiresAt),
+    }
+}
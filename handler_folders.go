@@ -0,0 +1,213 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+    "github.com/lib/pq"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+)
+
+type Folder struct {
+    ID        uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Name      string    `json:"name"`
+}
+
+func (cfg *apiConfig) handleCreateFolder(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        Name string `json:"name"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    params.Name = strings.TrimSpace(params.Name)
+    if params.Name == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "name is required")
+        return
+    }
+
+    now := time.Now().UTC()
+    folder, err := cfg.DB.CreateFolder(r.Context(), database.CreateFolderParams{
+        ID:        uuid.New(),
+        CreatedAt: now,
+        UpdatedAt: now,
+        UserID:    user.ID,
+        Name:      params.Name,
+    })
+    if err != nil {
+        if isUniqueViolation(err) {
+            httputil.RespondWithError(w, http.StatusConflict, "a folder with that name already exists")
+            return
+        }
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create folder")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusCreated, databaseFolderToFolder(folder))
+}
+
+func (cfg *apiConfig) handleGetFolders(w http.ResponseWriter, r *http.Request, user database.User) {
+    folders, err := cfg.DB.GetFoldersForUser(r.Context(), user.ID)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get folders")
+        return
+    }
+
+    out := make([]Folder, 0, len(folders))
+    for _, f := range folders {
+        out = append(out, databaseFolderToFolder(f))
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handleRenameFolder(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        Name string `json:"name"`
+    }
+
+    id, err := uuid.Parse(chi.URLParam(r, "folderID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid folderID")
+        return
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    params.Name = strings.TrimSpace(params.Name)
+    if params.Name == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "name is required")
+        return
+    }
+
+    folder, err := cfg.DB.RenameFolder(r.Context(), database.RenameFolderParams{
+        ID:        id,
+        UserID:    user.ID,
+        Name:      params.Name,
+        UpdatedAt: time.Now().UTC(),
+    })
+    if err != nil {
+        if isUniqueViolation(err) {
+            httputil.RespondWithError(w, http.StatusConflict, "a folder with that name already exists")
+            return
+        }
+        httputil.RespondWithError(w, http.StatusNotFound, "folder not found")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, databaseFolderToFolder(folder))
+}
+
+// handleDeleteFolder deletes a folder. Follows in it are kept and simply
+// become unfiled.
+func (cfg *apiConfig) handleDeleteFolder(w http.ResponseWriter, r *http.Request, user database.User) {
+    id, err := uuid.Parse(chi.URLParam(r, "folderID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid folderID")
+        return
+    }
+
+    n, err := cfg.DB.DeleteFolder(r.Context(), database.DeleteFolderParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not delete folder")
+        return
+    }
+    if n == 0 {
+        httputil.RespondWithError(w, http.StatusNotFound, "folder not found")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleSetFeedFollowFolder moves a follow into a folder, or out of any
+// folder when folder_id is null.
+func (cfg *apiConfig) handleSetFeedFollowFolder(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        FolderID *uuid.UUID `json:"folder_id"`
+    }
+
+    followID, err := uuid.Parse(chi.URLParam(r, "feedFollowID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid feedFollowID")
+        return
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    var folderID uuid.NullUUID
+    if params.FolderID != nil {
+        folder, err := cfg.DB.GetFolder(r.Context(), database.GetFolderParams{
+            ID:     *params.FolderID,
+            UserID: user.ID,
+        })
+        if err != nil {
+            httputil.RespondWithError(w, http.StatusNotFound, "folder not found")
+            return
+        }
+        folderID = uuid.NullUUID{UUID: folder.ID, Valid: true}
+    }
+
+    follow, err := cfg.DB.SetFeedFollowFolder(r.Context(), database.SetFeedFollowFolderParams{
+        ID:        followID,
+        UserID:    user.ID,
+        FolderID:  folderID,
+        UpdatedAt: time.Now().UTC(),
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusNotFound, "feed follow not found")
+        return
+    }
+
+    cfg.respondWithFeedFollow(w, r, http.StatusOK, follow)
+}
+
+func databaseFolderToFolder(f database.Folder) Folder {
+    return Folder{
+        ID:        f.ID,
+        CreatedAt: f.CreatedAt,
+        UpdatedAt: f.UpdatedAt,
+        Name:      f.Name,
+    }
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint
+// violation.
+func isUniqueViolation(err error) bool {
+    var pqErr *pq.Error
+    return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign key
+// violation, such as a reference to a row that doesn't exist.
+func isForeignKeyViolation(err error) bool {
+    var pqErr *pq.Error
+    return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
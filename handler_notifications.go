@@ -0,0 +1,87 @@
+package main
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+)
+
+type Notification struct {
+    ID              uuid.UUID  `json:"id"`
+    CreatedAt       time.Time  `json:"created_at"`
+    PostID          uuid.UUID  `json:"post_id"`
+    PostTitle       string     `json:"post_title"`
+    PostURL         string     `json:"post_url"`
+    FeedID          uuid.UUID  `json:"feed_id"`
+    FeedTitle       string     `json:"feed_title"`
+    SavedSearchID   *uuid.UUID `json:"saved_search_id"`
+    SavedSearchName *string    `json:"saved_search_name"`
+    ReadAt          *time.Time `json:"read_at"`
+}
+
+// handleGetNotifications lists the user's notifications, newest first.
+// Pass unread=true for unread notifications only.
+func (cfg *apiConfig) handleGetNotifications(w http.ResponseWriter, r *http.Request, user database.User) {
+    limit, offset, err := parsePagination(r, defaultTimelineLimit, maxTimelineLimit)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    rows, err := cfg.DB.GetNotificationsForUser(r.Context(), database.GetNotificationsForUserParams{
+        UserID:     user.ID,
+        UnreadOnly: r.URL.Query().Get("unread") == "true",
+        RowLimit:   limit,
+        RowOffset:  offset,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get notifications")
+        return
+    }
+
+    out := make([]Notification, 0, len(rows))
+    for _, row := range rows {
+        out = append(out, Notification{
+            ID:              row.ID,
+            CreatedAt:       row.CreatedAt,
+            PostID:          row.PostID,
+            PostTitle:       row.PostTitle,
+            PostURL:         row.PostUrl,
+            FeedID:          row.FeedID,
+            FeedTitle:       row.FeedTitle,
+            SavedSearchID:   nullUUIDPtr(row.SavedSearchID),
+            SavedSearchName: nullStringPtr(row.SavedSearchName),
+            ReadAt:          nullTimePtr(row.ReadAt),
+        })
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handleMarkNotificationRead(w http.ResponseWriter, r *http.Request, user database.User) {
+    id, err := uuid.Parse(chi.URLParam(r, "notificationID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid notificationID")
+        return
+    }
+
+    n, err := cfg.DB.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not update notification")
+        return
+    }
+    if n == 0 {
+        httputil.RespondWithError(w, http.StatusNotFound, "notification not found")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "read"})
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+    "github.com/mdbailin/go-rss-server/internal/publish"
+)
+
+// Sources an output feed can republish.
+const (
+    outputSourceTimeline    = "timeline"
+    outputSourceFolder      = "folder"
+    outputSourceSavedSearch = "saved_search"
+    outputSourceStarred     = "starred"
+)
+
+// Formats an output feed is served in, by file extension.
+const (
+    outputFormatAtom = "atom"
+    outputFormatRSS  = "rss"
+    outputFormatJSON = "json"
+)
+
+// outputFeedLimit is how many of the newest posts an output feed holds.
+const outputFeedLimit = 50
+
+// OutputFeed is a token-protected Atom/RSS/JSON Feed rendition of some of
+// a user's posts. Anyone with the URLs can read it.
+type OutputFeed struct {
+    ID            uuid.UUID         `json:"id"`
+    CreatedAt     time.Time         `json:"created_at"`
+    Title         string            `json:"title"`
+    Source        string            `json:"source"`
+    FolderID      *uuid.UUID        `json:"folder_id"`
+    SavedSearchID *uuid.UUID        `json:"saved_search_id"`
+    URLs          map[string]string `json:"urls"`
+}
+
+// handleCreateOutputFeed publishes a timeline, folder, saved search or the
+// user's starred posts under a new secret URL.
+func (cfg *apiConfig) handleCreateOutputFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        Title         string     `json:"title"`
+        Source        string     `json:"source"`
+        FolderID      *uuid.UUID `json:"folder_id"`
+        SavedSearchID *uuid.UUID `json:"saved_search_id"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    title := strings.TrimSpace(params.Title)
+    var folderID, savedSearchID uuid.NullUUID
+
+    switch params.Source {
+    case outputSourceTimeline:
+        if title == "" {
+            title = user.Name + "'s timeline"
+        }
+    case outputSourceStarred:
+        if title == "" {
+            title = user.Name + "'s starred posts"
+        }
+    case outputSourceFolder:
+        if params.FolderID == nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "folder_id is required")
+            return
+        }
+        folder, err := cfg.DB.GetFolder(r.Context(), database.GetFolderParams{
+            ID:     *params.FolderID,
+            UserID: user.ID,
+        })
+        if err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "folder not found")
+            return
+        }
+        folderID = uuid.NullUUID{UUID: folder.ID, Valid: true}
+        if title == "" {
+            title = folder.Name
+        }
+    case outputSourceSavedSearch:
+        if params.SavedSearchID == nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "saved_search_id is required")
+            return
+        }
+        search, err := cfg.DB.GetSavedSearch(r.Context(), database.GetSavedSearchParams{
+            ID:     *params.SavedSearchID,
+            UserID: user.ID,
+        })
+        if err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "saved search not found")
+            return
+        }
+        savedSearchID = uuid.NullUUID{UUID: search.ID, Valid: true}
+        if title == "" {
+            title = search.Name
+        }
+    default:
+        httputil.RespondWithError(w, http.StatusBadRequest, "source must be timeline, folder, saved_search or starred")
+        return
+    }
+
+    token := make([]byte, 24)
+    if _, err := rand.Read(token); err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create output feed")
+        return
+    }
+
+    now := time.Now().UTC()
+    feed, err := cfg.DB.CreateOutputFeed(r.Context(), database.CreateOutputFeedParams{
+        ID:            uuid.New(),
+        CreatedAt:     now,
+        UpdatedAt:     now,
+        UserID:        user.ID,
+        Token:         hex.EncodeToString(token),
+        Title:         title,
+        Source:        params.Source,
+        FolderID:      folderID,
+        SavedSearchID: savedSearchID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create output feed")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusCreated, databaseOutputFeedToOutputFeed(r, feed))
+}
+
+func (cfg *apiConfig) handleGetOutputFeeds(w http.ResponseWriter, r *http.Request, user database.User) {
+    feeds, err := cfg.DB.GetOutputFeedsForUser(r.Context(), user.ID)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get output feeds")
+        return
+    }
+
+    out := make([]OutputFeed, 0, len(feeds))
+    for _, f := range feeds {
+        out = append(out, databaseOutputFeedToOutputFeed(r, f))
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+// handleDeleteOutputFeed unpublishes an output feed; its URLs stop working.
+func (cfg *apiConfig) handleDeleteOutputFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+    id, err := uuid.Parse(chi.URLParam(r, "outputFeedID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid outputFeedID")
+        return
+    }
+
+    n, err := cfg.DB.DeleteOutputFeed(r.Context(), database.DeleteOutputFeedParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not delete output feed")
+        return
+    }
+    if n == 0 {
+        httputil.RespondWithError(w, http.StatusNotFound, "output feed not found")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleServeOutputFeed returns a handler serving the output feed named by
+// the token in the URL in one format. Responses carry an ETag and
+// Last-Modified, and conditional requests get 304 Not Modified.
+func (cfg *apiConfig) handleServeOutputFeed(format string) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        out, err := cfg.DB.GetOutputFeedByToken(r.Context(), chi.URLParam(r, "token"))
+        if err != nil {
+            http.NotFound(w, r)
+            return
+        }
+
+        user, err := cfg.DB.GetUser(r.Context(), out.UserID)
+        if err != nil {
+            http.NotFound(w, r)
+            return
+        }
+
+        items, lastModified, err := cfg.outputFeedItems(r, out)
+        if err != nil {
+            http.Error(w, "could not load feed", http.StatusInternalServerError)
+            return
+        }
+        if lastModified.IsZero() {
+            lastModified = out.UpdatedAt
+        }
+
+        feed := publish.Feed{
+            ID:      "urn:uuid:" + out.ID.String(),
+            Title:   out.Title,
+            SelfURL: outputFeedURL(r, out.Token, format),
+            Author:  user.Name,
+            Updated: lastModified,
+            Items:   items,
+        }
+
+        var body []byte
+        var contentType string
+        switch format {
+        case outputFormatAtom:
+            body, err = publish.Atom(feed)
+            contentType = publish.ContentTypeAtom
+        case outputFormatRSS:
+            body, err = publish.RSS(feed)
+            contentType = publish.ContentTypeRSS
+        default:
+            body, err = publish.JSON(feed)
+            contentType = publish.ContentTypeJSON
+        }
+        if err != nil {
+            http.Error(w, "could not render feed", http.StatusInternalServerError)
+            return
+        }
+
+        sum := sha256.Sum256(body)
+        etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+        w.Header().Set("ETag", etag)
+        w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+        w.Header().Set("Cache-Control", "private, max-age=300")
+        w.Header().Set("X-Content-Type-Options", "nosniff")
+
+        if match := r.Header.Get("If-None-Match"); match != "" {
+            if strings.Contains(match, etag) || strings.TrimSpace(match) == "*" {
+                w.WriteHeader(http.StatusNotModified)
+                return
+            }
+        } else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+            if !lastModified.Truncate(time.Second).After(since) {
+                w.WriteHeader(http.StatusNotModified)
+                return
+            }
+        }
+
+        w.Header().Set("Content-Type", contentType)
+        w.WriteHeader(http.StatusOK)
+        w.Write(body)
+    }
+}
+
+// outputFeedItems loads the newest posts of an output feed's source and
+// the time the newest of them entered it.
+func (cfg *apiConfig) outputFeedItems(r *http.Request, out database.OutputFeed) ([]publish.Item, time.Time, error) {
+    var items []publish.Item
+    var lastModified time.Time
+
+    add := func(p database.Post, addedAt time.Time) {
+        sanitizePost(&p)
+        items = append(items, publish.Item{
+            ID:          "urn:uuid:" + p.ID.String(),
+            Title:       p.Title,
+            URL:         p.Url,
+            Summary:     p.Description,
+            ContentHTML: p.Content,
+            Author:      p.Author,
+            Categories:  p.Categories,
+            Published:   p.PublishedAt,
+            Updated:     p.UpdatedAt,
+        })
+        if addedAt.After(lastModified) {
+            lastModified = addedAt
+        }
+    }
+
+    if out.Source == outputSourceSavedSearch {
+        rows, err := cfg.DB.GetSavedSearchPosts(r.Context(), database.GetSavedSearchPostsParams{
+            SavedSearchID: out.SavedSearchID.UUID,
+            RowLimit:      outputFeedLimit,
+        })
+        if err != nil {
+            return nil, time.Time{}, err
+        }
+        for _, row := range rows {
+            add(database.Post{
+                ID:          row.ID,
+                CreatedAt:   row.CreatedAt,
+                UpdatedAt:   row.UpdatedAt,
+                Title:       row.Title,
+                Url:         row.Url,
+                Description: row.Description,
+                PublishedAt: row.PublishedAt,
+                FeedID:      row.FeedID,
+                Content:     row.Content,
+                Author:      row.Author,
+                Categories:  row.Categories,
+            }, row.CreatedAt)
+        }
+        return items, lastModified, nil
+    }
+
+    rows, err := cfg.DB.GetTimelineForUser(r.Context(), database.GetTimelineForUserParams{
+        UserID:      out.UserID,
+        StarredOnly: out.Source == outputSourceStarred,
+        FolderID:    out.FolderID,
+        RowLimit:    outputFeedLimit,
+    })
+    if err != nil {
+        return nil, time.Time{}, err
+    }
+    for _, row := range rows {
+        addedAt := row.CreatedAt
+        if out.Source == outputSourceStarred {
+            addedAt = row.StarredAt.Time
+        }
+        add(database.Post{
+            ID:          row.ID,
+            CreatedAt:   row.CreatedAt,
+            UpdatedAt:   row.UpdatedAt,
+            Title:       row.Title,
+            Url:         row.Url,
+            Description: row.Description,
+            PublishedAt: row.PublishedAt,
+            FeedID:      row.FeedID,
+            Content:     row.Content,
+            Author:      row.Author,
+            Categories:  row.Categories,
+        }, addedAt)
+    }
+    return items, lastModified, nil
+}
+
+func databaseOutputFeedToOutputFeed(r *http.Request, f database.OutputFeed) OutputFeed {
+    return OutputFeed{
+        ID:            f.ID,
+        CreatedAt:     f.CreatedAt,
+        Title:         f.Title,
+        Source:        f.Source,
+        FolderID:      nullUUIDPtr(f.FolderID),
+        SavedSearchID: nullUUIDPtr(f.SavedSearchID),
+        URLs: map[string]string{
+            outputFormatAtom: outputFeedURL(r, f.Token, outputFormatAtom),
+            outputFormatRSS:  outputFeedURL(r, f.Token, outputFormatRSS),
+            outputFormatJSON: outputFeedURL(r, f.Token, outputFormatJSON),
+        },
+    }
+}
+
+// outputFeedURL builds the public URL of an output feed from the request,
+// honouring X-Forwarded-Proto from a TLS-terminating proxy.
+func outputFeedURL(r *http.Request, token, format string) string {
+    scheme := "http"
+    if requestIsHTTPS(r) {
+        scheme = "https"
+    }
+    u := url.URL{Scheme: scheme, Host: r.Host, Path: "/u/" + token + "/feed." + format}
+    return u.String()
+}
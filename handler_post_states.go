@@ -0,0 +1,99 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "net/http"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+    "github.com/mdbailin/go-rss-server/internal/stream"
+)
+
+// Post state actions, used as the status in responses.
+const (
+    postStateRead      = "read"
+    postStateUnread    = "unread"
+    postStateStarred   = "starred"
+    postStateUnstarred = "unstarred"
+    postStateHidden    = "hidden"
+    postStateUnhidden  = "unhidden"
+)
+
+// handlePostState returns a handler that applies one read/star/hide action
+// to the post named in the URL for the authenticated user.
+func (cfg *apiConfig) handlePostState(action string) authedHandler {
+    return func(w http.ResponseWriter, r *http.Request, user database.User) {
+        postID, err := uuid.Parse(chi.URLParam(r, "postID"))
+        if err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "invalid postID")
+            return
+        }
+
+        if _, err := cfg.DB.GetPost(r.Context(), postID); err != nil {
+            httputil.RespondWithError(w, http.StatusNotFound, "post not found")
+            return
+        }
+
+        if err := cfg.setPostState(r.Context(), user.ID, postID, action); err != nil {
+            httputil.RespondWithError(w, http.StatusInternalServerError, "could not update post state")
+            return
+        }
+
+        httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": action})
+    }
+}
+
+// setPostState applies a post state action and tells the user's other
+// live connections about it.
+func (cfg *apiConfig) setPostState(ctx context.Context, userID, postID uuid.UUID, action string) error {
+    now := time.Now().UTC()
+
+    var err error
+    switch action {
+    case postStateRead:
+        err = cfg.DB.MarkPostRead(ctx, database.MarkPostReadParams{
+            UserID: userID, PostID: postID, Now: now,
+        })
+    case postStateUnread:
+        err = cfg.DB.MarkPostUnread(ctx, database.MarkPostUnreadParams{
+            UserID: userID, PostID: postID, Now: now,
+        })
+    case postStateStarred:
+        err = cfg.DB.StarPost(ctx, database.StarPostParams{
+            UserID: userID, PostID: postID, Now: now,
+        })
+    case postStateUnstarred:
+        err = cfg.DB.UnstarPost(ctx, database.UnstarPostParams{
+            UserID: userID, PostID: postID, Now: now,
+        })
+    case postStateHidden:
+        err = cfg.DB.HidePost(ctx, database.HidePostParams{
+            UserID: userID, PostID: postID, Now: now,
+        })
+    case postStateUnhidden:
+        err = cfg.DB.UnhidePost(ctx, database.UnhidePostParams{
+            UserID: userID, PostID: postID, Now: now,
+        })
+    default:
+        err = fmt.Errorf("unknown post state action %q", action)
+    }
+    if err != nil {
+        return err
+    }
+
+    if err := stream.Publish(ctx, cfg.DB, stream.Event{
+        Type:   stream.EventPostState,
+        PostID: postID,
+        UserID: userID,
+    }); err != nil {
+        slog.ErrorContext(ctx, "publishing post state failed", "post_id", postID, logging.Err(err))
+    }
+    return nil
+}
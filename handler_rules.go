@@ -0,0 +1,391 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+    "github.com/mdbailin/go-rss-server/internal/rules"
+)
+
+const (
+    // dryRunScanLimit is how many recent posts a dry run checks.
+    dryRunScanLimit = 200
+    // applyScanLimit is how many recent posts applying a rule retroactively
+    // checks.
+    applyScanLimit = 1000
+)
+
+// FilterRule matches new posts and hides, marks read, stars or tags them.
+// A rule covers all followed feeds unless it is scoped to a folder or to a
+// single feed follow.
+type FilterRule struct {
+    ID           uuid.UUID  `json:"id"`
+    CreatedAt    time.Time  `json:"created_at"`
+    UpdatedAt    time.Time  `json:"updated_at"`
+    Name         string     `json:"name"`
+    FolderID     *uuid.UUID `json:"folder_id"`
+    FeedFollowID *uuid.UUID `json:"feed_follow_id"`
+    Field        string     `json:"field"`
+    MatchType    string     `json:"match_type"`
+    Pattern      string     `json:"pattern"`
+    Action       string     `json:"action"`
+    Tag          string     `json:"tag,omitempty"`
+    Enabled      bool       `json:"enabled"`
+}
+
+// RuleMatch is a post a rule matched in a dry run.
+type RuleMatch struct {
+    ID          uuid.UUID `json:"id"`
+    Title       string    `json:"title"`
+    URL         string    `json:"url"`
+    PublishedAt time.Time `json:"published_at"`
+    FeedID      uuid.UUID `json:"feed_id"`
+}
+
+// ruleRequest is the body accepted when creating, replacing or dry-running
+// a rule. field defaults to "any" and match_type to "keyword".
+type ruleRequest struct {
+    Name         string     `json:"name"`
+    FolderID     *uuid.UUID `json:"folder_id"`
+    FeedFollowID *uuid.UUID `json:"feed_follow_id"`
+    Field        string     `json:"field"`
+    MatchType    string     `json:"match_type"`
+    Pattern      string     `json:"pattern"`
+    Action       string     `json:"action"`
+    Tag          string     `json:"tag"`
+    Enabled      *bool      `json:"enabled"`
+}
+
+// validRule is a checked ruleRequest, ready to store or run.
+type validRule struct {
+    name         string
+    folderID     uuid.NullUUID
+    feedFollowID uuid.NullUUID
+    field        string
+    matchType    string
+    pattern      string
+    action       string
+    tag          string
+    enabled      bool
+    matcher      *rules.Matcher
+}
+
+// parseRuleRequest decodes and validates a rule body, checking that any
+// folder or feed follow it is scoped to belongs to the user. On failure it
+// has already responded.
+func (cfg *apiConfig) parseRuleRequest(w http.ResponseWriter, r *http.Request, user database.User) (validRule, bool) {
+    decoder := json.NewDecoder(r.Body)
+    params := ruleRequest{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return validRule{}, false
+    }
+
+    v := validRule{
+        name:      strings.TrimSpace(params.Name),
+        field:     params.Field,
+        matchType: params.MatchType,
+        pattern:   params.Pattern,
+        action:    params.Action,
+        tag:       strings.TrimSpace(params.Tag),
+        enabled:   params.Enabled == nil || *params.Enabled,
+    }
+    if v.field == "" {
+        v.field = rules.FieldAny
+    }
+    if v.matchType == "" {
+        v.matchType = rules.MatchKeyword
+    }
+    if v.action != rules.ActionTag {
+        v.tag = ""
+    }
+
+    var err error
+    if v.matcher, err = rules.Compile(v.field, v.matchType, v.pattern); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return validRule{}, false
+    }
+    if err := rules.ValidateAction(v.action, v.tag); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return validRule{}, false
+    }
+
+    if params.FolderID != nil && params.FeedFollowID != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "set at most one of folder_id and feed_follow_id")
+        return validRule{}, false
+    }
+    if params.FolderID != nil {
+        if _, err := cfg.DB.GetFolder(r.Context(), database.GetFolderParams{
+            ID:     *params.FolderID,
+            UserID: user.ID,
+        }); err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "folder not found")
+            return validRule{}, false
+        }
+        v.folderID = uuid.NullUUID{UUID: *params.FolderID, Valid: true}
+    }
+    if params.FeedFollowID != nil {
+        if _, err := cfg.DB.GetFeedFollow(r.Context(), database.GetFeedFollowParams{
+            ID:     *params.FeedFollowID,
+            UserID: user.ID,
+        }); err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "feed follow not found")
+            return validRule{}, false
+        }
+        v.feedFollowID = uuid.NullUUID{UUID: *params.FeedFollowID, Valid: true}
+    }
+
+    return v, true
+}
+
+func (cfg *apiConfig) handleCreateRule(w http.ResponseWriter, r *http.Request, user database.User) {
+    v, ok := cfg.parseRuleRequest(w, r, user)
+    if !ok {
+        return
+    }
+
+    now := time.Now().UTC()
+    rule, err := cfg.DB.CreateFilterRule(r.Context(), database.CreateFilterRuleParams{
+        ID:           uuid.New(),
+        CreatedAt:    now,
+        UpdatedAt:    now,
+        UserID:       user.ID,
+        Name:         v.name,
+        FolderID:     v.folderID,
+        FeedFollowID: v.feedFollowID,
+        Field:        v.field,
+        MatchType:    v.matchType,
+        Pattern:      v.pattern,
+        Action:       v.action,
+        Tag:          v.tag,
+        Enabled:      v.enabled,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create rule")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusCreated, databaseRuleToRule(rule))
+}
+
+func (cfg *apiConfig) handleGetRules(w http.ResponseWriter, r *http.Request, user database.User) {
+    rows, err := cfg.DB.GetFilterRulesForUser(r.Context(), user.ID)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get rules")
+        return
+    }
+
+    out := make([]FilterRule, 0, len(rows))
+    for _, rule := range rows {
+        out = append(out, databaseRuleToRule(rule))
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handleGetRule(w http.ResponseWriter, r *http.Request, user database.User) {
+    rule, ok := cfg.ruleFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, databaseRuleToRule(rule))
+}
+
+// handleUpdateRule replaces a rule with the request body.
+func (cfg *apiConfig) handleUpdateRule(w http.ResponseWriter, r *http.Request, user database.User) {
+    rule, ok := cfg.ruleFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    v, ok := cfg.parseRuleRequest(w, r, user)
+    if !ok {
+        return
+    }
+
+    updated, err := cfg.DB.UpdateFilterRule(r.Context(), database.UpdateFilterRuleParams{
+        ID:           rule.ID,
+        UserID:       user.ID,
+        Name:         v.name,
+        FolderID:     v.folderID,
+        FeedFollowID: v.feedFollowID,
+        Field:        v.field,
+        MatchType:    v.matchType,
+        Pattern:      v.pattern,
+        Action:       v.action,
+        Tag:          v.tag,
+        Enabled:      v.enabled,
+        UpdatedAt:    time.Now().UTC(),
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not update rule")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, databaseRuleToRule(updated))
+}
+
+func (cfg *apiConfig) handleDeleteRule(w http.ResponseWriter, r *http.Request, user database.User) {
+    id, err := uuid.Parse(chi.URLParam(r, "ruleID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid ruleID")
+        return
+    }
+
+    n, err := cfg.DB.DeleteFilterRule(r.Context(), database.DeleteFilterRuleParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not delete rule")
+        return
+    }
+    if n == 0 {
+        httputil.RespondWithError(w, http.StatusNotFound, "rule not found")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleDryRunRule reports which of the user's recent posts a rule would
+// match, without storing the rule or changing anything.
+func (cfg *apiConfig) handleDryRunRule(w http.ResponseWriter, r *http.Request, user database.User) {
+    v, ok := cfg.parseRuleRequest(w, r, user)
+    if !ok {
+        return
+    }
+
+    posts, err := cfg.DB.GetRecentPostsInScope(r.Context(), database.GetRecentPostsInScopeParams{
+        UserID:       user.ID,
+        FolderID:     v.folderID,
+        FeedFollowID: v.feedFollowID,
+        RowLimit:     dryRunScanLimit,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get posts")
+        return
+    }
+
+    matches := []RuleMatch{}
+    for _, p := range posts {
+        if !v.matcher.Match(rules.FromPost(p)) {
+            continue
+        }
+        matches = append(matches, RuleMatch{
+            ID:          p.ID,
+            Title:       p.Title,
+            URL:         p.Url,
+            PublishedAt: p.PublishedAt,
+            FeedID:      p.FeedID,
+        })
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]any{
+        "scanned": len(posts),
+        "matched": len(matches),
+        "posts":   matches,
+    })
+}
+
+// handleApplyRule runs a stored rule over the user's recent posts, for
+// posts that arrived before the rule existed.
+func (cfg *apiConfig) handleApplyRule(w http.ResponseWriter, r *http.Request, user database.User) {
+    rule, ok := cfg.ruleFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    matcher, err := rules.Compile(rule.Field, rule.MatchType, rule.Pattern)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
+        return
+    }
+
+    posts, err := cfg.DB.GetRecentPostsInScope(r.Context(), database.GetRecentPostsInScopeParams{
+        UserID:       user.ID,
+        FolderID:     rule.FolderID,
+        FeedFollowID: rule.FeedFollowID,
+        RowLimit:     applyScanLimit,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get posts")
+        return
+    }
+
+    matched := 0
+    err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+        for _, p := range posts {
+            if !matcher.Match(rules.FromPost(p)) {
+                continue
+            }
+            if err := rules.Apply(r.Context(), q, rule, p.ID); err != nil {
+                return err
+            }
+            matched++
+        }
+        return nil
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not apply rule")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]int{
+        "scanned": len(posts),
+        "matched": matched,
+    })
+}
+
+// ruleFromURL loads the user's rule named by the ruleID URL parameter,
+// responding with an error if it can't.
+func (cfg *apiConfig) ruleFromURL(w http.ResponseWriter, r *http.Request, user database.User) (database.FilterRule, bool) {
+    id, err := uuid.Parse(chi.URLParam(r, "ruleID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid ruleID")
+        return database.FilterRule{}, false
+    }
+
+    rule, err := cfg.DB.GetFilterRule(r.Context(), database.GetFilterRuleParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusNotFound, "rule not found")
+        return database.FilterRule{}, false
+    }
+
+    return rule, true
+}
+
+func databaseRuleToRule(r database.FilterRule) FilterRule {
+    return FilterRule{
+        ID:           r.ID,
+        CreatedAt:    r.CreatedAt,
+        UpdatedAt:    r.UpdatedAt,
+        Name:         r.Name,
+        FolderID:     nullUUIDPtr(r.FolderID),
+        FeedFollowID: nullUUIDPtr(r.FeedFollowID),
+        Field:        r.Field,
+        MatchType:    r.MatchType,
+        Pattern:      r.Pattern,
+        Action:       r.Action,
+        Tag:          r.Tag,
+        Enabled:      r.Enabled,
+    }
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+    if !id.Valid {
+        return nil
+    }
+    return &id.UUID
+}
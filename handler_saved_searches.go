@@ -0,0 +1,269 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+)
+
+const (
+    defaultTimelineLimit = 50
+    maxTimelineLimit     = 200
+)
+
+type SavedSearch struct {
+    ID          uuid.UUID `json:"id"`
+    CreatedAt   time.Time `json:"created_at"`
+    UpdatedAt   time.Time `json:"updated_at"`
+    Name        string    `json:"name"`
+    Query       string    `json:"query"`
+    Language    string    `json:"language"`
+    Notify      bool      `json:"notify"`
+    UnreadCount *int64    `json:"unread_count,omitempty"`
+}
+
+func (cfg *apiConfig) handleCreateSavedSearch(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        Name     string `json:"name"`
+        Query    string `json:"query"`
+        Language string `json:"language"`
+        Notify   bool   `json:"notify"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    params.Query = strings.TrimSpace(params.Query)
+    if params.Query == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "query is required")
+        return
+    }
+    if params.Name == "" {
+        params.Name = params.Query
+    }
+
+    now := time.Now().UTC()
+    search, err := cfg.DB.CreateSavedSearch(r.Context(), database.CreateSavedSearchParams{
+        ID:        uuid.New(),
+        CreatedAt: now,
+        UpdatedAt: now,
+        UserID:    user.ID,
+        Name:      params.Name,
+        Query:     params.Query,
+        Language:  params.Language,
+        Notify:    params.Notify,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create saved search")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusCreated, databaseSavedSearchToSavedSearch(search))
+}
+
+func (cfg *apiConfig) handleGetSavedSearches(w http.ResponseWriter, r *http.Request, user database.User) {
+    rows, err := cfg.DB.GetSavedSearchesForUser(r.Context(), user.ID)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get saved searches")
+        return
+    }
+
+    out := make([]SavedSearch, 0, len(rows))
+    for _, row := range rows {
+        unread := row.UnreadCount
+        out = append(out, SavedSearch{
+            ID:          row.ID,
+            CreatedAt:   row.CreatedAt,
+            UpdatedAt:   row.UpdatedAt,
+            Name:        row.Name,
+            Query:       row.Query,
+            Language:    row.Language,
+            Notify:      row.Notify,
+            UnreadCount: &unread,
+        })
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handleGetSavedSearch(w http.ResponseWriter, r *http.Request, user database.User) {
+    search, ok := cfg.savedSearchFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, databaseSavedSearchToSavedSearch(search))
+}
+
+func (cfg *apiConfig) handleUpdateSavedSearch(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        Name     *string `json:"name"`
+        Query    *string `json:"query"`
+        Language *string `json:"language"`
+        Notify   *bool   `json:"notify"`
+    }
+
+    search, ok := cfg.savedSearchFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    update := database.UpdateSavedSearchParams{
+        ID:        search.ID,
+        UserID:    user.ID,
+        Name:      search.Name,
+        Query:     search.Query,
+        Language:  search.Language,
+        Notify:    search.Notify,
+        UpdatedAt: time.Now().UTC(),
+    }
+    if params.Name != nil {
+        update.Name = *params.Name
+    }
+    if params.Query != nil {
+        update.Query = strings.TrimSpace(*params.Query)
+        if update.Query == "" {
+            httputil.RespondWithError(w, http.StatusBadRequest, "query cannot be empty")
+            return
+        }
+    }
+    if params.Language != nil {
+        update.Language = *params.Language
+    }
+    if params.Notify != nil {
+        update.Notify = *params.Notify
+    }
+
+    updated, err := cfg.DB.UpdateSavedSearch(r.Context(), update)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not update saved search")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, databaseSavedSearchToSavedSearch(updated))
+}
+
+func (cfg *apiConfig) handleDeleteSavedSearch(w http.ResponseWriter, r *http.Request, user database.User) {
+    id, err := uuid.Parse(chi.URLParam(r, "savedSearchID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid savedSearchID")
+        return
+    }
+
+    n, err := cfg.DB.DeleteSavedSearch(r.Context(), database.DeleteSavedSearchParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not delete saved search")
+        return
+    }
+    if n == 0 {
+        httputil.RespondWithError(w, http.StatusNotFound, "saved search not found")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleGetSavedSearchPosts is the saved search's timeline: matching posts
+// from followed feeds, newest first. Pass unread=true for unread posts only.
+func (cfg *apiConfig) handleGetSavedSearchPosts(w http.ResponseWriter, r *http.Request, user database.User) {
+    search, ok := cfg.savedSearchFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    limit, offset, err := parsePagination(r, defaultTimelineLimit, maxTimelineLimit)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    rows, err := cfg.DB.GetSavedSearchPosts(r.Context(), database.GetSavedSearchPostsParams{
+        SavedSearchID: search.ID,
+        UnreadOnly:    r.URL.Query().Get("unread") == "true",
+        RowLimit:      limit,
+        RowOffset:     offset,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get posts")
+        return
+    }
+
+    posts := make([]TimelinePost, 0, len(rows))
+    for _, row := range rows {
+        posts = append(posts, TimelinePost{
+            ID:          row.ID,
+            CreatedAt:   row.CreatedAt,
+            UpdatedAt:   row.UpdatedAt,
+            Title:       row.Title,
+            URL:         row.Url,
+            Description: row.Description,
+            Content:     row.Content,
+            Author:      row.Author,
+            Categories:  row.Categories,
+            PublishedAt: row.PublishedAt,
+            FeedID:      row.FeedID,
+            FeedTitle:   row.FeedTitle,
+            FeedColor:   nullStringPtr(row.FeedColor),
+            Read:        row.ReadAt.Valid,
+            Starred:     row.StarredAt.Valid,
+            Hidden:      row.HiddenAt.Valid,
+            Tags:        row.Tags,
+        })
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, posts)
+}
+
+// savedSearchFromURL loads the user's saved search named by the
+// savedSearchID URL parameter, responding with an error if it can't.
+func (cfg *apiConfig) savedSearchFromURL(w http.ResponseWriter, r *http.Request, user database.User) (database.SavedSearch, bool) {
+    id, err := uuid.Parse(chi.URLParam(r, "savedSearchID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid savedSearchID")
+        return database.SavedSearch{}, false
+    }
+
+    search, err := cfg.DB.GetSavedSearch(r.Context(), database.GetSavedSearchParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusNotFound, "saved search not found")
+        return database.SavedSearch{}, false
+    }
+
+    return search, true
+}
+
+func databaseSavedSearchToSavedSearch(s database.SavedSearch) SavedSearch {
+    return SavedSearch{
+        ID:        s.ID,
+        CreatedAt: s.CreatedAt,
+        UpdatedAt: s.UpdatedAt,
+        Name:      s.Name,
+        Query:     s.Query,
+        Language:  s.Language,
+        Notify:    s.Notify,
+    }
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "html"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+)
+
+const (
+    defaultSearchLimit = 20
+    maxSearchLimit     = 100
+)
+
+type SearchResult struct {
+    ID          uuid.UUID `json:"id"`
+    Title       string    `json:"title"`
+    URL         string    `json:"url"`
+    PublishedAt time.Time `json:"published_at"`
+    FeedID      uuid.UUID `json:"feed_id"`
+    FeedTitle   string    `json:"feed_title"`
+    Rank        float32   `json:"rank"`
+    Snippet     string    `json:"snippet"`
+    Read        bool      `json:"read"`
+    Starred     bool      `json:"starred"`
+}
+
+// handleSearch runs a full-text search over posts. q accepts web-search
+// syntax: "quoted phrases", OR, and -excluded terms. Results default to the
+// user's followed feeds; pass followed=false to search everything. Posts
+// hidden by rules are left out unless include_hidden=true.
+func (cfg *apiConfig) handleSearch(w http.ResponseWriter, r *http.Request, user database.User) {
+    query := r.URL.Query()
+
+    params := database.SearchPostsParams{
+        Query:        strings.TrimSpace(query.Get("q")),
+        Language:     query.Get("lang"),
+        UserID:       user.ID,
+        FollowedOnly: query.Get("followed") != "false",
+        RowLimit:     defaultSearchLimit,
+    }
+    if params.Query == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "q is required")
+        return
+    }
+
+    if s := query.Get("feed_id"); s != "" {
+        id, err := uuid.Parse(s)
+        if err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "invalid feed_id")
+            return
+        }
+        params.FeedID = uuid.NullUUID{UUID: id, Valid: true}
+    }
+
+    var err error
+    if params.PublishedAfter, err = parseTimeParam(query.Get("from")); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid from: use RFC 3339 or YYYY-MM-DD")
+        return
+    }
+    if params.PublishedBefore, err = parseTimeParam(query.Get("to")); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid to: use RFC 3339 or YYYY-MM-DD")
+        return
+    }
+
+    if s := query.Get("read"); s != "" {
+        read, err := strconv.ParseBool(s)
+        if err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "invalid read")
+            return
+        }
+        params.IsRead = sql.NullBool{Bool: read, Valid: true}
+    }
+
+    if s := query.Get("include_hidden"); s != "" {
+        if params.IncludeHidden, err = strconv.ParseBool(s); err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "invalid include_hidden")
+            return
+        }
+    }
+
+    if params.RowLimit, params.RowOffset, err = parsePagination(r, defaultSearchLimit, maxSearchLimit); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    rows, err := cfg.DB.SearchPosts(r.Context(), params)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not search posts")
+        return
+    }
+
+    results := make([]SearchResult, 0, len(rows))
+    for _, row := range rows {
+        results = append(results, SearchResult{
+            ID:          row.ID,
+            Title:       row.Title,
+            URL:         row.Url,
+            PublishedAt: row.PublishedAt,
+            FeedID:      row.FeedID,
+            FeedTitle:   row.FeedTitle,
+            Rank:        row.Rank,
+            Snippet:     highlightSnippet(row.Snippet),
+            Read:        row.ReadAt.Valid,
+            Starred:     row.StarredAt.Valid,
+        })
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, results)
+}
+
+// highlightSnippet escapes a ts_headline snippet and turns its \x01/\x02
+// match markers into <mark> tags.
+func highlightSnippet(s string) string {
+    s = html.EscapeString(s)
+    s = strings.ReplaceAll(s, "\x01", "<mark>")
+    s = strings.ReplaceAll(s, "\x02", "</mark>")
+    return s
+}
+
+// parseTimeParam parses an optional RFC 3339 timestamp or YYYY-MM-DD date
+// from a query string.
+func parseTimeParam(s string) (sql.NullTime, error) {
+    if s == "" {
+        return sql.NullTime{}, nil
+    }
+    for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+        if t, err := time.Parse(layout, s); err == nil {
+            return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+        }
+    }
+    return sql.NullTime{}, fmt.Errorf("invalid time %q", s)
+}
+
+// parsePagination reads the limit and offset query parameters.
+func parsePagination(r *http.Request, defaultLimit, maxLimit int32) (int32, int32, error) {
+    limit, offset := defaultLimit, int32(0)
+
+    if s := r.URL.Query().Get("limit"); s != "" {
+        n, err := strconv.Atoi(s)
+        if err != nil || n < 1 || n > int(maxLimit) {
+            return 0, 0, fmt.Errorf("invalid limit: must be between 1 and %d", maxLimit)
+        }
+        limit = int32(n)
+    }
+
+    if s := r.URL.Query().Get("offset"); s != "" {
+        n, err := strconv.Atoi(s)
+        if err != nil || n < 0 || n > 1<<20 {
+            return 0, 0, errors.New("invalid offset: must be a non-negative integer")
+        }
+        offset = int32(n)
+    }
+
+    return limit, offset, nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "log/slog"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/auth"
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+)
+
+// handleLogin checks a username and password and starts a browser session.
+// The session token goes in an HttpOnly cookie; the CSRF token that
+// mutating requests must echo in X-CSRF-Token is returned in the body and
+// in a cookie scripts can read.
+func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
+    type requestBody struct {
+        Username string `json:"username"`
+        Password string `json:"password"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    if params.Username == "" || params.Password == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "username and password are required")
+        return
+    }
+
+    // Unknown users go through the same password check as known ones so
+    // timing doesn't reveal which usernames exist.
+    user, err := cfg.DB.GetUserByUsername(r.Context(), params.Username)
+    if err != nil && !errors.Is(err, sql.ErrNoRows) {
+        slog.ErrorContext(r.Context(), "looking up user failed", logging.Err(err))
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not log in")
+        return
+    }
+    if !auth.CheckPassword(user.PasswordHash.String, params.Password) {
+        httputil.RespondWithError(w, http.StatusUnauthorized, "invalid username or password")
+        return
+    }
+    if user.DisabledAt.Valid {
+        httputil.RespondWithError(w, http.StatusForbidden, "account is disabled")
+        return
+    }
+
+    now := time.Now().UTC()
+
+    if _, err := cfg.DB.DeleteExpiredSessions(r.Context(), now); err != nil {
+        slog.ErrorContext(r.Context(), "deleting expired sessions failed", logging.Err(err))
+    }
+
+    token, hash, err := auth.NewToken()
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not log in")
+        return
+    }
+    csrfToken, _, err := auth.NewToken()
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not log in")
+        return
+    }
+
+    // A new session every time, so a token planted before login is useless.
+    session, err := cfg.DB.CreateSession(r.Context(), database.CreateSessionParams{
+        ID:        uuid.New(),
+        Now:       now,
+        UserID:    user.ID,
+        TokenHash: hash,
+        CsrfToken: csrfToken,
+        ExpiresAt: now.Add(auth.SessionTTL),
+        UserAgent: r.UserAgent(),
+    })
+    if err != nil {
+        slog.ErrorContext(r.Context(), "creating session failed", logging.Err(err))
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not log in")
+        return
+    }
+
+    auth.SetSessionCookies(w, token, csrfToken, session.ExpiresAt, requestIsHTTPS(r))
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+        "user_id":    user.ID,
+        "csrf_token": csrfToken,
+        "expires_at": session.ExpiresAt,
+    })
+}
+
+// handleLogout ends the session the request was made with.
+func (cfg *apiConfig) handleLogout(w http.ResponseWriter, r *http.Request, user database.User) {
+    sessionID, ok := auth.SessionFromContext(r.Context())
+    if !ok {
+        httputil.RespondWithError(w, http.StatusBadRequest, "not logged in with a session")
+        return
+    }
+
+    if err := cfg.DB.DeleteSession(r.Context(), sessionID); err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not log out")
+        return
+    }
+
+    auth.ClearSessionCookies(w, requestIsHTTPS(r))
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "logged out"})
+}
+
+// handleSetPassword sets the username and password used to log in. Once a
+// password is set, changing it needs the current one, and signs out every
+// other session.
+func (cfg *apiConfig) handleSetPassword(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        Username        string `json:"username"`
+        Password        string `json:"password"`
+        CurrentPassword string `json:"current_password"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    username := strings.TrimSpace(params.Username)
+    if username == "" {
+        username = user.Username.String
+    }
+    if username == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "username is required")
+        return
+    }
+
+    if user.PasswordHash.Valid && !auth.CheckPassword(user.PasswordHash.String, params.CurrentPassword) {
+        httputil.RespondWithError(w, http.StatusForbidden, "current_password is incorrect")
+        return
+    }
+
+    hash, err := auth.HashPassword(params.Password)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    _, err = cfg.DB.SetUserPassword(r.Context(), database.SetUserPasswordParams{
+        Username:     sql.NullString{String: username, Valid: true},
+        PasswordHash: sql.NullString{String: hash, Valid: true},
+        UpdatedAt:    time.Now().UTC(),
+        ID:           user.ID,
+    })
+    if err != nil {
+        if isUniqueViolation(err) {
+            httputil.RespondWithError(w, http.StatusConflict, "username is taken")
+            return
+        }
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not set password")
+        return
+    }
+
+    sessionID, _ := auth.SessionFromContext(r.Context())
+    if err := cfg.DB.DeleteOtherSessionsForUser(r.Context(), database.DeleteOtherSessionsForUserParams{
+        UserID: user.ID,
+        KeepID: sessionID,
+    }); err != nil {
+        slog.ErrorContext(r.Context(), "deleting other sessions failed", logging.Err(err))
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "password set", "username": username})
+}
+
+// checkSession looks up the session a cookie's token belongs to.
+func (cfg *apiConfig) checkSession(ctx context.Context, token string) credentials {
+    now := time.Now().UTC()
+    hash := auth.HashToken(token)
+
+    row, err := cfg.DB.GetSessionByToken(ctx, database.GetSessionByTokenParams{
+        Now:        now,
+        TokenHash:  hash,
+        GraceAfter: now.Add(-auth.SessionRotateGrace),
+    })
+    if err != nil {
+        return credentials{failure: "invalid or expired session", badSession: true}
+    }
+    return credentials{user: row.User, session: &row.Session, tokenHash: hash}
+}
+
+// authenticateSession is middlewareAuth for requests carrying a session
+// cookie. Sessions have every scope, but requests that change something
+// must carry the session's CSRF token. A session used after
+// auth.SessionRotateAfter gets a new token and a later expiry.
+func (cfg *apiConfig) authenticateSession(w http.ResponseWriter, r *http.Request, c credentials, handler authedHandler) {
+    now := time.Now().UTC()
+    session := *c.session
+
+    if !auth.SafeMethod(r.Method) && !auth.CheckCSRF(r, session.CsrfToken) {
+        httputil.RespondWithError(w, http.StatusForbidden, "missing or invalid CSRF token")
+        return
+    }
+
+    // WebSocket handshakes write their own response, so a new cookie would
+    // be lost; rotate on the next ordinary request instead.
+    if session.TokenHash == c.tokenHash && now.Sub(session.RotatedAt) > auth.SessionRotateAfter && r.Header.Get("Upgrade") == "" {
+        cfg.rotateSession(w, r, session, c.tokenHash, now)
+    }
+
+    logging.SetUserID(r.Context(), c.user.ID)
+    ctx := auth.WithScopes(r.Context(), []string{auth.ScopeAccount})
+    ctx = auth.WithSession(ctx, session.ID)
+    handler(w, r.WithContext(ctx), c.user)
+}
+
+func (cfg *apiConfig) rotateSession(w http.ResponseWriter, r *http.Request, session database.Session, oldHash string, now time.Time) {
+    token, hash, err := auth.NewToken()
+    if err != nil {
+        slog.ErrorContext(r.Context(), "creating session token failed", logging.Err(err))
+        return
+    }
+
+    rotated, err := cfg.DB.RotateSession(r.Context(), database.RotateSessionParams{
+        NewTokenHash: hash,
+        Now:          now,
+        ExpiresAt:    now.Add(auth.SessionTTL),
+        ID:           session.ID,
+        OldTokenHash: oldHash,
+    })
+    if err != nil {
+        // Another request rotated it first; the old token still works for
+        // the grace period and that request set the new cookie.
+        if !errors.Is(err, sql.ErrNoRows) {
+            slog.ErrorContext(r.Context(), "rotating session failed", "session_id", session.ID, logging.Err(err))
+        }
+        return
+    }
+
+    auth.SetSessionCookies(w, token, rotated.CsrfToken, rotated.ExpiresAt, requestIsHTTPS(r))
+}
+
+// requestIsHTTPS reports whether the client reached us over HTTPS, directly
+// or through a proxy.
+func requestIsHTTPS(r *http.Request) bool {
+    return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+    "github.com/mdbailin/go-rss-server/internal/stream"
+)
+
+const (
+    // streamHeartbeat is how often an idle stream gets a comment line, to
+    // keep proxies from closing it.
+    streamHeartbeat = 15 * time.Second
+
+    // streamReplayLimit caps how many missed posts are replayed on resume.
+    streamReplayLimit = 500
+
+    // streamRetry is the reconnect delay suggested to clients, in ms.
+    streamRetry = 5000
+)
+
+// handleStream keeps a Server-Sent Events connection open and pushes each
+// new post from the user's followed feeds as a "post" event whose data is
+// a TimelinePost and whose id is the post ID. Reconnecting clients send
+// Last-Event-ID (or the last_event_id query parameter) to receive the
+// posts they missed.
+func (cfg *apiConfig) handleStream(w http.ResponseWriter, r *http.Request, user database.User) {
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "streaming not supported")
+        return
+    }
+
+    // Subscribe before replaying so nothing stored in between is missed;
+    // anything seen during replay is skipped when it arrives live.
+    events, unsubscribe := cfg.Stream.Subscribe()
+    defer unsubscribe()
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+    w.Header().Set("X-Accel-Buffering", "no")
+    w.WriteHeader(http.StatusOK)
+    fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+    flusher.Flush()
+
+    replayed := map[uuid.UUID]bool{}
+
+    lastEventID := r.Header.Get("Last-Event-ID")
+    if lastEventID == "" {
+        lastEventID = r.URL.Query().Get("last_event_id")
+    }
+    if afterID, err := uuid.Parse(lastEventID); err == nil {
+        rows, err := cfg.DB.GetTimelineSinceForUser(r.Context(), database.GetTimelineSinceForUserParams{
+            UserID:      user.ID,
+            AfterPostID: afterID,
+            RowLimit:    streamReplayLimit,
+        })
+        if err != nil {
+            return
+        }
+        for _, row := range rows {
+            post := streamPost(database.GetTimelinePostForUserRow(row))
+            if err := writeStreamEvent(w, post.ID.String(), stream.EventPost, post); err != nil {
+                return
+            }
+            replayed[post.ID] = true
+        }
+        flusher.Flush()
+    }
+
+    heartbeat := time.NewTicker(streamHeartbeat)
+    defer heartbeat.Stop()
+
+    for {
+        select {
+        case <-r.Context().Done():
+            return
+
+        case <-heartbeat.C:
+            if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+                return
+            }
+            flusher.Flush()
+
+        case ev, ok := <-events:
+            if !ok {
+                // Fell too far behind; the client reconnects and resumes.
+                return
+            }
+            if ev.Type != stream.EventPost || replayed[ev.PostID] {
+                continue
+            }
+
+            row, err := cfg.DB.GetTimelinePostForUser(r.Context(), database.GetTimelinePostForUserParams{
+                UserID: user.ID,
+                PostID: ev.PostID,
+            })
+            if err != nil || row.HideFromTimeline {
+                // Not from a followed feed, hidden by a rule, or from a
+                // feed hidden from the timeline.
+                continue
+            }
+            post := streamPost(row)
+            if err := writeStreamEvent(w, post.ID.String(), stream.EventPost, post); err != nil {
+                return
+            }
+            flusher.Flush()
+        }
+    }
+}
+
+// streamPost converts a post loaded for the stream. GetTimelineSinceForUser
+// rows share the same shape and convert directly.
+func streamPost(row database.GetTimelinePostForUserRow) TimelinePost {
+    return TimelinePost{
+        ID:          row.ID,
+        CreatedAt:   row.CreatedAt,
+        UpdatedAt:   row.UpdatedAt,
+        Title:       row.Title,
+        URL:         row.Url,
+        Description: row.Description,
+        Content:     row.Content,
+        Author:      row.Author,
+        Categories:  row.Categories,
+        PublishedAt: row.PublishedAt,
+        FeedID:      row.FeedID,
+        FeedTitle:   row.FeedTitle,
+        FeedColor:   nullStringPtr(row.FeedColor),
+        Read:        row.ReadAt.Valid,
+        Starred:     row.StarredAt.Valid,
+        Tags:        row.Tags,
+    }
+}
+
+// writeStreamEvent writes one SSE event with a JSON data line.
+func writeStreamEvent(w http.ResponseWriter, id, event string, data any) error {
+    b, err := json.Marshal(data)
+    if err != nil {
+        return err
+    }
+    _, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, b)
+    return err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+    "database/sql"
+    "net/http"
+    "strconv"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+)
+
+// handleGetTimeline returns the user's personal timeline: posts from the
+// feeds they follow with their own state applied. Posts hidden by rules
+// are left out unless include_hidden=true.
+func (cfg *apiConfig) handleGetTimeline(w http.ResponseWriter, r *http.Request, user database.User) {
+    query := r.URL.Query()
+
+    params := database.GetTimelineForUserParams{UserID: user.ID}
+
+    for name, dst := range map[string]*bool{
+        "unread":         &params.UnreadOnly,
+        "starred":        &params.StarredOnly,
+        "include_hidden": &params.IncludeHidden,
+    } {
+        if s := query.Get(name); s != "" {
+            v, err := strconv.ParseBool(s)
+            if err != nil {
+                httputil.RespondWithError(w, http.StatusBadRequest, "invalid "+name)
+                return
+            }
+            *dst = v
+        }
+    }
+
+    for name, dst := range map[string]*uuid.NullUUID{
+        "folder_id": &params.FolderID,
+        "feed_id":   &params.FeedID,
+    } {
+        if s := query.Get(name); s != "" {
+            id, err := uuid.Parse(s)
+            if err != nil {
+                httputil.RespondWithError(w, http.StatusBadRequest, "invalid "+name)
+                return
+            }
+            *dst = uuid.NullUUID{UUID: id, Valid: true}
+        }
+    }
+
+    if s := query.Get("tag"); s != "" {
+        params.Tag = sql.NullString{String: s, Valid: true}
+    }
+
+    var err error
+    if params.RowLimit, params.RowOffset, err = parsePagination(r, defaultTimelineLimit, maxTimelineLimit); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    rows, err := cfg.DB.GetTimelineForUser(r.Context(), params)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get timeline")
+        return
+    }
+
+    posts := make([]TimelinePost, 0, len(rows))
+    for _, row := range rows {
+        posts = append(posts, TimelinePost{
+            ID:          row.ID,
+            CreatedAt:   row.CreatedAt,
+            UpdatedAt:   row.UpdatedAt,
+            Title:       row.Title,
+            URL:         row.Url,
+            Description: row.Description,
+            Content:     row.Content,
+            Author:      row.Author,
+            Categories:  row.Categories,
+            PublishedAt: row.PublishedAt,
+            FeedID:      row.FeedID,
+            FeedTitle:   row.FeedTitle,
+            FeedColor:   nullStringPtr(row.FeedColor),
+            Read:        row.ReadAt.Valid,
+            Starred:     row.StarredAt.Valid,
+            Hidden:      row.HiddenAt.Valid,
+            Tags:        row.Tags,
+        })
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, posts)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/auth"
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+)
+
+// User is the signed-in user's own account.
+type User struct {
+    ID        uuid.UUID `json:"id"`
+    CreatedAt time.Time `json:"created_at"`
+    UpdatedAt time.Time `json:"updated_at"`
+    Name      string    `json:"name"`
+    Username  *string   `json:"username"`
+    IsAdmin   bool      `json:"is_admin"`
+}
+
+func (cfg *apiConfig) handleGetMe(w http.ResponseWriter, r *http.Request, user database.User) {
+    httputil.RespondWithJSON(w, http.StatusOK, databaseUserToUser(user))
+}
+
+func (cfg *apiConfig) handleUpdateMe(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        Name *string `json:"name"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    if params.Name == nil {
+        httputil.RespondWithJSON(w, http.StatusOK, databaseUserToUser(user))
+        return
+    }
+
+    name := strings.TrimSpace(*params.Name)
+    if name == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "name must not be empty")
+        return
+    }
+
+    updated, err := cfg.DB.UpdateUserName(r.Context(), database.UpdateUserNameParams{
+        Name:      name,
+        UpdatedAt: time.Now().UTC(),
+        ID:        user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not update user")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, databaseUserToUser(updated))
+}
+
+// handleRotateKey gives an API key a new secret, keeping its name, scopes
+// and expiry. It rotates the key making the request unless api_key_id
+// names another; requests made with a session must name one.
+func (cfg *apiConfig) handleRotateKey(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        APIKeyID *uuid.UUID `json:"api_key_id"`
+    }
+
+    // The body is optional.
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    id, ok := auth.APIKeyFromContext(r.Context())
+    if params.APIKeyID != nil {
+        id, ok = *params.APIKeyID, true
+    }
+    if !ok {
+        httputil.RespondWithError(w, http.StatusBadRequest, "api_key_id is required when logged in with a session")
+        return
+    }
+
+    key, prefix, hash, err := auth.NewAPIKey()
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not rotate api key")
+        return
+    }
+
+    apiKey, err := cfg.DB.RotateAPIKey(r.Context(), database.RotateAPIKeyParams{
+        Prefix:    prefix,
+        KeyHash:   hash,
+        UpdatedAt: time.Now().UTC(),
+        ID:        id,
+        UserID:    user.ID,
+    })
+    if errors.Is(err, sql.ErrNoRows) {
+        httputil.RespondWithError(w, http.StatusNotFound, "api key not found")
+        return
+    }
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not rotate api key")
+        return
+    }
+
+    out := databaseAPIKeyToAPIKey(apiKey)
+    out.Key = key
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+// handleDeleteMe deletes the account along with its keys, sessions,
+// follows, post states and settings.
+func (cfg *apiConfig) handleDeleteMe(w http.ResponseWriter, r *http.Request, user database.User) {
+    err := cfg.deleteUser(r.Context(), user.ID)
+    if errors.Is(err, errLastAdmin) {
+        httputil.RespondWithError(w, http.StatusConflict, "the only admin can't delete their account")
+        return
+    }
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not delete user")
+        return
+    }
+
+    if _, ok := auth.SessionFromContext(r.Context()); ok {
+        auth.ClearSessionCookies(w, requestIsHTTPS(r))
+    }
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// errLastAdmin is returned when removing a user would leave no enabled
+// admin to run the instance.
+var errLastAdmin = errors.New("last admin")
+
+// deleteUser deletes a user and everything of theirs, returning
+// sql.ErrNoRows if there is no such user and errLastAdmin if they are the
+// only enabled admin. Feeds they added stay; any that nobody else follows
+// are deactivated and cleaned up by the feed worker.
+func (cfg *apiConfig) deleteUser(ctx context.Context, id uuid.UUID) error {
+    return cfg.withTx(ctx, func(q *database.Queries) error {
+        if err := checkNotLastAdmin(ctx, q, id); err != nil {
+            return err
+        }
+        n, err := q.DeleteUser(ctx, id)
+        if err != nil {
+            return fmt.Errorf("delete user: %w", err)
+        }
+        if n == 0 {
+            return sql.ErrNoRows
+        }
+        return nil
+    })
+}
+
+// checkNotLastAdmin returns errLastAdmin if the user is the only enabled
+// admin, holding the user accounts lock until the transaction ends so a
+// concurrent removal can't also pass.
+func checkNotLastAdmin(ctx context.Context, q *database.Queries, id uuid.UUID) error {
+    if err := q.LockUserAccounts(ctx); err != nil {
+        return fmt.Errorf("lock users: %w", err)
+    }
+    user, err := q.GetUser(ctx, id)
+    if err != nil {
+        return err
+    }
+    if !user.IsAdmin || user.DisabledAt.Valid {
+        return nil
+    }
+    others, err := q.CountOtherActiveAdmins(ctx, id)
+    if err != nil {
+        return fmt.Errorf("count admins: %w", err)
+    }
+    if others == 0 {
+        return errLastAdmin
+    }
+    return nil
+}
+
+func databaseUserToUser(u database.User) User {
+    return User{
+        ID:        u.ID,
+        CreatedAt: u.CreatedAt,
+        UpdatedAt: u.UpdatedAt,
+        Name:      u.Name,
+        Username:  nullStringPtr(u.Username),
+        IsAdmin:   u.IsAdmin,
+    }
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+    "github.com/mdbailin/go-rss-server/internal/webhooks"
+)
+
+const (
+    defaultDeliveriesLimit = 50
+    maxDeliveriesLimit     = 200
+)
+
+// Webhook receives a signed POST for every new post matching its filter.
+// The secret is only returned when the webhook is created.
+type Webhook struct {
+    ID                  uuid.UUID  `json:"id"`
+    CreatedAt           time.Time  `json:"created_at"`
+    UpdatedAt           time.Time  `json:"updated_at"`
+    URL                 string     `json:"url"`
+    Secret              string     `json:"secret,omitempty"`
+    FeedID              *uuid.UUID `json:"feed_id"`
+    FolderID            *uuid.UUID `json:"folder_id"`
+    RuleID              *uuid.UUID `json:"rule_id"`
+    Enabled             bool       `json:"enabled"`
+    ConsecutiveFailures int32      `json:"consecutive_failures"`
+    DisabledAt          *time.Time `json:"disabled_at"`
+    DisabledReason      string     `json:"disabled_reason,omitempty"`
+}
+
+// WebhookDelivery is one post sent (or being sent) to a webhook.
+type WebhookDelivery struct {
+    ID             uuid.UUID        `json:"id"`
+    CreatedAt      time.Time        `json:"created_at"`
+    PostID         uuid.UUID        `json:"post_id"`
+    Event          string           `json:"event"`
+    Status         string           `json:"status"`
+    Attempts       int32            `json:"attempts"`
+    NextAttemptAt  *time.Time       `json:"next_attempt_at"`
+    LastAttemptAt  *time.Time       `json:"last_attempt_at"`
+    ResponseStatus *int32           `json:"response_status"`
+    LastError      string           `json:"last_error,omitempty"`
+    DeliveredAt    *time.Time       `json:"delivered_at"`
+    Payload        json.RawMessage  `json:"payload,omitempty"`
+    AttemptLog     []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+type WebhookAttempt struct {
+    AttemptedAt    time.Time `json:"attempted_at"`
+    ResponseStatus *int32    `json:"response_status"`
+    Error          string    `json:"error,omitempty"`
+    DurationMs     int32     `json:"duration_ms"`
+}
+
+// handleCreateWebhook registers a webhook. At most one of feed_id,
+// folder_id and rule_id narrows which posts it receives.
+func (cfg *apiConfig) handleCreateWebhook(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        URL      string     `json:"url"`
+        FeedID   *uuid.UUID `json:"feed_id"`
+        FolderID *uuid.UUID `json:"folder_id"`
+        RuleID   *uuid.UUID `json:"rule_id"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    params.URL = strings.TrimSpace(params.URL)
+    if params.URL == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "url is required")
+        return
+    }
+    if !cfg.validateWebhookURL(w, r, params.URL) {
+        return
+    }
+
+    filters := 0
+    for _, id := range []*uuid.UUID{params.FeedID, params.FolderID, params.RuleID} {
+        if id != nil {
+            filters++
+        }
+    }
+    if filters > 1 {
+        httputil.RespondWithError(w, http.StatusBadRequest, "set at most one of feed_id, folder_id and rule_id")
+        return
+    }
+
+    var feedID, folderID, ruleID uuid.NullUUID
+    switch {
+    case params.FeedID != nil:
+        if _, err := cfg.DB.GetFeedFollowForFeed(r.Context(), database.GetFeedFollowForFeedParams{
+            UserID: user.ID,
+            FeedID: *params.FeedID,
+        }); err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "you don't follow that feed")
+            return
+        }
+        feedID = uuid.NullUUID{UUID: *params.FeedID, Valid: true}
+    case params.FolderID != nil:
+        if _, err := cfg.DB.GetFolder(r.Context(), database.GetFolderParams{
+            ID:     *params.FolderID,
+            UserID: user.ID,
+        }); err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "folder not found")
+            return
+        }
+        folderID = uuid.NullUUID{UUID: *params.FolderID, Valid: true}
+    case params.RuleID != nil:
+        if _, err := cfg.DB.GetFilterRule(r.Context(), database.GetFilterRuleParams{
+            ID:     *params.RuleID,
+            UserID: user.ID,
+        }); err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, "rule not found")
+            return
+        }
+        ruleID = uuid.NullUUID{UUID: *params.RuleID, Valid: true}
+    }
+
+    secret, err := webhooks.NewSecret()
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create webhook")
+        return
+    }
+
+    now := time.Now().UTC()
+    hook, err := cfg.DB.CreateWebhook(r.Context(), database.CreateWebhookParams{
+        ID:        uuid.New(),
+        CreatedAt: now,
+        UpdatedAt: now,
+        UserID:    user.ID,
+        Url:       params.URL,
+        Secret:    secret,
+        FeedID:    feedID,
+        FolderID:  folderID,
+        RuleID:    ruleID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create webhook")
+        return
+    }
+
+    out := databaseWebhookToWebhook(hook)
+    out.Secret = hook.Secret
+    httputil.RespondWithJSON(w, http.StatusCreated, out)
+}
+
+func (cfg *apiConfig) handleGetWebhooks(w http.ResponseWriter, r *http.Request, user database.User) {
+    hooks, err := cfg.DB.GetWebhooksForUser(r.Context(), user.ID)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get webhooks")
+        return
+    }
+
+    out := make([]Webhook, 0, len(hooks))
+    for _, h := range hooks {
+        out = append(out, databaseWebhookToWebhook(h))
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handleGetWebhook(w http.ResponseWriter, r *http.Request, user database.User) {
+    hook, ok := cfg.webhookFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, databaseWebhookToWebhook(hook))
+}
+
+// handleUpdateWebhook changes a webhook's URL or enables/disables it.
+// Re-enabling a webhook that was disabled after repeated failures resets
+// its failure count.
+func (cfg *apiConfig) handleUpdateWebhook(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        URL     *string `json:"url"`
+        Enabled *bool   `json:"enabled"`
+    }
+
+    hook, ok := cfg.webhookFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    update := database.UpdateWebhookParams{
+        ID:        hook.ID,
+        UserID:    user.ID,
+        Url:       hook.Url,
+        Enabled:   hook.Enabled,
+        UpdatedAt: time.Now().UTC(),
+    }
+    if params.URL != nil {
+        update.Url = strings.TrimSpace(*params.URL)
+        if update.Url == "" {
+            httputil.RespondWithError(w, http.StatusBadRequest, "url can't be empty")
+            return
+        }
+        if !cfg.validateWebhookURL(w, r, update.Url) {
+            return
+        }
+    }
+    if params.Enabled != nil {
+        update.Enabled = *params.Enabled
+    }
+
+    updated, err := cfg.DB.UpdateWebhook(r.Context(), update)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not update webhook")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, databaseWebhookToWebhook(updated))
+}
+
+func (cfg *apiConfig) handleDeleteWebhook(w http.ResponseWriter, r *http.Request, user database.User) {
+    id, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid webhookID")
+        return
+    }
+
+    n, err := cfg.DB.DeleteWebhook(r.Context(), database.DeleteWebhookParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not delete webhook")
+        return
+    }
+    if n == 0 {
+        httputil.RespondWithError(w, http.StatusNotFound, "webhook not found")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleGetWebhookDeliveries lists a webhook's recent deliveries, newest
+// first, optionally filtered by status (pending, delivered or failed).
+func (cfg *apiConfig) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request, user database.User) {
+    hook, ok := cfg.webhookFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    params := database.GetWebhookDeliveriesParams{WebhookID: hook.ID}
+    if s := r.URL.Query().Get("status"); s != "" {
+        params.Status = sql.NullString{String: s, Valid: true}
+    }
+
+    var err error
+    if params.RowLimit, params.RowOffset, err = parsePagination(r, defaultDeliveriesLimit, maxDeliveriesLimit); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    rows, err := cfg.DB.GetWebhookDeliveries(r.Context(), params)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get deliveries")
+        return
+    }
+
+    out := make([]WebhookDelivery, 0, len(rows))
+    for _, d := range rows {
+        out = append(out, databaseDeliveryToDelivery(d))
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+// handleGetWebhookDelivery returns one delivery with its payload and a log
+// of every attempt.
+func (cfg *apiConfig) handleGetWebhookDelivery(w http.ResponseWriter, r *http.Request, user database.User) {
+    delivery, ok := cfg.webhookDeliveryFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    attempts, err := cfg.DB.GetWebhookDeliveryAttempts(r.Context(), delivery.ID)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get delivery attempts")
+        return
+    }
+
+    out := databaseDeliveryToDelivery(delivery)
+    out.Payload = json.RawMessage(delivery.Payload)
+    for _, a := range attempts {
+        out.AttemptLog = append(out.AttemptLog, WebhookAttempt{
+            AttemptedAt:    a.AttemptedAt,
+            ResponseStatus: nullInt32Ptr(a.ResponseStatus),
+            Error:          a.Error,
+            DurationMs:     a.DurationMs,
+        })
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, out)
+}
+
+// handleRetryWebhookDelivery queues a delivered or failed delivery to be
+// sent again.
+func (cfg *apiConfig) handleRetryWebhookDelivery(w http.ResponseWriter, r *http.Request, user database.User) {
+    delivery, ok := cfg.webhookDeliveryFromURL(w, r, user)
+    if !ok {
+        return
+    }
+
+    n, err := cfg.DB.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{
+        Now: time.Now().UTC(),
+        ID:  delivery.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not retry delivery")
+        return
+    }
+    if n == 0 {
+        httputil.RespondWithError(w, http.StatusConflict, "delivery is already pending")
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusAccepted, map[string]string{"status": "pending"})
+}
+
+// validateWebhookURL applies the same destination checks as feed URLs, so
+// webhooks can't be pointed at internal services. On failure it has
+// already responded.
+func (cfg *apiConfig) validateWebhookURL(w http.ResponseWriter, r *http.Request, rawURL string) bool {
+    if err := cfg.Guard.ValidateURL(r.Context(), rawURL); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("url not allowed: %v", err))
+        return false
+    }
+    return true
+}
+
+// webhookFromURL loads the user's webhook named by the webhookID URL
+// parameter, responding with an error if it can't.
+func (cfg *apiConfig) webhookFromURL(w http.ResponseWriter, r *http.Request, user database.User) (database.Webhook, bool) {
+    id, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid webhookID")
+        return database.Webhook{}, false
+    }
+
+    hook, err := cfg.DB.GetWebhook(r.Context(), database.GetWebhookParams{
+        ID:     id,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusNotFound, "webhook not found")
+        return database.Webhook{}, false
+    }
+
+    return hook, true
+}
+
+// webhookDeliveryFromURL loads the delivery named by the deliveryID URL
+// parameter, checking it belongs to one of the user's webhooks.
+func (cfg *apiConfig) webhookDeliveryFromURL(w http.ResponseWriter, r *http.Request, user database.User) (database.WebhookDelivery, bool) {
+    hook, ok := cfg.webhookFromURL(w, r, user)
+    if !ok {
+        return database.WebhookDelivery{}, false
+    }
+
+    id, err := uuid.Parse(chi.URLParam(r, "deliveryID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid deliveryID")
+        return database.WebhookDelivery{}, false
+    }
+
+    delivery, err := cfg.DB.GetWebhookDelivery(r.Context(), database.GetWebhookDeliveryParams{
+        ID:        id,
+        WebhookID: hook.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusNotFound, "delivery not found")
+        return database.WebhookDelivery{}, false
+    }
+
+    return delivery, true
+}
+
+func databaseWebhookToWebhook(h database.Webhook) Webhook {
+    return Webhook{
+        ID:                  h.ID,
+        CreatedAt:           h.CreatedAt,
+        UpdatedAt:           h.UpdatedAt,
+        URL:                 h.Url,
+        FeedID:              nullUUIDPtr(h.FeedID),
+        FolderID:            nullUUIDPtr(h.FolderID),
+        RuleID:              nullUUIDPtr(h.RuleID),
+        Enabled:             h.Enabled,
+        ConsecutiveFailures: h.ConsecutiveFailures,
+        DisabledAt:          nullTimePtr(h.DisabledAt),
+        DisabledReason:      h.DisabledReason,
+    }
+}
+
+func databaseDeliveryToDelivery(d database.WebhookDelivery) WebhookDelivery {
+    out := WebhookDelivery{
+        ID:             d.ID,
+        CreatedAt:      d.CreatedAt,
+        PostID:         d.PostID,
+        Event:          d.Event,
+        Status:         d.Status,
+        Attempts:       d.Attempts,
+        LastAttemptAt:  nullTimePtr(d.LastAttemptAt),
+        ResponseStatus: nullInt32Ptr(d.ResponseStatus),
+        LastError:      d.LastError,
+        DeliveredAt:    nullTimePtr(d.DeliveredAt),
+    }
+    if d.Status == "pending" {
+        next := d.NextAttemptAt
+        out.NextAttemptAt = &next
+    }
+    return out
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+    if !t.Valid {
+        return nil
+    }
+    return &t.Time
+}
+
+func nullInt32Ptr(n sql.NullInt32) *int32 {
+    if !n.Valid {
+        return nil
+    }
+    return &n.Int32
+}
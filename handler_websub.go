@@ -0,0 +1,148 @@
+package main
+
+import (
+    "io"
+    "log/slog"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+    "github.com/mdbailin/go-rss-server/internal/rss"
+    "github.com/mdbailin/go-rss-server/internal/websub"
+    "github.com/mdbailin/go-rss-server/internal/worker"
+)
+
+// websubMaxBody caps how much pushed content we accept in one delivery.
+const websubMaxBody = 10 << 20
+
+// handleWebSubVerify answers a hub's verification of intent. We only ever
+// ask to subscribe, so a subscribe for the topic we asked about is
+// confirmed by echoing the challenge and anything else gets a 404.
+func (cfg *apiConfig) handleWebSubVerify(w http.ResponseWriter, r *http.Request) {
+    sub, ok := cfg.websubSubscriptionFromURL(w, r)
+    if !ok {
+        return
+    }
+
+    query := r.URL.Query()
+    now := time.Now().UTC()
+
+    switch query.Get("hub.mode") {
+    case "subscribe":
+        challenge := query.Get("hub.challenge")
+        if query.Get("hub.topic") != sub.TopicUrl || challenge == "" {
+            http.NotFound(w, r)
+            return
+        }
+
+        lease := websub.LeaseSeconds
+        if n, err := strconv.Atoi(query.Get("hub.lease_seconds")); err == nil && n > 0 {
+            lease = n
+        }
+        if err := cfg.DB.ActivateWebSubSubscription(r.Context(), database.ActivateWebSubSubscriptionParams{
+            LeaseExpiresAt: now.Add(time.Duration(lease) * time.Second),
+            Now:            now,
+            ID:             sub.ID,
+        }); err != nil {
+            slog.ErrorContext(r.Context(), "activating websub subscription failed", "subscription_id", sub.ID, logging.Err(err))
+            http.Error(w, "could not activate subscription", http.StatusInternalServerError)
+            return
+        }
+
+        w.Header().Set("Content-Type", "text/plain")
+        w.WriteHeader(http.StatusOK)
+        io.WriteString(w, challenge)
+
+    case "denied":
+        if err := cfg.DB.DenyWebSubSubscription(r.Context(), database.DenyWebSubSubscriptionParams{
+            Reason: query.Get("hub.reason"),
+            Now:    now,
+            ID:     sub.ID,
+        }); err != nil {
+            slog.ErrorContext(r.Context(), "denying websub subscription failed", "subscription_id", sub.ID, logging.Err(err))
+        }
+        w.WriteHeader(http.StatusOK)
+
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+// handleWebSubContent ingests content a hub pushed for a subscription.
+// Deliveries with a missing or wrong signature are acknowledged but
+// ignored, as the spec requires, so a forged push can't tell whether it
+// worked.
+func (cfg *apiConfig) handleWebSubContent(w http.ResponseWriter, r *http.Request) {
+    id, err := uuid.Parse(chi.URLParam(r, "subscriptionID"))
+    if err != nil {
+        http.NotFound(w, r)
+        return
+    }
+    sub, err := cfg.DB.GetWebSubSubscription(r.Context(), id)
+    if err != nil {
+        // Tells the hub to stop sending; the feed is gone or resubscribed.
+        w.WriteHeader(http.StatusGone)
+        return
+    }
+
+    body, err := io.ReadAll(io.LimitReader(r.Body, websubMaxBody))
+    if err != nil {
+        http.Error(w, "could not read body", http.StatusBadRequest)
+        return
+    }
+
+    if !websub.VerifySignature(sub.Secret, r.Header.Get(websub.SignatureHeader), body) {
+        slog.WarnContext(r.Context(), "ignoring websub content with a bad signature", "subscription_id", sub.ID)
+        w.WriteHeader(http.StatusAccepted)
+        return
+    }
+
+    parsed, err := rss.Parse(body)
+    if err != nil {
+        slog.WarnContext(r.Context(), "ignoring websub content that does not parse", "subscription_id", sub.ID, logging.Err(err))
+        w.WriteHeader(http.StatusAccepted)
+        return
+    }
+
+    feed, err := cfg.DB.GetFeed(r.Context(), sub.FeedID)
+    if err != nil {
+        w.WriteHeader(http.StatusGone)
+        return
+    }
+
+    // Nobody follows the feed any more; the lease is left to lapse.
+    if feed.DeactivatedAt.Valid {
+        w.WriteHeader(http.StatusAccepted)
+        return
+    }
+
+    worker.Ingest(r.Context(), cfg.DB, feed, parsed, cfg.FeedOptions)
+
+    if err := cfg.DB.MarkWebSubPush(r.Context(), database.MarkWebSubPushParams{
+        Now: time.Now().UTC(),
+        ID:  sub.ID,
+    }); err != nil {
+        slog.ErrorContext(r.Context(), "marking websub push failed", "subscription_id", sub.ID, logging.Err(err))
+    }
+
+    w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) websubSubscriptionFromURL(w http.ResponseWriter, r *http.Request) (database.WebsubSubscription, bool) {
+    id, err := uuid.Parse(chi.URLParam(r, "subscriptionID"))
+    if err != nil {
+        http.NotFound(w, r)
+        return database.WebsubSubscription{}, false
+    }
+    sub, err := cfg.DB.GetWebSubSubscription(r.Context(), id)
+    if err != nil {
+        http.NotFound(w, r)
+        return database.WebsubSubscription{}, false
+    }
+    return sub, true
+}
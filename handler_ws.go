@@ -0,0 +1,504 @@
+package main
+
+// WebSocket protocol for GET /v1/ws
+//
+// Authenticate with the usual "Authorization: ApiKey <key>" header, or pass
+// the key as ?access_token=<key> from browsers, which can't set headers on
+// WebSocket requests. A browser signed in with /v1/login may instead rely on
+// its session cookie, from a page on the same origin. Every message in
+// either direction is one JSON object with a "type". Client messages may
+// carry an "id", which is echoed in the reply so requests can be matched to
+// responses.
+//
+// Client to server:
+//
+//	{"type":"subscribe","id":"1","topics":["posts","unread_counts","feed_health"],"feed_ids":["<uuid>"]}
+//	    Start receiving the given topics. feed_ids limits posts and feed
+//	    health to some of the user's followed feeds; omit it (or send an
+//	    empty list) for all of them. Subscribing to unread_counts sends the
+//	    current counts straight away.
+//	{"type":"unsubscribe","id":"2","topics":["feed_health"]}
+//	{"type":"mark_read","id":"3","post_id":"<uuid>"}
+//	    Also mark_unread, star and unstar. Needs the posts:write scope;
+//	    connecting needs posts:read.
+//	{"type":"ping","id":"4"}
+//
+// Server to client:
+//
+//	{"type":"ack","id":"3"}
+//	{"type":"error","id":"3","error":"post not found"}
+//	{"type":"pong","id":"4"}
+//	{"type":"heartbeat"}
+//	    Sent every 30s so clients can detect a dead connection.
+//	{"type":"post","post":{...}}
+//	    Topic posts. A new post from a followed feed, shaped like the
+//	    posts of GET /v1/me/posts.
+//	{"type":"post_state","post_id":"<uuid>","read":true,"starred":false,"hidden":false}
+//	    Topic posts. The user changed a post's state, from any connection
+//	    or over HTTP.
+//	{"type":"unread_counts","total":12,"feeds":{"<feed uuid>":3}}
+//	    Topic unread_counts. Sent whenever the counts may have changed;
+//	    bursts are coalesced.
+//	{"type":"feed_health","feed_id":"<uuid>","healthy":false,"error":"...","error_at":"...","failures":3}
+//	    Topic feed_health. A followed feed started failing or recovered.
+//
+// Backpressure: each connection has a bounded send queue. A client that
+// can't keep up is sent {"type":"error","error":"slow consumer"} and
+// disconnected; it should reconnect and reload its state over HTTP.
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "golang.org/x/net/websocket"
+
+    "github.com/mdbailin/go-rss-server/internal/auth"
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/stream"
+)
+
+const (
+    wsMaxMessageSize   = 64 << 10
+    wsSendBuffer       = 64
+    wsWriteTimeout     = 10 * time.Second
+    wsHeartbeat        = 30 * time.Second
+    wsCountsCoalescing = 500 * time.Millisecond
+)
+
+// Subscription topics.
+const (
+    wsTopicPosts        = "posts"
+    wsTopicUnreadCounts = "unread_counts"
+    wsTopicFeedHealth   = "feed_health"
+)
+
+// wsCommands maps post state commands to post state actions.
+var wsCommands = map[string]string{
+    "mark_read":   postStateRead,
+    "mark_unread": postStateUnread,
+    "star":        postStateStarred,
+    "unstar":      postStateUnstarred,
+}
+
+// wsRequest is any message a client sends.
+type wsRequest struct {
+    Type    string      `json:"type"`
+    ID      string      `json:"id,omitempty"`
+    Topics  []string    `json:"topics,omitempty"`
+    FeedIDs []uuid.UUID `json:"feed_ids,omitempty"`
+    PostID  *uuid.UUID  `json:"post_id,omitempty"`
+}
+
+type wsReply struct {
+    Type  string `json:"type"`
+    ID    string `json:"id,omitempty"`
+    Error string `json:"error,omitempty"`
+}
+
+type wsPost struct {
+    Type string       `json:"type"`
+    Post TimelinePost `json:"post"`
+}
+
+type wsPostState struct {
+    Type    string    `json:"type"`
+    PostID  uuid.UUID `json:"post_id"`
+    Read    bool      `json:"read"`
+    Starred bool      `json:"starred"`
+    Hidden  bool      `json:"hidden"`
+}
+
+type wsUnreadCounts struct {
+    Type  string              `json:"type"`
+    Total int64               `json:"total"`
+    Feeds map[uuid.UUID]int64 `json:"feeds"`
+}
+
+type wsFeedHealth struct {
+    Type     string     `json:"type"`
+    FeedID   uuid.UUID  `json:"feed_id"`
+    Healthy  bool       `json:"healthy"`
+    Error    *string    `json:"error"`
+    ErrorAt  *time.Time `json:"error_at"`
+    Failures int32      `json:"failures"`
+}
+
+// wsClient is one WebSocket connection and its subscriptions.
+type wsClient struct {
+    cfg    *apiConfig
+    user   database.User
+    scopes []string
+    ws     *websocket.Conn
+
+    ctx    context.Context
+    cancel context.CancelFunc
+
+    // out is the send queue, drained by writeLoop, the only goroutine that
+    // writes to ws.
+    out chan any
+
+    // countsDirty asks countsLoop to resend unread counts.
+    countsDirty chan struct{}
+
+    mu          sync.Mutex
+    topics      map[string]bool
+    feeds       map[uuid.UUID]bool // nil means every followed feed
+    closeReason string
+}
+
+// handleWebSocket upgrades the request and serves the protocol described
+// at the top of this file.
+func (cfg *apiConfig) handleWebSocket(w http.ResponseWriter, r *http.Request, user database.User) {
+    scopes := auth.ScopesFromContext(r.Context())
+    server := websocket.Server{
+        Handler: func(ws *websocket.Conn) {
+            cfg.serveWebSocket(ws, user, scopes)
+        },
+    }
+
+    // An API key can't be sent by another site, so any Origin is fine. A
+    // session cookie can, and WebSockets aren't covered by CORS or the
+    // CSRF check, so cookie-authenticated connections must be same-origin.
+    if _, ok := auth.SessionFromContext(r.Context()); ok {
+        server.Handshake = func(config *websocket.Config, req *http.Request) error {
+            if config.Origin == nil || config.Origin.Host != req.Host {
+                return errors.New("cross-origin websocket not allowed")
+            }
+            return nil
+        }
+    }
+
+    server.ServeHTTP(w, r)
+}
+
+func (cfg *apiConfig) serveWebSocket(ws *websocket.Conn, user database.User, scopes []string) {
+    ws.MaxPayloadBytes = wsMaxMessageSize
+
+    ctx, cancel := context.WithCancel(context.Background())
+    c := &wsClient{
+        cfg:         cfg,
+        user:        user,
+        scopes:      scopes,
+        ws:          ws,
+        ctx:         ctx,
+        cancel:      cancel,
+        out:         make(chan any, wsSendBuffer),
+        countsDirty: make(chan struct{}, 1),
+        topics:      map[string]bool{},
+    }
+
+    events, unsubscribe := cfg.Stream.Subscribe()
+    defer unsubscribe()
+
+    var wg sync.WaitGroup
+    wg.Add(3)
+    go func() { defer wg.Done(); c.writeLoop() }()
+    go func() { defer wg.Done(); c.eventLoop(events) }()
+    go func() { defer wg.Done(); c.countsLoop() }()
+
+    c.readLoop()
+    c.close("")
+    wg.Wait()
+}
+
+// close ends the connection. A non-empty reason is sent to the client as a
+// final error message.
+func (c *wsClient) close(reason string) {
+    c.mu.Lock()
+    if c.ctx.Err() == nil && c.closeReason == "" {
+        c.closeReason = reason
+    }
+    c.mu.Unlock()
+    c.cancel()
+}
+
+// send queues a message without blocking; a full queue means the client is
+// too slow and gets disconnected.
+func (c *wsClient) send(msg any) {
+    select {
+    case c.out <- msg:
+    default:
+        c.close("slow consumer")
+    }
+}
+
+func (c *wsClient) writeLoop() {
+    heartbeat := time.NewTicker(wsHeartbeat)
+    defer heartbeat.Stop()
+
+    write := func(msg any) {
+        c.ws.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+        if err := websocket.JSON.Send(c.ws, msg); err != nil {
+            c.close("")
+        }
+    }
+
+    for {
+        select {
+        case <-c.ctx.Done():
+            c.mu.Lock()
+            reason := c.closeReason
+            c.mu.Unlock()
+            if reason != "" {
+                write(wsReply{Type: "error", Error: reason})
+            }
+            // Unblocks readLoop.
+            c.ws.Close()
+            return
+
+        case msg := <-c.out:
+            write(msg)
+
+        case <-heartbeat.C:
+            write(wsReply{Type: "heartbeat"})
+        }
+    }
+}
+
+func (c *wsClient) readLoop() {
+    for {
+        var data []byte
+        if err := websocket.Message.Receive(c.ws, &data); err != nil {
+            return
+        }
+
+        var req wsRequest
+        if err := json.Unmarshal(data, &req); err != nil {
+            c.send(wsReply{Type: "error", Error: "invalid JSON"})
+            continue
+        }
+        c.handleRequest(req)
+    }
+}
+
+func (c *wsClient) handleRequest(req wsRequest) {
+    reply := func(err string) {
+        if err != "" {
+            c.send(wsReply{Type: "error", ID: req.ID, Error: err})
+            return
+        }
+        c.send(wsReply{Type: "ack", ID: req.ID})
+    }
+
+    switch req.Type {
+    case "ping":
+        c.send(wsReply{Type: "pong", ID: req.ID})
+
+    case "subscribe":
+        for _, t := range req.Topics {
+            if t != wsTopicPosts && t != wsTopicUnreadCounts && t != wsTopicFeedHealth {
+                reply("unknown topic " + t)
+                return
+            }
+        }
+        c.mu.Lock()
+        sendCounts := false
+        for _, t := range req.Topics {
+            sendCounts = sendCounts || (t == wsTopicUnreadCounts && !c.topics[t])
+            c.topics[t] = true
+        }
+        if req.FeedIDs != nil {
+            c.feeds = nil
+            if len(req.FeedIDs) > 0 {
+                c.feeds = map[uuid.UUID]bool{}
+                for _, id := range req.FeedIDs {
+                    c.feeds[id] = true
+                }
+            }
+        }
+        c.mu.Unlock()
+        reply("")
+        if sendCounts {
+            c.markCountsDirty()
+        }
+
+    case "unsubscribe":
+        c.mu.Lock()
+        for _, t := range req.Topics {
+            delete(c.topics, t)
+        }
+        c.mu.Unlock()
+        reply("")
+
+    default:
+        action, ok := wsCommands[req.Type]
+        if !ok {
+            reply("unknown message type " + req.Type)
+            return
+        }
+        if !auth.HasScope(c.scopes, auth.ScopePostsWrite) {
+            reply("api key is missing scope " + auth.ScopePostsWrite)
+            return
+        }
+        if req.PostID == nil {
+            reply("post_id is required")
+            return
+        }
+        if _, err := c.cfg.DB.GetPost(c.ctx, *req.PostID); err != nil {
+            reply("post not found")
+            return
+        }
+        if err := c.cfg.setPostState(c.ctx, c.user.ID, *req.PostID, action); err != nil {
+            reply("could not update post state")
+            return
+        }
+        reply("")
+    }
+}
+
+// subscribed reports whether the client wants a topic, and for feed-bound
+// events whether it wants that feed.
+func (c *wsClient) subscribed(topic string, feedID uuid.UUID) bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if !c.topics[topic] {
+        return false
+    }
+    return feedID == uuid.Nil || c.feeds == nil || c.feeds[feedID]
+}
+
+// subscribedToFeed reports whether the client named feedID when it
+// subscribed, rather than subscribing to every feed.
+func (c *wsClient) subscribedToFeed(feedID uuid.UUID) bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.feeds[feedID]
+}
+
+func (c *wsClient) eventLoop(events <-chan stream.Event) {
+    for {
+        select {
+        case <-c.ctx.Done():
+            return
+
+        case ev, ok := <-events:
+            if !ok {
+                c.close("slow consumer")
+                return
+            }
+            c.handleEvent(ev)
+        }
+    }
+}
+
+func (c *wsClient) handleEvent(ev stream.Event) {
+    switch ev.Type {
+    case stream.EventPost:
+        wantPost := c.subscribed(wsTopicPosts, ev.FeedID)
+        wantCounts := c.subscribed(wsTopicUnreadCounts, uuid.Nil)
+        if !wantPost && !wantCounts {
+            return
+        }
+
+        row, err := c.cfg.DB.GetTimelinePostForUser(c.ctx, database.GetTimelinePostForUserParams{
+            UserID: c.user.ID,
+            PostID: ev.PostID,
+        })
+        if err != nil {
+            // Not from a followed feed, or hidden by a rule.
+            return
+        }
+        // Feeds hidden from the timeline only send posts to clients that
+        // subscribed to them by id.
+        if row.HideFromTimeline && !c.subscribedToFeed(ev.FeedID) {
+            wantPost = false
+        }
+        if wantPost {
+            c.send(wsPost{Type: "post", Post: streamPost(row)})
+        }
+        if wantCounts {
+            c.markCountsDirty()
+        }
+
+    case stream.EventPostState:
+        if ev.UserID != c.user.ID {
+            return
+        }
+        if c.subscribed(wsTopicPosts, uuid.Nil) {
+            state, err := c.cfg.DB.GetPostState(c.ctx, database.GetPostStateParams{
+                UserID: c.user.ID,
+                PostID: ev.PostID,
+            })
+            if err == nil {
+                c.send(wsPostState{
+                    Type:    "post_state",
+                    PostID:  ev.PostID,
+                    Read:    state.ReadAt.Valid,
+                    Starred: state.StarredAt.Valid,
+                    Hidden:  state.HiddenAt.Valid,
+                })
+            }
+        }
+        if c.subscribed(wsTopicUnreadCounts, uuid.Nil) {
+            c.markCountsDirty()
+        }
+
+    case stream.EventFeedHealth:
+        if !c.subscribed(wsTopicFeedHealth, ev.FeedID) {
+            return
+        }
+        if _, err := c.cfg.DB.GetFeedFollowForFeed(c.ctx, database.GetFeedFollowForFeedParams{
+            UserID: c.user.ID,
+            FeedID: ev.FeedID,
+        }); err != nil {
+            return
+        }
+        feed, err := c.cfg.DB.GetFeed(c.ctx, ev.FeedID)
+        if err != nil {
+            return
+        }
+        c.send(wsFeedHealth{
+            Type:     "feed_health",
+            FeedID:   feed.ID,
+            Healthy:  feed.FetchFailures == 0,
+            Error:    nullStringPtr(feed.FetchError),
+            ErrorAt:  nullTimePtr(feed.FetchErrorAt),
+            Failures: feed.FetchFailures,
+        })
+    }
+}
+
+func (c *wsClient) markCountsDirty() {
+    select {
+    case c.countsDirty <- struct{}{}:
+    default:
+    }
+}
+
+// countsLoop sends unread counts when asked, waiting briefly first so a
+// burst of new posts or state changes results in one message.
+func (c *wsClient) countsLoop() {
+    for {
+        select {
+        case <-c.ctx.Done():
+            return
+        case <-c.countsDirty:
+        }
+
+        select {
+        case <-c.ctx.Done():
+            return
+        case <-time.After(wsCountsCoalescing):
+        }
+        select {
+        case <-c.countsDirty:
+        default:
+        }
+
+        rows, err := c.cfg.DB.GetUnreadCountsForUser(c.ctx, c.user.ID)
+        if err != nil {
+            continue
+        }
+        msg := wsUnreadCounts{Type: "unread_counts", Feeds: map[uuid.UUID]int64{}}
+        for _, row := range rows {
+            msg.Feeds[row.FeedID] = row.Unread
+            msg.Total += row.Unread
+        }
+        c.send(msg)
+    }
+}
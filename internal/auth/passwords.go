@@ -0,0 +1,53 @@
+package auth
+
+import (
+    "fmt"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Password length limits. bcrypt ignores everything past 72 bytes, so
+// longer passwords are refused rather than silently truncated.
+const (
+    MinPasswordLength = 8
+    MaxPasswordLength = 72
+)
+
+const passwordCost = 12
+
+// dummyHash is compared against when a login names no known user, so that
+// case takes as long as a wrong password.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), passwordCost)
+
+// ValidatePassword checks a new password against the length limits.
+func ValidatePassword(password string) error {
+    if len(password) < MinPasswordLength {
+        return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+    }
+    if len(password) > MaxPasswordLength {
+        return fmt.Errorf("password must be at most %d bytes", MaxPasswordLength)
+    }
+    return nil
+}
+
+// HashPassword returns the bcrypt hash to store for password.
+func HashPassword(password string) (string, error) {
+    if err := ValidatePassword(password); err != nil {
+        return "", err
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+    if err != nil {
+        return "", err
+    }
+    return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash. An empty hash (no
+// such user, or no password set) never matches but takes just as long.
+func CheckPassword(hash, password string) bool {
+    if hash == "" {
+        bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+        return false
+    }
+    return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
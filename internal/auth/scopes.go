@@ -0,0 +1,74 @@
+package auth
+
+import "context"
+
+// Scopes limit what an API key may do.
+const (
+    // ScopePostsRead covers reading: timelines, search, streams, and
+    // listing feeds, follows, folders and the user's other settings.
+    ScopePostsRead = "posts:read"
+
+    // ScopePostsWrite covers marking posts read, starred or hidden, and
+    // notifications read.
+    ScopePostsWrite = "posts:write"
+
+    // ScopeFeedsWrite covers adding and previewing feeds.
+    ScopeFeedsWrite = "feeds:write"
+
+    // ScopeFollowsWrite covers following and unfollowing feeds and
+    // organising follows into folders.
+    ScopeFollowsWrite = "follows:write"
+
+    // ScopeSettingsWrite covers saved searches, filter rules, output feeds
+    // and webhooks.
+    ScopeSettingsWrite = "settings:write"
+
+    // ScopeAccount covers managing API keys and the account itself, and
+    // implies every other scope. It has nothing to do with being an admin
+    // of the instance, which is a property of the user, not the key.
+    ScopeAccount = "account"
+)
+
+// AllScopes lists every scope, in the order they are documented.
+var AllScopes = []string{
+    ScopePostsRead,
+    ScopePostsWrite,
+    ScopeFeedsWrite,
+    ScopeFollowsWrite,
+    ScopeSettingsWrite,
+    ScopeAccount,
+}
+
+// ValidScope reports whether s is a known scope.
+func ValidScope(s string) bool {
+    for _, scope := range AllScopes {
+        if s == scope {
+            return true
+        }
+    }
+    return false
+}
+
+// HasScope reports whether granted allows want.
+func HasScope(granted []string, want string) bool {
+    for _, s := range granted {
+        if s == want || s == ScopeAccount {
+            return true
+        }
+    }
+    return false
+}
+
+type scopesKey struct{}
+
+// WithScopes returns a copy of ctx carrying the scopes the request was
+// authenticated with.
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+    return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// ScopesFromContext returns the scopes stored by WithScopes, or nil.
+func ScopesFromContext(ctx context.Context) []string {
+    scopes, _ := ctx.Value(scopesKey{}).([]string)
+    return scopes
+}
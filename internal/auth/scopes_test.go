@@ -0,0 +1,59 @@
+package auth
+
+import (
+    "context"
+    "testing"
+)
+
+func TestHasScope(t *testing.T) {
+    tests := []struct {
+        name    string
+        granted []string
+        want    string
+        ok      bool
+    }{
+        {"exact scope", []string{ScopePostsRead}, ScopePostsRead, true},
+        {"one of several", []string{ScopePostsRead, ScopeFeedsWrite}, ScopeFeedsWrite, true},
+        {"read does not imply write", []string{ScopePostsRead}, ScopePostsWrite, false},
+        {"write does not imply read", []string{ScopePostsWrite}, ScopePostsRead, false},
+        {"account implies read", []string{ScopeAccount}, ScopePostsRead, true},
+        {"account implies settings", []string{ScopeAccount}, ScopeSettingsWrite, true},
+        {"account implies itself", []string{ScopeAccount}, ScopeAccount, true},
+        {"nothing else implies account", []string{ScopePostsRead, ScopePostsWrite, ScopeFeedsWrite, ScopeFollowsWrite, ScopeSettingsWrite}, ScopeAccount, false},
+        {"old admin scope grants nothing", []string{"admin"}, ScopePostsRead, false},
+        {"no scopes", nil, ScopePostsRead, false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := HasScope(tt.granted, tt.want); got != tt.ok {
+                t.Errorf("HasScope(%v, %q) = %v, want %v", tt.granted, tt.want, got, tt.ok)
+            }
+        })
+    }
+}
+
+func TestValidScope(t *testing.T) {
+    for _, s := range AllScopes {
+        if !ValidScope(s) {
+            t.Errorf("ValidScope(%q) = false", s)
+        }
+    }
+    for _, s := range []string{"", "admin", "posts", "posts:delete", "POSTS:READ"} {
+        if ValidScope(s) {
+            t.Errorf("ValidScope(%q) = true", s)
+        }
+    }
+}
+
+func TestScopesFromContext(t *testing.T) {
+    if got := ScopesFromContext(context.Background()); got != nil {
+        t.Errorf("empty context: got %v, want nil", got)
+    }
+
+    ctx := WithScopes(context.Background(), []string{ScopePostsRead})
+    got := ScopesFromContext(ctx)
+    if len(got) != 1 || got[0] != ScopePostsRead {
+        t.Errorf("got %v, want [%s]", got, ScopePostsRead)
+    }
+}
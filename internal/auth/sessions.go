@@ -0,0 +1,111 @@
+package auth
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/hex"
+    "net/http"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+// Session cookies and headers.
+const (
+    // SessionCookie holds the session token. It is HttpOnly, so scripts
+    // can't read it.
+    SessionCookie = "rss_session"
+
+    // CSRFCookie holds the session's CSRF token for scripts to read and
+    // send back in CSRFHeader on requests that change something.
+    CSRFCookie = "rss_csrf"
+    CSRFHeader = "X-CSRF-Token"
+)
+
+// Session lifetimes. A session lasts SessionTTL from its last rotation;
+// using it after SessionRotateAfter issues a new token. The old token
+// keeps working for SessionRotateGrace.
+const (
+    SessionTTL         = 30 * 24 * time.Hour
+    SessionRotateAfter = 24 * time.Hour
+    SessionRotateGrace = time.Minute
+)
+
+// NewToken returns a random token and the hash to store for it.
+func NewToken() (token, hash string, err error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", "", err
+    }
+    token = hex.EncodeToString(b)
+    return token, HashToken(token), nil
+}
+
+// CheckCSRF reports whether the request's CSRF header matches the
+// session's token, in constant time.
+func CheckCSRF(r *http.Request, csrfToken string) bool {
+    got := r.Header.Get(CSRFHeader)
+    return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(csrfToken)) == 1
+}
+
+// SafeMethod reports whether a request method only reads, and so needs no
+// CSRF token.
+func SafeMethod(method string) bool {
+    switch method {
+    case http.MethodGet, http.MethodHead, http.MethodOptions:
+        return true
+    }
+    return false
+}
+
+// SetSessionCookies sets the session and CSRF cookies. secure should be
+// true whenever the client reached us over HTTPS.
+func SetSessionCookies(w http.ResponseWriter, token, csrfToken string, expires time.Time, secure bool) {
+    http.SetCookie(w, &http.Cookie{
+        Name:     SessionCookie,
+        Value:    token,
+        Path:     "/",
+        Expires:  expires,
+        HttpOnly: true,
+        Secure:   secure,
+        SameSite: http.SameSiteLaxMode,
+    })
+    http.SetCookie(w, &http.Cookie{
+        Name:     CSRFCookie,
+        Value:    csrfToken,
+        Path:     "/",
+        Expires:  expires,
+        Secure:   secure,
+        SameSite: http.SameSiteLaxMode,
+    })
+}
+
+// ClearSessionCookies removes the session and CSRF cookies.
+func ClearSessionCookies(w http.ResponseWriter, secure bool) {
+    for _, name := range []string{SessionCookie, CSRFCookie} {
+        http.SetCookie(w, &http.Cookie{
+            Name:     name,
+            Value:    "",
+            Path:     "/",
+            MaxAge:   -1,
+            HttpOnly: name == SessionCookie,
+            Secure:   secure,
+            SameSite: http.SameSiteLaxMode,
+        })
+    }
+}
+
+type sessionKey struct{}
+
+// WithSession returns a copy of ctx recording that the request was
+// authenticated by the given session rather than an API key.
+func WithSession(ctx context.Context, id uuid.UUID) context.Context {
+    return context.WithValue(ctx, sessionKey{}, id)
+}
+
+// SessionFromContext returns the session stored by WithSession.
+func SessionFromContext(ctx context.Context) (uuid.UUID, bool) {
+    id, ok := ctx.Value(sessionKey{}).(uuid.UUID)
+    return id, ok
+}
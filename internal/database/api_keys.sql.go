@@ -0,0 +1,245 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: api_keys.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createAPIKey = `-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, updated_at, user_id, name, prefix, key_hash, scopes, expires_at)
+VALUES ($1, $2, $2, $3, $4, $5, $6, $7::text[], $8)
+RETURNING id, created_at, updated_at, user_id, name, prefix, key_hash, last_used_at, expires_at, scopes
+`
+
+type CreateAPIKeyParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	Prefix    string
+	KeyHash   string
+	Scopes    []string
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, createAPIKey,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UserID,
+		arg.Name,
+		arg.Prefix,
+		arg.KeyHash,
+		pq.Array(arg.Scopes),
+		arg.ExpiresAt,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		pq.Array(&i.Scopes),
+	)
+	return i, err
+}
+
+const deleteAPIKey = `-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteAPIKeyParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteAPIKey(ctx context.Context, arg DeleteAPIKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAPIKey, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAPIKeysByPrefix = `-- name: GetAPIKeysByPrefix :many
+SELECT k.id, k.created_at, k.updated_at, k.user_id, k.name, k.prefix, k.key_hash, k.last_used_at, k.expires_at, k.scopes, u.id, u.created_at, u.updated_at, u.name, u.username, u.password_hash, u.is_admin, u.disabled_at
+FROM api_keys k
+JOIN users u ON u.id = k.user_id
+WHERE k.prefix = $1
+  AND u.disabled_at IS NULL
+  AND (k.expires_at IS NULL OR k.expires_at > $2::timestamp)
+`
+
+type GetAPIKeysByPrefixParams struct {
+	Prefix string
+	Now    time.Time
+}
+
+type GetAPIKeysByPrefixRow struct {
+	ApiKey ApiKey
+	User   User
+}
+
+// Unexpired keys sharing a prefix, with their users, leaving out disabled
+// users. The caller compares hashes to find the right one.
+func (q *Queries) GetAPIKeysByPrefix(ctx context.Context, arg GetAPIKeysByPrefixParams) ([]GetAPIKeysByPrefixRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAPIKeysByPrefix, arg.Prefix, arg.Now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAPIKeysByPrefixRow
+	for rows.Next() {
+		var i GetAPIKeysByPrefixRow
+		if err := rows.Scan(
+			&i.ApiKey.ID,
+			&i.ApiKey.CreatedAt,
+			&i.ApiKey.UpdatedAt,
+			&i.ApiKey.UserID,
+			&i.ApiKey.Name,
+			&i.ApiKey.Prefix,
+			&i.ApiKey.KeyHash,
+			&i.ApiKey.LastUsedAt,
+			&i.ApiKey.ExpiresAt,
+			pq.Array(&i.ApiKey.Scopes),
+			&i.User.ID,
+			&i.User.CreatedAt,
+			&i.User.UpdatedAt,
+			&i.User.Name,
+			&i.User.Username,
+			&i.User.PasswordHash,
+			&i.User.IsAdmin,
+			&i.User.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAPIKeysForUser = `-- name: GetAPIKeysForUser :many
+SELECT id, created_at, updated_at, user_id, name, prefix, key_hash, last_used_at, expires_at, scopes
+FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetAPIKeysForUser(ctx context.Context, userID uuid.UUID) ([]ApiKey, error) {
+	rows, err := q.db.QueryContext(ctx, getAPIKeysForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiKey
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.Prefix,
+			&i.KeyHash,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			pq.Array(&i.Scopes),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rotateAPIKey = `-- name: RotateAPIKey :one
+UPDATE api_keys
+SET prefix = $1,
+    key_hash = $2,
+    last_used_at = NULL,
+    updated_at = $3
+WHERE id = $4 AND user_id = $5
+RETURNING id, created_at, updated_at, user_id, name, prefix, key_hash, last_used_at, expires_at, scopes
+`
+
+type RotateAPIKeyParams struct {
+	Prefix    string
+	KeyHash   string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+	UserID    uuid.UUID
+}
+
+// Replaces a key with a new secret, keeping its id, name, scopes and
+// expiry. The old secret stops working immediately.
+func (q *Queries) RotateAPIKey(ctx context.Context, arg RotateAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, rotateAPIKey,
+		arg.Prefix,
+		arg.KeyHash,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.UserID,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		pq.Array(&i.Scopes),
+	)
+	return i, err
+}
+
+const touchAPIKey = `-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = $1::timestamp
+WHERE id = $2
+  AND (last_used_at IS NULL OR last_used_at < $3::timestamp)
+`
+
+type TouchAPIKeyParams struct {
+	Now         time.Time
+	ID          uuid.UUID
+	StaleBefore time.Time
+}
+
+// Only writes if the last recorded use is before @stale_before, so busy
+// keys do not write on every request.
+func (q *Queries) TouchAPIKey(ctx context.Context, arg TouchAPIKeyParams) error {
+	_, err := q.db.ExecContext(ctx, touchAPIKey, arg.Now, arg.ID, arg.StaleBefore)
+	return err
+}
@@ -7,15 +7,29 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const countFeedFollowsForUser = `-- name: CountFeedFollowsForUser :one
+SELECT COUNT(*)
+FROM feed_follows
+WHERE user_id = $1
+`
+
+func (q *Queries) CountFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countFeedFollowsForUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createFeedFollow = `-- name: CreateFeedFollow :one
-INSERT INTO feed_follows (id, created_at, updated_at, feed_id, user_id)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, created_at, updated_at, feed_id, user_id
+INSERT INTO feed_follows (id, created_at, updated_at, feed_id, user_id, title)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, updated_at, feed_id, user_id, folder_id, title, color, priority, hide_from_timeline, notify
 `
 
 type CreateFeedFollowParams struct {
@@ -24,6 +38,7 @@ type CreateFeedFollowParams struct {
 	UpdatedAt time.Time
 	FeedID    uuid.UUID
 	UserID    uuid.UUID
+	Title     sql.NullString
 }
 
 func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error) {
@@ -33,6 +48,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		arg.UpdatedAt,
 		arg.FeedID,
 		arg.UserID,
+		arg.Title,
 	)
 	var i FeedFollow
 	err := row.Scan(
@@ -41,6 +57,12 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.FeedID,
 		&i.UserID,
+		&i.FolderID,
+		&i.Title,
+		&i.Color,
+		&i.Priority,
+		&i.HideFromTimeline,
+		&i.Notify,
 	)
 	return i, err
 }
@@ -60,28 +82,121 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 	return err
 }
 
-const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
-SELECT id, created_at, updated_at, feed_id, user_id
+const getFeedFollow = `-- name: GetFeedFollow :one
+SELECT id, created_at, updated_at, feed_id, user_id, folder_id, title, color, priority, hide_from_timeline, notify
 FROM feed_follows
-WHERE user_id = $1
-ORDER BY created_at DESC
+WHERE id = $1 AND user_id = $2
+`
+
+type GetFeedFollowParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetFeedFollow(ctx context.Context, arg GetFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollow, arg.ID, arg.UserID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.UserID,
+		&i.FolderID,
+		&i.Title,
+		&i.Color,
+		&i.Priority,
+		&i.HideFromTimeline,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const getFeedFollowForFeed = `-- name: GetFeedFollowForFeed :one
+SELECT id, created_at, updated_at, feed_id, user_id, folder_id, title, color, priority, hide_from_timeline, notify
+FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type GetFeedFollowForFeedParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) GetFeedFollowForFeed(ctx context.Context, arg GetFeedFollowForFeedParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollowForFeed, arg.UserID, arg.FeedID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.UserID,
+		&i.FolderID,
+		&i.Title,
+		&i.Color,
+		&i.Priority,
+		&i.HideFromTimeline,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
+SELECT ff.id, ff.created_at, ff.updated_at, ff.feed_id, ff.user_id, ff.folder_id, ff.title, ff.color, ff.priority, ff.hide_from_timeline, ff.notify, f.id, f.created_at, f.updated_at, f.name, f.url, f.added_by, f.last_fetched_at, f.title, f.site_url, f.description, f.image_url, f.language, f.generator, f.last_build_date, f.icon_checked_at, f.fetch_error, f.fetch_error_at, f.fetch_failures, f.follower_count, f.deactivated_at
+FROM feed_follows ff
+JOIN feeds f ON f.id = ff.feed_id
+WHERE ff.user_id = $1
+ORDER BY ff.priority DESC, ff.created_at DESC
 `
 
-func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]FeedFollow, error) {
+type GetFeedFollowsForUserRow struct {
+	FeedFollow FeedFollow
+	Feed       Feed
+}
+
+// The user's follows with their feeds, highest priority first.
+func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
 	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []FeedFollow
+	var items []GetFeedFollowsForUserRow
 	for rows.Next() {
-		var i FeedFollow
+		var i GetFeedFollowsForUserRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.FeedID,
-			&i.UserID,
+			&i.FeedFollow.ID,
+			&i.FeedFollow.CreatedAt,
+			&i.FeedFollow.UpdatedAt,
+			&i.FeedFollow.FeedID,
+			&i.FeedFollow.UserID,
+			&i.FeedFollow.FolderID,
+			&i.FeedFollow.Title,
+			&i.FeedFollow.Color,
+			&i.FeedFollow.Priority,
+			&i.FeedFollow.HideFromTimeline,
+			&i.FeedFollow.Notify,
+			&i.Feed.ID,
+			&i.Feed.CreatedAt,
+			&i.Feed.UpdatedAt,
+			&i.Feed.Name,
+			&i.Feed.Url,
+			&i.Feed.AddedBy,
+			&i.Feed.LastFetchedAt,
+			&i.Feed.Title,
+			&i.Feed.SiteUrl,
+			&i.Feed.Description,
+			&i.Feed.ImageUrl,
+			&i.Feed.Language,
+			&i.Feed.Generator,
+			&i.Feed.LastBuildDate,
+			&i.Feed.IconCheckedAt,
+			&i.Feed.FetchError,
+			&i.Feed.FetchErrorAt,
+			&i.Feed.FetchFailures,
+			&i.Feed.FollowerCount,
+			&i.Feed.DeactivatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -95,3 +210,92 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const setFeedFollowFolder = `-- name: SetFeedFollowFolder :one
+UPDATE feed_follows
+SET folder_id = $3, updated_at = $4
+WHERE id = $1 AND user_id = $2
+RETURNING id, created_at, updated_at, feed_id, user_id, folder_id, title, color, priority, hide_from_timeline, notify
+`
+
+type SetFeedFollowFolderParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	FolderID  uuid.NullUUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedFollowFolder(ctx context.Context, arg SetFeedFollowFolderParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFollowFolder,
+		arg.ID,
+		arg.UserID,
+		arg.FolderID,
+		arg.UpdatedAt,
+	)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.UserID,
+		&i.FolderID,
+		&i.Title,
+		&i.Color,
+		&i.Priority,
+		&i.HideFromTimeline,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const updateFeedFollowSettings = `-- name: UpdateFeedFollowSettings :one
+UPDATE feed_follows
+SET title = $1,
+    color = $2,
+    priority = $3,
+    hide_from_timeline = $4,
+    notify = $5,
+    updated_at = $6
+WHERE id = $7 AND user_id = $8
+RETURNING id, created_at, updated_at, feed_id, user_id, folder_id, title, color, priority, hide_from_timeline, notify
+`
+
+type UpdateFeedFollowSettingsParams struct {
+	Title            sql.NullString
+	Color            sql.NullString
+	Priority         int32
+	HideFromTimeline bool
+	Notify           bool
+	UpdatedAt        time.Time
+	ID               uuid.UUID
+	UserID           uuid.UUID
+}
+
+func (q *Queries) UpdateFeedFollowSettings(ctx context.Context, arg UpdateFeedFollowSettingsParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedFollowSettings,
+		arg.Title,
+		arg.Color,
+		arg.Priority,
+		arg.HideFromTimeline,
+		arg.Notify,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.UserID,
+	)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.UserID,
+		&i.FolderID,
+		&i.Title,
+		&i.Color,
+		&i.Priority,
+		&i.HideFromTimeline,
+		&i.Notify,
+	)
+	return i, err
+}
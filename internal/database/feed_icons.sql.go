@@ -0,0 +1,68 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: feed_icons.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getFeedIcon = `-- name: GetFeedIcon :one
+SELECT feed_id, created_at, updated_at, url, content_type, data, hash
+FROM feed_icons
+WHERE feed_id = $1
+`
+
+func (q *Queries) GetFeedIcon(ctx context.Context, feedID uuid.UUID) (FeedIcon, error) {
+	row := q.db.QueryRowContext(ctx, getFeedIcon, feedID)
+	var i FeedIcon
+	err := row.Scan(
+		&i.FeedID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Url,
+		&i.ContentType,
+		&i.Data,
+		&i.Hash,
+	)
+	return i, err
+}
+
+const upsertFeedIcon = `-- name: UpsertFeedIcon :exec
+INSERT INTO feed_icons (feed_id, created_at, updated_at, url, content_type, data, hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (feed_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    url = EXCLUDED.url,
+    content_type = EXCLUDED.content_type,
+    data = EXCLUDED.data,
+    hash = EXCLUDED.hash
+`
+
+type UpsertFeedIconParams struct {
+	FeedID      uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Url         string
+	ContentType string
+	Data        []byte
+	Hash        string
+}
+
+func (q *Queries) UpsertFeedIcon(ctx context.Context, arg UpsertFeedIconParams) error {
+	_, err := q.db.ExecContext(ctx, upsertFeedIcon,
+		arg.FeedID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Url,
+		arg.ContentType,
+		arg.Data,
+		arg.Hash,
+	)
+	return err
+}
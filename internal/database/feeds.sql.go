@@ -7,15 +7,45 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const clearFeedFetchError = `-- name: ClearFeedFetchError :execrows
+UPDATE feeds
+SET fetch_error = NULL,
+    fetch_error_at = NULL,
+    fetch_failures = 0
+WHERE id = $1 AND fetch_failures > 0
+`
+
+func (q *Queries) ClearFeedFetchError(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, clearFeedFetchError, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countFeedsAddedByUser = `-- name: CountFeedsAddedByUser :one
+SELECT COUNT(*)
+FROM feeds
+WHERE added_by = $1
+`
+
+func (q *Queries) CountFeedsAddedByUser(ctx context.Context, addedBy uuid.NullUUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countFeedsAddedByUser, addedBy)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, added_by)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, added_by, last_fetched_at, title, site_url, description, image_url, language, generator, last_build_date, icon_checked_at, fetch_error, fetch_error_at, fetch_failures, follower_count, deactivated_at
 `
 
 type CreateFeedParams struct {
@@ -24,7 +54,7 @@ type CreateFeedParams struct {
 	UpdatedAt time.Time
 	Name      string
 	Url       string
-	UserID    uuid.UUID
+	AddedBy   uuid.NullUUID
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -34,8 +64,91 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.UpdatedAt,
 		arg.Name,
 		arg.Url,
-		arg.UserID,
+		arg.AddedBy,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.AddedBy,
+		&i.LastFetchedAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.ImageUrl,
+		&i.Language,
+		&i.Generator,
+		&i.LastBuildDate,
+		&i.IconCheckedAt,
+		&i.FetchError,
+		&i.FetchErrorAt,
+		&i.FetchFailures,
+		&i.FollowerCount,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const deleteInactiveFeeds = `-- name: DeleteInactiveFeeds :execrows
+DELETE FROM feeds
+WHERE deactivated_at < $1::timestamp
+`
+
+// Deletes feeds nobody has followed since @deactivated_before, with their
+// posts.
+func (q *Queries) DeleteInactiveFeeds(ctx context.Context, deactivatedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteInactiveFeeds, deactivatedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFeed = `-- name: GetFeed :one
+SELECT id, created_at, updated_at, name, url, added_by, last_fetched_at, title, site_url, description, image_url, language, generator, last_build_date, icon_checked_at, fetch_error, fetch_error_at, fetch_failures, follower_count, deactivated_at
+FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) GetFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeed, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.AddedBy,
+		&i.LastFetchedAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.ImageUrl,
+		&i.Language,
+		&i.Generator,
+		&i.LastBuildDate,
+		&i.IconCheckedAt,
+		&i.FetchError,
+		&i.FetchErrorAt,
+		&i.FetchFailures,
+		&i.FollowerCount,
+		&i.DeactivatedAt,
 	)
+	return i, err
+}
+
+const getFeedByURL = `-- name: GetFeedByURL :one
+SELECT id, created_at, updated_at, name, url, added_by, last_fetched_at, title, site_url, description, image_url, language, generator, last_build_date, icon_checked_at, fetch_error, fetch_error_at, fetch_failures, follower_count, deactivated_at
+FROM feeds
+WHERE url = $1
+`
+
+func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURL, url)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -43,14 +156,42 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.UpdatedAt,
 		&i.Name,
 		&i.Url,
-		&i.UserID,
+		&i.AddedBy,
 		&i.LastFetchedAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.ImageUrl,
+		&i.Language,
+		&i.Generator,
+		&i.LastBuildDate,
+		&i.IconCheckedAt,
+		&i.FetchError,
+		&i.FetchErrorAt,
+		&i.FetchFailures,
+		&i.FollowerCount,
+		&i.DeactivatedAt,
 	)
 	return i, err
 }
 
+const getFeedQueueLag = `-- name: GetFeedQueueLag :one
+SELECT COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(COALESCE(last_fetched_at, created_at))), 0)::float8 AS lag_seconds
+FROM feeds
+WHERE deactivated_at IS NULL
+`
+
+// Seconds since the least recently fetched active feed was last fetched, or
+// added if it never has been. Zero when there are no active feeds.
+func (q *Queries) GetFeedQueueLag(ctx context.Context) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getFeedQueueLag)
+	var lag_seconds float64
+	err := row.Scan(&lag_seconds)
+	return lag_seconds, err
+}
+
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, added_by, last_fetched_at, title, site_url, description, image_url, language, generator, last_build_date, icon_checked_at, fetch_error, fetch_error_at, fetch_failures, follower_count, deactivated_at
 FROM feeds
 ORDER BY created_at DESC
 `
@@ -70,8 +211,82 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.UpdatedAt,
 			&i.Name,
 			&i.Url,
-			&i.UserID,
+			&i.AddedBy,
+			&i.LastFetchedAt,
+			&i.Title,
+			&i.SiteUrl,
+			&i.Description,
+			&i.ImageUrl,
+			&i.Language,
+			&i.Generator,
+			&i.LastBuildDate,
+			&i.IconCheckedAt,
+			&i.FetchError,
+			&i.FetchErrorAt,
+			&i.FetchFailures,
+			&i.FollowerCount,
+			&i.DeactivatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsNeedingIcon = `-- name: GetFeedsNeedingIcon :many
+SELECT id, created_at, updated_at, name, url, added_by, last_fetched_at, title, site_url, description, image_url, language, generator, last_build_date, icon_checked_at, fetch_error, fetch_error_at, fetch_failures, follower_count, deactivated_at
+FROM feeds
+WHERE deactivated_at IS NULL
+  AND last_fetched_at IS NOT NULL
+  AND (icon_checked_at IS NULL OR icon_checked_at < $1)
+ORDER BY icon_checked_at IS NOT NULL, icon_checked_at, created_at
+LIMIT $2
+`
+
+type GetFeedsNeedingIconParams struct {
+	IconCheckedAt sql.NullTime
+	Limit         int32
+}
+
+// Feeds are only picked once fetched, since the first fetch fills in the
+// image and homepage the icon is found from.
+func (q *Queries) GetFeedsNeedingIcon(ctx context.Context, arg GetFeedsNeedingIconParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsNeedingIcon, arg.IconCheckedAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.AddedBy,
 			&i.LastFetchedAt,
+			&i.Title,
+			&i.SiteUrl,
+			&i.Description,
+			&i.ImageUrl,
+			&i.Language,
+			&i.Generator,
+			&i.LastBuildDate,
+			&i.IconCheckedAt,
+			&i.FetchError,
+			&i.FetchErrorAt,
+			&i.FetchFailures,
+			&i.FollowerCount,
+			&i.DeactivatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -87,12 +302,24 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, added_by, last_fetched_at, title, site_url, description, image_url, language, generator, last_build_date, icon_checked_at, fetch_error, fetch_error_at, fetch_failures, follower_count, deactivated_at
 FROM feeds
+WHERE deactivated_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM websub_subscriptions s
+    WHERE s.feed_id = feeds.id
+      AND s.state = 'active'
+      AND s.lease_expires_at > NOW()
+      AND feeds.last_fetched_at > NOW() - INTERVAL '6 hours'
+)
 ORDER BY last_fetched_at IS NOT NULL, last_fetched_at, created_at
 LIMIT $1
 `
 
+// Feeds nobody follows are skipped. Feeds with an active WebSub lease get
+// their content pushed, so they are only polled if nothing has arrived for
+// six hours.
 func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed, error) {
 	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, limit)
 	if err != nil {
@@ -108,8 +335,21 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 			&i.UpdatedAt,
 			&i.Name,
 			&i.Url,
-			&i.UserID,
+			&i.AddedBy,
 			&i.LastFetchedAt,
+			&i.Title,
+			&i.SiteUrl,
+			&i.Description,
+			&i.ImageUrl,
+			&i.Language,
+			&i.Generator,
+			&i.LastBuildDate,
+			&i.IconCheckedAt,
+			&i.FetchError,
+			&i.FetchErrorAt,
+			&i.FetchFailures,
+			&i.FollowerCount,
+			&i.DeactivatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -124,6 +364,47 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 	return items, nil
 }
 
+const getUnreadCountsForUser = `-- name: GetUnreadCountsForUser :many
+SELECT ff.feed_id, COUNT(*) AS unread
+FROM feed_follows ff
+JOIN posts p ON p.feed_id = ff.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1
+  AND ps.read_at IS NULL
+  AND ps.hidden_at IS NULL
+GROUP BY ff.feed_id
+`
+
+type GetUnreadCountsForUserRow struct {
+	FeedID uuid.UUID
+	Unread int64
+}
+
+// Unread, unhidden posts per followed feed. Feeds with nothing unread are
+// left out.
+func (q *Queries) GetUnreadCountsForUser(ctx context.Context, userID uuid.UUID) ([]GetUnreadCountsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnreadCountsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnreadCountsForUserRow
+	for rows.Next() {
+		var i GetUnreadCountsForUserRow
+		if err := rows.Scan(&i.FeedID, &i.Unread); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds
 SET last_fetched_at = NOW(), updated_at = NOW()
@@ -134,3 +415,78 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
 	return err
 }
+
+const markFeedIconChecked = `-- name: MarkFeedIconChecked :exec
+UPDATE feeds
+SET icon_checked_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkFeedIconChecked(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, markFeedIconChecked, id)
+	return err
+}
+
+const recordFeedFetchError = `-- name: RecordFeedFetchError :one
+UPDATE feeds
+SET fetch_error = $2,
+    fetch_error_at = NOW(),
+    fetch_failures = fetch_failures + 1,
+    last_fetched_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING fetch_failures
+`
+
+type RecordFeedFetchErrorParams struct {
+	ID         uuid.UUID
+	FetchError sql.NullString
+}
+
+// A failed fetch still counts as a fetch for scheduling, so feeds that keep
+// failing go to the back of the queue instead of heading every batch.
+func (q *Queries) RecordFeedFetchError(ctx context.Context, arg RecordFeedFetchErrorParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFetchError, arg.ID, arg.FetchError)
+	var fetch_failures int32
+	err := row.Scan(&fetch_failures)
+	return fetch_failures, err
+}
+
+const updateFeedMetadata = `-- name: UpdateFeedMetadata :exec
+UPDATE feeds
+SET title = $2,
+    site_url = $3,
+    description = $4,
+    image_url = $5,
+    language = $6,
+    generator = $7,
+    last_build_date = $8,
+    name = CASE WHEN name = '' THEN COALESCE($2, '') ELSE name END,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedMetadataParams struct {
+	ID            uuid.UUID
+	Title         sql.NullString
+	SiteUrl       sql.NullString
+	Description   sql.NullString
+	ImageUrl      sql.NullString
+	Language      sql.NullString
+	Generator     sql.NullString
+	LastBuildDate sql.NullTime
+}
+
+func (q *Queries) UpdateFeedMetadata(ctx context.Context, arg UpdateFeedMetadataParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedMetadata,
+		arg.ID,
+		arg.Title,
+		arg.SiteUrl,
+		arg.Description,
+		arg.ImageUrl,
+		arg.Language,
+		arg.Generator,
+		arg.LastBuildDate,
+	)
+	return err
+}
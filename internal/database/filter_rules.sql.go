@@ -0,0 +1,355 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: filter_rules.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createFilterRule = `-- name: CreateFilterRule :one
+INSERT INTO filter_rules (
+    id,
+    created_at,
+    updated_at,
+    user_id,
+    name,
+    folder_id,
+    feed_follow_id,
+    field,
+    match_type,
+    pattern,
+    action,
+    tag,
+    enabled
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+RETURNING id, created_at, updated_at, user_id, name, folder_id, feed_follow_id, field, match_type, pattern, action, tag, enabled
+`
+
+type CreateFilterRuleParams struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	FolderID     uuid.NullUUID
+	FeedFollowID uuid.NullUUID
+	Field        string
+	MatchType    string
+	Pattern      string
+	Action       string
+	Tag          string
+	Enabled      bool
+}
+
+func (q *Queries) CreateFilterRule(ctx context.Context, arg CreateFilterRuleParams) (FilterRule, error) {
+	row := q.db.QueryRowContext(ctx, createFilterRule,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Name,
+		arg.FolderID,
+		arg.FeedFollowID,
+		arg.Field,
+		arg.MatchType,
+		arg.Pattern,
+		arg.Action,
+		arg.Tag,
+		arg.Enabled,
+	)
+	var i FilterRule
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.FolderID,
+		&i.FeedFollowID,
+		&i.Field,
+		&i.MatchType,
+		&i.Pattern,
+		&i.Action,
+		&i.Tag,
+		&i.Enabled,
+	)
+	return i, err
+}
+
+const deleteFilterRule = `-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteFilterRuleParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteFilterRule(ctx context.Context, arg DeleteFilterRuleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFilterRule, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getActiveFilterRulesForFeed = `-- name: GetActiveFilterRulesForFeed :many
+SELECT r.id, r.created_at, r.updated_at, r.user_id, r.name, r.folder_id, r.feed_follow_id, r.field, r.match_type, r.pattern, r.action, r.tag, r.enabled
+FROM filter_rules r
+JOIN feed_follows ff ON ff.user_id = r.user_id AND ff.feed_id = $1
+WHERE r.enabled
+  AND (
+      (r.folder_id IS NULL AND r.feed_follow_id IS NULL)
+      OR r.folder_id = ff.folder_id
+      OR r.feed_follow_id = ff.id
+  )
+`
+
+// Enabled rules of every follower of the feed whose scope covers it: all
+// feeds, the folder the follow is in, or the follow itself.
+func (q *Queries) GetActiveFilterRulesForFeed(ctx context.Context, feedID uuid.UUID) ([]FilterRule, error) {
+	rows, err := q.db.QueryContext(ctx, getActiveFilterRulesForFeed, feedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FilterRule
+	for rows.Next() {
+		var i FilterRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.FolderID,
+			&i.FeedFollowID,
+			&i.Field,
+			&i.MatchType,
+			&i.Pattern,
+			&i.Action,
+			&i.Tag,
+			&i.Enabled,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFilterRule = `-- name: GetFilterRule :one
+SELECT id, created_at, updated_at, user_id, name, folder_id, feed_follow_id, field, match_type, pattern, action, tag, enabled
+FROM filter_rules
+WHERE id = $1 AND user_id = $2
+`
+
+type GetFilterRuleParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetFilterRule(ctx context.Context, arg GetFilterRuleParams) (FilterRule, error) {
+	row := q.db.QueryRowContext(ctx, getFilterRule, arg.ID, arg.UserID)
+	var i FilterRule
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.FolderID,
+		&i.FeedFollowID,
+		&i.Field,
+		&i.MatchType,
+		&i.Pattern,
+		&i.Action,
+		&i.Tag,
+		&i.Enabled,
+	)
+	return i, err
+}
+
+const getFilterRulesForUser = `-- name: GetFilterRulesForUser :many
+SELECT id, created_at, updated_at, user_id, name, folder_id, feed_follow_id, field, match_type, pattern, action, tag, enabled
+FROM filter_rules
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetFilterRulesForUser(ctx context.Context, userID uuid.UUID) ([]FilterRule, error) {
+	rows, err := q.db.QueryContext(ctx, getFilterRulesForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FilterRule
+	for rows.Next() {
+		var i FilterRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.FolderID,
+			&i.FeedFollowID,
+			&i.Field,
+			&i.MatchType,
+			&i.Pattern,
+			&i.Action,
+			&i.Tag,
+			&i.Enabled,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentPostsInScope = `-- name: GetRecentPostsInScope :many
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.plain_text, p.author, p.categories
+FROM posts p
+JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = $1
+WHERE ($2::uuid IS NULL OR ff.folder_id = $2)
+  AND ($3::uuid IS NULL OR ff.id = $3)
+ORDER BY p.published_at DESC
+LIMIT $4
+`
+
+type GetRecentPostsInScopeParams struct {
+	UserID       uuid.UUID
+	FolderID     uuid.NullUUID
+	FeedFollowID uuid.NullUUID
+	RowLimit     int32
+}
+
+// The user's most recent posts within a rule scope, for dry runs and
+// retroactive application.
+func (q *Queries) GetRecentPostsInScope(ctx context.Context, arg GetRecentPostsInScopeParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPostsInScope,
+		arg.UserID,
+		arg.FolderID,
+		arg.FeedFollowID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Content,
+			&i.PlainText,
+			&i.Author,
+			pq.Array(&i.Categories),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateFilterRule = `-- name: UpdateFilterRule :one
+UPDATE filter_rules
+SET name = $3,
+    folder_id = $4,
+    feed_follow_id = $5,
+    field = $6,
+    match_type = $7,
+    pattern = $8,
+    action = $9,
+    tag = $10,
+    enabled = $11,
+    updated_at = $12
+WHERE id = $1 AND user_id = $2
+RETURNING id, created_at, updated_at, user_id, name, folder_id, feed_follow_id, field, match_type, pattern, action, tag, enabled
+`
+
+type UpdateFilterRuleParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	Name         string
+	FolderID     uuid.NullUUID
+	FeedFollowID uuid.NullUUID
+	Field        string
+	MatchType    string
+	Pattern      string
+	Action       string
+	Tag          string
+	Enabled      bool
+	UpdatedAt    time.Time
+}
+
+func (q *Queries) UpdateFilterRule(ctx context.Context, arg UpdateFilterRuleParams) (FilterRule, error) {
+	row := q.db.QueryRowContext(ctx, updateFilterRule,
+		arg.ID,
+		arg.UserID,
+		arg.Name,
+		arg.FolderID,
+		arg.FeedFollowID,
+		arg.Field,
+		arg.MatchType,
+		arg.Pattern,
+		arg.Action,
+		arg.Tag,
+		arg.Enabled,
+		arg.UpdatedAt,
+	)
+	var i FilterRule
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.FolderID,
+		&i.FeedFollowID,
+		&i.Field,
+		&i.MatchType,
+		&i.Pattern,
+		&i.Action,
+		&i.Tag,
+		&i.Enabled,
+	)
+	return i, err
+}
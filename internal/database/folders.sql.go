@@ -0,0 +1,156 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: folders.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createFolder = `-- name: CreateFolder :one
+INSERT INTO folders (id, created_at, updated_at, user_id, name)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_at, updated_at, user_id, name
+`
+
+type CreateFolderParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
+func (q *Queries) CreateFolder(ctx context.Context, arg CreateFolderParams) (Folder, error) {
+	row := q.db.QueryRowContext(ctx, createFolder,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Name,
+	)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+	)
+	return i, err
+}
+
+const deleteFolder = `-- name: DeleteFolder :execrows
+DELETE FROM folders
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteFolderParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteFolder(ctx context.Context, arg DeleteFolderParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFolder, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFolder = `-- name: GetFolder :one
+SELECT id, created_at, updated_at, user_id, name
+FROM folders
+WHERE id = $1 AND user_id = $2
+`
+
+type GetFolderParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetFolder(ctx context.Context, arg GetFolderParams) (Folder, error) {
+	row := q.db.QueryRowContext(ctx, getFolder, arg.ID, arg.UserID)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+	)
+	return i, err
+}
+
+const getFoldersForUser = `-- name: GetFoldersForUser :many
+SELECT id, created_at, updated_at, user_id, name
+FROM folders
+WHERE user_id = $1
+ORDER BY name
+`
+
+func (q *Queries) GetFoldersForUser(ctx context.Context, userID uuid.UUID) ([]Folder, error) {
+	rows, err := q.db.QueryContext(ctx, getFoldersForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Folder
+	for rows.Next() {
+		var i Folder
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Name,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const renameFolder = `-- name: RenameFolder :one
+UPDATE folders
+SET name = $3, updated_at = $4
+WHERE id = $1 AND user_id = $2
+RETURNING id, created_at, updated_at, user_id, name
+`
+
+type RenameFolderParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) RenameFolder(ctx context.Context, arg RenameFolderParams) (Folder, error) {
+	row := q.db.QueryRowContext(ctx, renameFolder,
+		arg.ID,
+		arg.UserID,
+		arg.Name,
+		arg.UpdatedAt,
+	)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+	)
+	return i, err
+}
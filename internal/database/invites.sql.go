@@ -0,0 +1,137 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: invites.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createInvite = `-- name: CreateInvite :one
+INSERT INTO invites (id, created_at, created_by, code, note, max_uses, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at, created_by, code, note, max_uses, uses, expires_at
+`
+
+type CreateInviteParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	CreatedBy uuid.NullUUID
+	Code      string
+	Note      string
+	MaxUses   int32
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) CreateInvite(ctx context.Context, arg CreateInviteParams) (Invite, error) {
+	row := q.db.QueryRowContext(ctx, createInvite,
+		arg.ID,
+		arg.CreatedAt,
+		arg.CreatedBy,
+		arg.Code,
+		arg.Note,
+		arg.MaxUses,
+		arg.ExpiresAt,
+	)
+	var i Invite
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.CreatedBy,
+		&i.Code,
+		&i.Note,
+		&i.MaxUses,
+		&i.Uses,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const deleteInvite = `-- name: DeleteInvite :execrows
+DELETE FROM invites
+WHERE id = $1
+`
+
+func (q *Queries) DeleteInvite(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteInvite, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getInvites = `-- name: GetInvites :many
+SELECT id, created_at, created_by, code, note, max_uses, uses, expires_at
+FROM invites
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetInvites(ctx context.Context) ([]Invite, error) {
+	rows, err := q.db.QueryContext(ctx, getInvites)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Invite
+	for rows.Next() {
+		var i Invite
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.CreatedBy,
+			&i.Code,
+			&i.Note,
+			&i.MaxUses,
+			&i.Uses,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const redeemInvite = `-- name: RedeemInvite :one
+UPDATE invites
+SET uses = uses + 1
+WHERE code = $1
+  AND uses < max_uses
+  AND (expires_at IS NULL OR expires_at > $2::timestamp)
+RETURNING id, created_at, created_by, code, note, max_uses, uses, expires_at
+`
+
+type RedeemInviteParams struct {
+	Code string
+	Now  time.Time
+}
+
+// Uses up one use of a valid code. No row means the code is unknown,
+// used up or expired.
+func (q *Queries) RedeemInvite(ctx context.Context, arg RedeemInviteParams) (Invite, error) {
+	row := q.db.QueryRowContext(ctx, redeemInvite, arg.Code, arg.Now)
+	var i Invite
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.CreatedBy,
+		&i.Code,
+		&i.Note,
+		&i.MaxUses,
+		&i.Uses,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
@@ -11,22 +11,120 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	LastUsedAt sql.NullTime
+	ExpiresAt  sql.NullTime
+	Scopes     []string
+}
+
 type Feed struct {
 	ID            uuid.UUID
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	Name          string
 	Url           string
-	UserID        uuid.UUID
+	AddedBy       uuid.NullUUID
 	LastFetchedAt sql.NullTime
+	Title         sql.NullString
+	SiteUrl       sql.NullString
+	Description   sql.NullString
+	ImageUrl      sql.NullString
+	Language      sql.NullString
+	Generator     sql.NullString
+	LastBuildDate sql.NullTime
+	IconCheckedAt sql.NullTime
+	FetchError    sql.NullString
+	FetchErrorAt  sql.NullTime
+	FetchFailures int32
+	FollowerCount int32
+	DeactivatedAt sql.NullTime
 }
 
 type FeedFollow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	FeedID           uuid.UUID
+	UserID           uuid.UUID
+	FolderID         uuid.NullUUID
+	Title            sql.NullString
+	Color            sql.NullString
+	Priority         int32
+	HideFromTimeline bool
+	Notify           bool
+}
+
+type FeedIcon struct {
+	FeedID      uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Url         string
+	ContentType string
+	Data        []byte
+	Hash        string
+}
+
+type FilterRule struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	FolderID     uuid.NullUUID
+	FeedFollowID uuid.NullUUID
+	Field        string
+	MatchType    string
+	Pattern      string
+	Action       string
+	Tag          string
+	Enabled      bool
+}
+
+type Folder struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	FeedID    uuid.UUID
 	UserID    uuid.UUID
+	Name      string
+}
+
+type Invite struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	CreatedBy uuid.NullUUID
+	Code      string
+	Note      string
+	MaxUses   int32
+	Uses      int32
+	ExpiresAt sql.NullTime
+}
+
+type Notification struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UserID        uuid.UUID
+	PostID        uuid.UUID
+	SavedSearchID uuid.NullUUID
+	ReadAt        sql.NullTime
+}
+
+type OutputFeed struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	UserID        uuid.UUID
+	Token         string
+	Title         string
+	Source        string
+	FolderID      uuid.NullUUID
+	SavedSearchID uuid.NullUUID
 }
 
 type Post struct {
@@ -38,12 +136,122 @@ type Post struct {
 	Description string
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Content     string
+	PlainText   string
+	Author      string
+	Categories  []string
 }
 
-type User struct {
+type PostSearch struct {
+	PostID uuid.UUID
+	Config interface{}
+	Vector interface{}
+}
+
+type PostState struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ReadAt    sql.NullTime
+	StarredAt sql.NullTime
+	HiddenAt  sql.NullTime
+}
+
+type PostTag struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type SavedSearch struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	UserID    uuid.UUID
 	Name      string
-	ApiKey    string
+	Query     string
+	Language  string
+	Notify    bool
+}
+
+type Session struct {
+	ID                uuid.UUID
+	CreatedAt         time.Time
+	UserID            uuid.UUID
+	TokenHash         string
+	PreviousTokenHash sql.NullString
+	CsrfToken         string
+	RotatedAt         time.Time
+	ExpiresAt         time.Time
+	UserAgent         string
+}
+
+type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	Username     sql.NullString
+	PasswordHash sql.NullString
+	IsAdmin      bool
+	DisabledAt   sql.NullTime
+}
+
+type Webhook struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	UserID              uuid.UUID
+	Url                 string
+	Secret              string
+	FeedID              uuid.NullUUID
+	FolderID            uuid.NullUUID
+	RuleID              uuid.NullUUID
+	Enabled             bool
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+	DisabledReason      string
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	WebhookID      uuid.UUID
+	PostID         uuid.UUID
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	LastError      string
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookDeliveryAttempt struct {
+	ID             uuid.UUID
+	DeliveryID     uuid.UUID
+	AttemptedAt    time.Time
+	ResponseStatus sql.NullInt32
+	Error          string
+	DurationMs     int32
+}
+
+type WebsubSubscription struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	FeedID         uuid.UUID
+	HubUrl         string
+	TopicUrl       string
+	Secret         string
+	State          string
+	RequestedAt    time.Time
+	LeaseExpiresAt sql.NullTime
+	LastPushAt     sql.NullTime
+	LastError      string
 }
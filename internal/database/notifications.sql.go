@@ -0,0 +1,147 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: notifications.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createSavedSearchNotifications = `-- name: CreateSavedSearchNotifications :execrows
+INSERT INTO notifications (id, created_at, user_id, post_id, saved_search_id)
+SELECT gen_random_uuid(), NOW(), s.user_id, p.id, s.id
+FROM posts p
+JOIN post_search idx ON idx.post_id = p.id
+JOIN feed_follows ff ON ff.feed_id = p.feed_id
+JOIN saved_searches s ON s.user_id = ff.user_id
+WHERE p.id = $1
+  AND ff.notify
+  AND s.notify
+  AND NOT EXISTS (
+    SELECT 1
+    FROM post_states ps
+    WHERE ps.post_id = p.id
+      AND ps.user_id = s.user_id
+      AND ps.hidden_at IS NOT NULL
+  )
+  AND idx.vector @@ (websearch_to_tsquery('simple', s.query)
+          || websearch_to_tsquery(search_config_for_language(s.language), s.query))
+ON CONFLICT DO NOTHING
+`
+
+// Notifies every follower of the post's feed whose notifying saved searches
+// match it, unless they turned notifications off for the feed or one of
+// their rules hid the post.
+func (q *Queries) CreateSavedSearchNotifications(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createSavedSearchNotifications, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getNotificationsForUser = `-- name: GetNotificationsForUser :many
+SELECT n.id,
+       n.created_at,
+       n.post_id,
+       n.saved_search_id,
+       n.read_at,
+       p.title AS post_title,
+       p.url AS post_url,
+       p.feed_id,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       s.name AS saved_search_name
+FROM notifications n
+JOIN posts p ON p.id = n.post_id
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = n.user_id
+LEFT JOIN saved_searches s ON s.id = n.saved_search_id
+WHERE n.user_id = $1
+  AND (NOT $2::boolean OR n.read_at IS NULL)
+ORDER BY n.created_at DESC
+LIMIT $4 OFFSET $3
+`
+
+type GetNotificationsForUserParams struct {
+	UserID     uuid.UUID
+	UnreadOnly bool
+	RowOffset  int32
+	RowLimit   int32
+}
+
+type GetNotificationsForUserRow struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	PostID          uuid.UUID
+	SavedSearchID   uuid.NullUUID
+	ReadAt          sql.NullTime
+	PostTitle       string
+	PostUrl         string
+	FeedID          uuid.UUID
+	FeedTitle       string
+	SavedSearchName sql.NullString
+}
+
+func (q *Queries) GetNotificationsForUser(ctx context.Context, arg GetNotificationsForUserParams) ([]GetNotificationsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getNotificationsForUser,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.RowOffset,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetNotificationsForUserRow
+	for rows.Next() {
+		var i GetNotificationsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.PostID,
+			&i.SavedSearchID,
+			&i.ReadAt,
+			&i.PostTitle,
+			&i.PostUrl,
+			&i.FeedID,
+			&i.FeedTitle,
+			&i.SavedSearchName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markNotificationRead = `-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2
+`
+
+type MarkNotificationReadParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) MarkNotificationRead(ctx context.Context, arg MarkNotificationReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markNotificationRead, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
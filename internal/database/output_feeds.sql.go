@@ -0,0 +1,139 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: output_feeds.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createOutputFeed = `-- name: CreateOutputFeed :one
+INSERT INTO output_feeds (id, created_at, updated_at, user_id, token, title, source, folder_id, saved_search_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, created_at, updated_at, user_id, token, title, source, folder_id, saved_search_id
+`
+
+type CreateOutputFeedParams struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	UserID        uuid.UUID
+	Token         string
+	Title         string
+	Source        string
+	FolderID      uuid.NullUUID
+	SavedSearchID uuid.NullUUID
+}
+
+func (q *Queries) CreateOutputFeed(ctx context.Context, arg CreateOutputFeedParams) (OutputFeed, error) {
+	row := q.db.QueryRowContext(ctx, createOutputFeed,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Token,
+		arg.Title,
+		arg.Source,
+		arg.FolderID,
+		arg.SavedSearchID,
+	)
+	var i OutputFeed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Token,
+		&i.Title,
+		&i.Source,
+		&i.FolderID,
+		&i.SavedSearchID,
+	)
+	return i, err
+}
+
+const deleteOutputFeed = `-- name: DeleteOutputFeed :execrows
+DELETE FROM output_feeds
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteOutputFeedParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteOutputFeed(ctx context.Context, arg DeleteOutputFeedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOutputFeed, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getOutputFeedByToken = `-- name: GetOutputFeedByToken :one
+SELECT id, created_at, updated_at, user_id, token, title, source, folder_id, saved_search_id
+FROM output_feeds
+WHERE token = $1
+`
+
+func (q *Queries) GetOutputFeedByToken(ctx context.Context, token string) (OutputFeed, error) {
+	row := q.db.QueryRowContext(ctx, getOutputFeedByToken, token)
+	var i OutputFeed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Token,
+		&i.Title,
+		&i.Source,
+		&i.FolderID,
+		&i.SavedSearchID,
+	)
+	return i, err
+}
+
+const getOutputFeedsForUser = `-- name: GetOutputFeedsForUser :many
+SELECT id, created_at, updated_at, user_id, token, title, source, folder_id, saved_search_id
+FROM output_feeds
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetOutputFeedsForUser(ctx context.Context, userID uuid.UUID) ([]OutputFeed, error) {
+	rows, err := q.db.QueryContext(ctx, getOutputFeedsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OutputFeed
+	for rows.Next() {
+		var i OutputFeed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Token,
+			&i.Title,
+			&i.Source,
+			&i.FolderID,
+			&i.SavedSearchID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,170 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: post_states.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const getPostState = `-- name: GetPostState :one
+SELECT user_id, post_id, created_at, updated_at, read_at, starred_at, hidden_at
+FROM post_states
+WHERE user_id = $1 AND post_id = $2
+`
+
+type GetPostStateParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) GetPostState(ctx context.Context, arg GetPostStateParams) (PostState, error) {
+	row := q.db.QueryRowContext(ctx, getPostState, arg.UserID, arg.PostID)
+	var i PostState
+	err := row.Scan(
+		&i.UserID,
+		&i.PostID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReadAt,
+		&i.StarredAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const hidePost = `-- name: HidePost :exec
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, hidden_at)
+VALUES ($1, $2, $3, $3, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET hidden_at = COALESCE(post_states.hidden_at, EXCLUDED.hidden_at),
+    updated_at = EXCLUDED.updated_at
+`
+
+type HidePostParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	Now    time.Time
+}
+
+func (q *Queries) HidePost(ctx context.Context, arg HidePostParams) error {
+	_, err := q.db.ExecContext(ctx, hidePost, arg.UserID, arg.PostID, arg.Now)
+	return err
+}
+
+const markPostRead = `-- name: MarkPostRead :exec
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, read_at)
+VALUES ($1, $2, $3, $3, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, EXCLUDED.read_at),
+    updated_at = EXCLUDED.updated_at
+`
+
+type MarkPostReadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	Now    time.Time
+}
+
+func (q *Queries) MarkPostRead(ctx context.Context, arg MarkPostReadParams) error {
+	_, err := q.db.ExecContext(ctx, markPostRead, arg.UserID, arg.PostID, arg.Now)
+	return err
+}
+
+const markPostUnread = `-- name: MarkPostUnread :exec
+UPDATE post_states
+SET read_at = NULL, updated_at = $1
+WHERE user_id = $2 AND post_id = $3
+`
+
+type MarkPostUnreadParams struct {
+	Now    time.Time
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) MarkPostUnread(ctx context.Context, arg MarkPostUnreadParams) error {
+	_, err := q.db.ExecContext(ctx, markPostUnread, arg.Now, arg.UserID, arg.PostID)
+	return err
+}
+
+const starPost = `-- name: StarPost :exec
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, starred_at)
+VALUES ($1, $2, $3, $3, $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred_at = COALESCE(post_states.starred_at, EXCLUDED.starred_at),
+    updated_at = EXCLUDED.updated_at
+`
+
+type StarPostParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	Now    time.Time
+}
+
+func (q *Queries) StarPost(ctx context.Context, arg StarPostParams) error {
+	_, err := q.db.ExecContext(ctx, starPost, arg.UserID, arg.PostID, arg.Now)
+	return err
+}
+
+const tagPost = `-- name: TagPost :exec
+INSERT INTO post_tags (user_id, post_id, tag, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING
+`
+
+type TagPostParams struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+func (q *Queries) TagPost(ctx context.Context, arg TagPostParams) error {
+	_, err := q.db.ExecContext(ctx, tagPost,
+		arg.UserID,
+		arg.PostID,
+		arg.Tag,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const unhidePost = `-- name: UnhidePost :exec
+UPDATE post_states
+SET hidden_at = NULL, updated_at = $1
+WHERE user_id = $2 AND post_id = $3
+`
+
+type UnhidePostParams struct {
+	Now    time.Time
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) UnhidePost(ctx context.Context, arg UnhidePostParams) error {
+	_, err := q.db.ExecContext(ctx, unhidePost, arg.Now, arg.UserID, arg.PostID)
+	return err
+}
+
+const unstarPost = `-- name: UnstarPost :exec
+UPDATE post_states
+SET starred_at = NULL, updated_at = $1
+WHERE user_id = $2 AND post_id = $3
+`
+
+type UnstarPostParams struct {
+	Now    time.Time
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) UnstarPost(ctx context.Context, arg UnstarPostParams) error {
+	_, err := q.db.ExecContext(ctx, unstarPost, arg.Now, arg.UserID, arg.PostID)
+	return err
+}
@@ -7,9 +7,11 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createPost = `-- name: CreatePost :one
@@ -21,10 +23,14 @@ INSERT INTO posts (
     url,
     description,
     published_at,
-    feed_id
+    feed_id,
+    content,
+    plain_text,
+    author,
+    categories
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, content, plain_text, author, categories
 `
 
 type CreatePostParams struct {
@@ -36,6 +42,10 @@ type CreatePostParams struct {
 	Description string
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Content     string
+	PlainText   string
+	Author      string
+	Categories  []string
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -48,6 +58,10 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Content,
+		arg.PlainText,
+		arg.Author,
+		pq.Array(arg.Categories),
 	)
 	var i Post
 	err := row.Scan(
@@ -59,12 +73,16 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Content,
+		&i.PlainText,
+		&i.Author,
+		pq.Array(&i.Categories),
 	)
 	return i, err
 }
 
 const getPost = `-- name: GetPost :one
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, content, plain_text, author, categories
 FROM posts
 WHERE id = $1
 `
@@ -81,12 +99,16 @@ func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Content,
+		&i.PlainText,
+		&i.Author,
+		pq.Array(&i.Categories),
 	)
 	return i, err
 }
 
 const getPosts = `-- name: GetPosts :many
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, content, plain_text, author, categories
 FROM posts
 ORDER BY published_at DESC
 LIMIT 50
@@ -110,6 +132,297 @@ func (q *Queries) GetPosts(ctx context.Context) ([]Post, error) {
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Content,
+			&i.PlainText,
+			&i.Author,
+			pq.Array(&i.Categories),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTimelineForUser = `-- name: GetTimelineForUser :many
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.plain_text, p.author, p.categories,
+       ps.read_at,
+       ps.starred_at,
+       ps.hidden_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       ff.color AS feed_color,
+       COALESCE(
+           (SELECT array_agg(t.tag ORDER BY t.tag) FROM post_tags t WHERE t.user_id = $1 AND t.post_id = p.id),
+           '{}'
+       )::text[] AS tags
+FROM posts p
+JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = $1
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = $1
+WHERE ($2::boolean OR ps.hidden_at IS NULL)
+  AND (NOT ff.hide_from_timeline OR $3::uuid IS NOT NULL)
+  AND (NOT $4::boolean OR ps.read_at IS NULL)
+  AND (NOT $5::boolean OR ps.starred_at IS NOT NULL)
+  AND ($6::uuid IS NULL OR ff.folder_id = $6)
+  AND ($3::uuid IS NULL OR p.feed_id = $3)
+  AND ($7::text IS NULL OR EXISTS (
+      SELECT 1 FROM post_tags t
+      WHERE t.user_id = $1 AND t.post_id = p.id AND t.tag = $7
+  ))
+ORDER BY p.published_at DESC
+LIMIT $9 OFFSET $8
+`
+
+type GetTimelineForUserParams struct {
+	UserID        uuid.UUID
+	IncludeHidden bool
+	FeedID        uuid.NullUUID
+	UnreadOnly    bool
+	StarredOnly   bool
+	FolderID      uuid.NullUUID
+	Tag           sql.NullString
+	RowOffset     int32
+	RowLimit      int32
+}
+
+type GetTimelineForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	Content     string
+	PlainText   string
+	Author      string
+	Categories  []string
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+	HiddenAt    sql.NullTime
+	FeedTitle   string
+	FeedColor   sql.NullString
+	Tags        []string
+}
+
+// Posts from the user's followed feeds with their read, star and hidden
+// state and tags, newest first. Hidden posts are left out unless asked for,
+// and so are feeds hidden from the timeline unless asked for by feed_id.
+// feed_title is the user's title for the feed if they set one.
+func (q *Queries) GetTimelineForUser(ctx context.Context, arg GetTimelineForUserParams) ([]GetTimelineForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTimelineForUser,
+		arg.UserID,
+		arg.IncludeHidden,
+		arg.FeedID,
+		arg.UnreadOnly,
+		arg.StarredOnly,
+		arg.FolderID,
+		arg.Tag,
+		arg.RowOffset,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTimelineForUserRow
+	for rows.Next() {
+		var i GetTimelineForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Content,
+			&i.PlainText,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.ReadAt,
+			&i.StarredAt,
+			&i.HiddenAt,
+			&i.FeedTitle,
+			&i.FeedColor,
+			pq.Array(&i.Tags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTimelinePostForUser = `-- name: GetTimelinePostForUser :one
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.plain_text, p.author, p.categories,
+       ps.read_at,
+       ps.starred_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       ff.color AS feed_color,
+       ff.hide_from_timeline,
+       COALESCE(
+           (SELECT array_agg(t.tag ORDER BY t.tag) FROM post_tags t WHERE t.user_id = $1 AND t.post_id = p.id),
+           '{}'
+       )::text[] AS tags
+FROM posts p
+JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = $1
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = $1
+WHERE p.id = $2
+  AND ps.hidden_at IS NULL
+`
+
+type GetTimelinePostForUserParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+type GetTimelinePostForUserRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            string
+	Url              string
+	Description      string
+	PublishedAt      time.Time
+	FeedID           uuid.UUID
+	Content          string
+	PlainText        string
+	Author           string
+	Categories       []string
+	ReadAt           sql.NullTime
+	StarredAt        sql.NullTime
+	FeedTitle        string
+	FeedColor        sql.NullString
+	HideFromTimeline bool
+	Tags             []string
+}
+
+// A single post as the user sees it, only if it is from a feed they follow
+// and they haven't hidden it. Callers decide what to do about feeds hidden
+// from the timeline.
+func (q *Queries) GetTimelinePostForUser(ctx context.Context, arg GetTimelinePostForUserParams) (GetTimelinePostForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getTimelinePostForUser, arg.UserID, arg.PostID)
+	var i GetTimelinePostForUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Content,
+		&i.PlainText,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.ReadAt,
+		&i.StarredAt,
+		&i.FeedTitle,
+		&i.FeedColor,
+		&i.HideFromTimeline,
+		pq.Array(&i.Tags),
+	)
+	return i, err
+}
+
+const getTimelineSinceForUser = `-- name: GetTimelineSinceForUser :many
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.plain_text, p.author, p.categories,
+       ps.read_at,
+       ps.starred_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       ff.color AS feed_color,
+       ff.hide_from_timeline,
+       COALESCE(
+           (SELECT array_agg(t.tag ORDER BY t.tag) FROM post_tags t WHERE t.user_id = $1 AND t.post_id = p.id),
+           '{}'
+       )::text[] AS tags
+FROM posts p
+JOIN posts after ON after.id = $2
+JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = $1
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = $1
+WHERE (p.created_at, p.id) > (after.created_at, after.id)
+  AND ps.hidden_at IS NULL
+  AND NOT ff.hide_from_timeline
+ORDER BY p.created_at, p.id
+LIMIT $3
+`
+
+type GetTimelineSinceForUserParams struct {
+	UserID      uuid.UUID
+	AfterPostID uuid.UUID
+	RowLimit    int32
+}
+
+type GetTimelineSinceForUserRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Title            string
+	Url              string
+	Description      string
+	PublishedAt      time.Time
+	FeedID           uuid.UUID
+	Content          string
+	PlainText        string
+	Author           string
+	Categories       []string
+	ReadAt           sql.NullTime
+	StarredAt        sql.NullTime
+	FeedTitle        string
+	FeedColor        sql.NullString
+	HideFromTimeline bool
+	Tags             []string
+}
+
+// Posts from the user's followed feeds stored after the given post, oldest
+// first, for resuming a stream. Feeds hidden from the timeline are left
+// out.
+func (q *Queries) GetTimelineSinceForUser(ctx context.Context, arg GetTimelineSinceForUserParams) ([]GetTimelineSinceForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTimelineSinceForUser, arg.UserID, arg.AfterPostID, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTimelineSinceForUserRow
+	for rows.Next() {
+		var i GetTimelineSinceForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Content,
+			&i.PlainText,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.ReadAt,
+			&i.StarredAt,
+			&i.FeedTitle,
+			&i.FeedColor,
+			&i.HideFromTimeline,
+			pq.Array(&i.Tags),
 		); err != nil {
 			return nil, err
 		}
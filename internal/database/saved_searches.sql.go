@@ -0,0 +1,320 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: saved_searches.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createSavedSearch = `-- name: CreateSavedSearch :one
+INSERT INTO saved_searches (id, created_at, updated_at, user_id, name, query, language, notify)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at, updated_at, user_id, name, query, language, notify
+`
+
+type CreateSavedSearchParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	Query     string
+	Language  string
+	Notify    bool
+}
+
+func (q *Queries) CreateSavedSearch(ctx context.Context, arg CreateSavedSearchParams) (SavedSearch, error) {
+	row := q.db.QueryRowContext(ctx, createSavedSearch,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Name,
+		arg.Query,
+		arg.Language,
+		arg.Notify,
+	)
+	var i SavedSearch
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.Query,
+		&i.Language,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const deleteSavedSearch = `-- name: DeleteSavedSearch :execrows
+DELETE FROM saved_searches
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteSavedSearchParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteSavedSearch(ctx context.Context, arg DeleteSavedSearchParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteSavedSearch, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getSavedSearch = `-- name: GetSavedSearch :one
+SELECT id, created_at, updated_at, user_id, name, query, language, notify
+FROM saved_searches
+WHERE id = $1 AND user_id = $2
+`
+
+type GetSavedSearchParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetSavedSearch(ctx context.Context, arg GetSavedSearchParams) (SavedSearch, error) {
+	row := q.db.QueryRowContext(ctx, getSavedSearch, arg.ID, arg.UserID)
+	var i SavedSearch
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.Query,
+		&i.Language,
+		&i.Notify,
+	)
+	return i, err
+}
+
+const getSavedSearchPosts = `-- name: GetSavedSearchPosts :many
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.content, p.plain_text, p.author, p.categories,
+       ps.read_at,
+       ps.starred_at,
+       ps.hidden_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       ff.color AS feed_color,
+       COALESCE(
+           (SELECT array_agg(t.tag ORDER BY t.tag) FROM post_tags t WHERE t.user_id = s.user_id AND t.post_id = p.id),
+           '{}'
+       )::text[] AS tags
+FROM saved_searches s
+JOIN feed_follows ff ON ff.user_id = s.user_id
+JOIN posts p ON p.feed_id = ff.feed_id
+JOIN feeds f ON f.id = p.feed_id
+JOIN post_search idx ON idx.post_id = p.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = s.user_id
+WHERE s.id = $1
+  AND idx.vector @@ (websearch_to_tsquery('simple', s.query)
+          || websearch_to_tsquery(search_config_for_language(s.language), s.query))
+  AND ps.hidden_at IS NULL
+  AND (NOT $2::boolean OR ps.read_at IS NULL)
+ORDER BY p.published_at DESC
+LIMIT $4 OFFSET $3
+`
+
+type GetSavedSearchPostsParams struct {
+	SavedSearchID uuid.UUID
+	UnreadOnly    bool
+	RowOffset     int32
+	RowLimit      int32
+}
+
+type GetSavedSearchPostsRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	Content     string
+	PlainText   string
+	Author      string
+	Categories  []string
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+	HiddenAt    sql.NullTime
+	FeedTitle   string
+	FeedColor   sql.NullString
+	Tags        []string
+}
+
+// The saved search's timeline: matching posts from followed feeds, newest
+// first, leaving out posts the user's rules have hidden.
+func (q *Queries) GetSavedSearchPosts(ctx context.Context, arg GetSavedSearchPostsParams) ([]GetSavedSearchPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSavedSearchPosts,
+		arg.SavedSearchID,
+		arg.UnreadOnly,
+		arg.RowOffset,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSavedSearchPostsRow
+	for rows.Next() {
+		var i GetSavedSearchPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Content,
+			&i.PlainText,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.ReadAt,
+			&i.StarredAt,
+			&i.HiddenAt,
+			&i.FeedTitle,
+			&i.FeedColor,
+			pq.Array(&i.Tags),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSavedSearchesForUser = `-- name: GetSavedSearchesForUser :many
+SELECT s.id,
+       s.created_at,
+       s.updated_at,
+       s.user_id,
+       s.name,
+       s.query,
+       s.language,
+       s.notify,
+       (
+           SELECT COUNT(*)
+           FROM posts p
+           JOIN post_search ps ON ps.post_id = p.id
+           WHERE ps.vector @@ (websearch_to_tsquery('simple', s.query)
+                   || websearch_to_tsquery(search_config_for_language(s.language), s.query))
+             AND p.feed_id IN (SELECT ff.feed_id FROM feed_follows ff WHERE ff.user_id = s.user_id)
+             AND NOT EXISTS (
+                 SELECT 1 FROM post_states st
+                 WHERE st.post_id = p.id AND st.user_id = s.user_id
+                   AND (st.read_at IS NOT NULL OR st.hidden_at IS NOT NULL)
+             )
+       ) AS unread_count
+FROM saved_searches s
+WHERE s.user_id = $1
+ORDER BY s.created_at DESC
+`
+
+type GetSavedSearchesForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Name        string
+	Query       string
+	Language    string
+	Notify      bool
+	UnreadCount int64
+}
+
+// Each saved search comes with the number of matching posts from followed
+// feeds that the user hasn't read.
+func (q *Queries) GetSavedSearchesForUser(ctx context.Context, userID uuid.UUID) ([]GetSavedSearchesForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSavedSearchesForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSavedSearchesForUserRow
+	for rows.Next() {
+		var i GetSavedSearchesForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.Query,
+			&i.Language,
+			&i.Notify,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateSavedSearch = `-- name: UpdateSavedSearch :one
+UPDATE saved_searches
+SET name = $3, query = $4, language = $5, notify = $6, updated_at = $7
+WHERE id = $1 AND user_id = $2
+RETURNING id, created_at, updated_at, user_id, name, query, language, notify
+`
+
+type UpdateSavedSearchParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Name      string
+	Query     string
+	Language  string
+	Notify    bool
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateSavedSearch(ctx context.Context, arg UpdateSavedSearchParams) (SavedSearch, error) {
+	row := q.db.QueryRowContext(ctx, updateSavedSearch,
+		arg.ID,
+		arg.UserID,
+		arg.Name,
+		arg.Query,
+		arg.Language,
+		arg.Notify,
+		arg.UpdatedAt,
+	)
+	var i SavedSearch
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.Query,
+		&i.Language,
+		&i.Notify,
+	)
+	return i, err
+}
@@ -0,0 +1,127 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: search.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const searchPosts = `-- name: SearchPosts :many
+SELECT p.id,
+       p.title,
+       p.url,
+       p.published_at,
+       p.feed_id,
+       ts_rank_cd(s.vector, q.query)::real AS rank,
+       ts_headline(
+           s.config,
+           regexp_replace(p.title || ' ' || p.description || ' ' || p.content, '<[^>]*>', ' ', 'g'),
+           q.query,
+           'StartSel=' || chr(1) || ', StopSel=' || chr(2) || ', MaxFragments=2, MaxWords=30, MinWords=10'
+       )::text AS snippet,
+       ps.read_at,
+       ps.starred_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title
+FROM posts p
+JOIN post_search s ON s.post_id = p.id
+JOIN feeds f ON f.id = p.feed_id
+CROSS JOIN (
+    SELECT websearch_to_tsquery('simple', $1::text)
+        || websearch_to_tsquery(search_config_for_language($2::text), $1::text) AS query
+) q
+LEFT JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = $3
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = $3
+WHERE s.vector @@ q.query
+  AND ($4::boolean OR ps.hidden_at IS NULL)
+  AND (NOT $5::boolean OR ff.id IS NOT NULL)
+  AND ($6::uuid IS NULL OR p.feed_id = $6)
+  AND ($7::timestamp IS NULL OR p.published_at >= $7)
+  AND ($8::timestamp IS NULL OR p.published_at < $8)
+  AND ($9::boolean IS NULL OR (ps.read_at IS NOT NULL) = $9)
+ORDER BY rank DESC, p.published_at DESC
+LIMIT $11 OFFSET $10
+`
+
+type SearchPostsParams struct {
+	Query           string
+	Language        string
+	UserID          uuid.UUID
+	IncludeHidden   bool
+	FollowedOnly    bool
+	FeedID          uuid.NullUUID
+	PublishedAfter  sql.NullTime
+	PublishedBefore sql.NullTime
+	IsRead          sql.NullBool
+	RowOffset       int32
+	RowLimit        int32
+}
+
+type SearchPostsRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	Rank        float32
+	Snippet     string
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+	FeedTitle   string
+}
+
+// Matches are ranked by relevance; snippets mark hits with \x01 and \x02 so
+// the caller can escape the text before turning them into tags.
+// feed_title is the user's title for the feed if they follow it and set
+// one. Posts the user hid are left out unless include_hidden is set.
+func (q *Queries) SearchPosts(ctx context.Context, arg SearchPostsParams) ([]SearchPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPosts,
+		arg.Query,
+		arg.Language,
+		arg.UserID,
+		arg.IncludeHidden,
+		arg.FollowedOnly,
+		arg.FeedID,
+		arg.PublishedAfter,
+		arg.PublishedBefore,
+		arg.IsRead,
+		arg.RowOffset,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsRow
+	for rows.Next() {
+		var i SearchPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Rank,
+			&i.Snippet,
+			&i.ReadAt,
+			&i.StarredAt,
+			&i.FeedTitle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,193 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: sessions.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createSession = `-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, user_id, token_hash, csrf_token, rotated_at, expires_at, user_agent)
+VALUES ($1, $2, $3, $4, $5, $2, $6, $7)
+RETURNING id, created_at, user_id, token_hash, previous_token_hash, csrf_token, rotated_at, expires_at, user_agent
+`
+
+type CreateSessionParams struct {
+	ID        uuid.UUID
+	Now       time.Time
+	UserID    uuid.UUID
+	TokenHash string
+	CsrfToken string
+	ExpiresAt time.Time
+	UserAgent string
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, createSession,
+		arg.ID,
+		arg.Now,
+		arg.UserID,
+		arg.TokenHash,
+		arg.CsrfToken,
+		arg.ExpiresAt,
+		arg.UserAgent,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.TokenHash,
+		&i.PreviousTokenHash,
+		&i.CsrfToken,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+		&i.UserAgent,
+	)
+	return i, err
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+WHERE expires_at < $1
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, expiresAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredSessions, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOtherSessionsForUser = `-- name: DeleteOtherSessionsForUser :exec
+DELETE FROM sessions
+WHERE user_id = $1 AND id <> $2
+`
+
+type DeleteOtherSessionsForUserParams struct {
+	UserID uuid.UUID
+	KeepID uuid.UUID
+}
+
+func (q *Queries) DeleteOtherSessionsForUser(ctx context.Context, arg DeleteOtherSessionsForUserParams) error {
+	_, err := q.db.ExecContext(ctx, deleteOtherSessionsForUser, arg.UserID, arg.KeepID)
+	return err
+}
+
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE id = $1
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteSession, id)
+	return err
+}
+
+const deleteSessionsForUser = `-- name: DeleteSessionsForUser :exec
+DELETE FROM sessions
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteSessionsForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteSessionsForUser, userID)
+	return err
+}
+
+const getSessionByToken = `-- name: GetSessionByToken :one
+SELECT s.id, s.created_at, s.user_id, s.token_hash, s.previous_token_hash, s.csrf_token, s.rotated_at, s.expires_at, s.user_agent, u.id, u.created_at, u.updated_at, u.name, u.username, u.password_hash, u.is_admin, u.disabled_at
+FROM sessions s
+JOIN users u ON u.id = s.user_id
+WHERE s.expires_at > $1::timestamp
+  AND u.disabled_at IS NULL
+  AND (s.token_hash = $2
+       OR (s.previous_token_hash = $2 AND s.rotated_at > $3::timestamp))
+`
+
+type GetSessionByTokenParams struct {
+	Now        time.Time
+	TokenHash  string
+	GraceAfter time.Time
+}
+
+type GetSessionByTokenRow struct {
+	Session Session
+	User    User
+}
+
+// Finds an unexpired session by its current token, or by the token it had
+// before its last rotation if that happened after @grace_after.
+func (q *Queries) GetSessionByToken(ctx context.Context, arg GetSessionByTokenParams) (GetSessionByTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getSessionByToken, arg.Now, arg.TokenHash, arg.GraceAfter)
+	var i GetSessionByTokenRow
+	err := row.Scan(
+		&i.Session.ID,
+		&i.Session.CreatedAt,
+		&i.Session.UserID,
+		&i.Session.TokenHash,
+		&i.Session.PreviousTokenHash,
+		&i.Session.CsrfToken,
+		&i.Session.RotatedAt,
+		&i.Session.ExpiresAt,
+		&i.Session.UserAgent,
+		&i.User.ID,
+		&i.User.CreatedAt,
+		&i.User.UpdatedAt,
+		&i.User.Name,
+		&i.User.Username,
+		&i.User.PasswordHash,
+		&i.User.IsAdmin,
+		&i.User.DisabledAt,
+	)
+	return i, err
+}
+
+const rotateSession = `-- name: RotateSession :one
+UPDATE sessions
+SET previous_token_hash = token_hash,
+    token_hash = $1,
+    rotated_at = $2,
+    expires_at = $3
+WHERE id = $4 AND token_hash = $5
+RETURNING id, created_at, user_id, token_hash, previous_token_hash, csrf_token, rotated_at, expires_at, user_agent
+`
+
+type RotateSessionParams struct {
+	NewTokenHash string
+	Now          time.Time
+	ExpiresAt    time.Time
+	ID           uuid.UUID
+	OldTokenHash string
+}
+
+// Replaces the session token and extends the session. Only the caller that
+// still holds the current token wins if two requests rotate at once.
+func (q *Queries) RotateSession(ctx context.Context, arg RotateSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, rotateSession,
+		arg.NewTokenHash,
+		arg.Now,
+		arg.ExpiresAt,
+		arg.ID,
+		arg.OldTokenHash,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.TokenHash,
+		&i.PreviousTokenHash,
+		&i.CsrfToken,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+		&i.UserAgent,
+	)
+	return i, err
+}
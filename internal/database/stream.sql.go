@@ -0,0 +1,20 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: stream.sql
+
+package database
+
+import (
+	"context"
+)
+
+const notifyStreamEvent = `-- name: NotifyStreamEvent :exec
+SELECT pg_notify('stream_events', $1::text)
+`
+
+// Sends a live-update event to every server instance's stream hub.
+func (q *Queries) NotifyStreamEvent(ctx context.Context, payload string) error {
+	_, err := q.db.ExecContext(ctx, notifyStreamEvent, payload)
+	return err
+}
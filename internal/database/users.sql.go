@@ -7,23 +7,53 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const countOtherActiveAdmins = `-- name: CountOtherActiveAdmins :one
+SELECT COUNT(*)
+FROM users
+WHERE is_admin
+  AND disabled_at IS NULL
+  AND id <> $1
+`
+
+func (q *Queries) CountOtherActiveAdmins(ctx context.Context, id uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOtherActiveAdmins, id)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*)
+FROM users
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name, api_key)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, created_at, updated_at, name, api_key
+INSERT INTO users (id, created_at, updated_at, name, username, password_hash, is_admin)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, created_at, updated_at, name, username, password_hash, is_admin, disabled_at
 `
 
 type CreateUserParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	ApiKey    string
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	Username     sql.NullString
+	PasswordHash sql.NullString
+	IsAdmin      bool
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -32,7 +62,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
-		arg.ApiKey,
+		arg.Username,
+		arg.PasswordHash,
+		arg.IsAdmin,
 	)
 	var i User
 	err := row.Scan(
@@ -40,26 +72,221 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
-		&i.ApiKey,
+		&i.Username,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
 	)
 	return i, err
 }
 
-const getUserByAPIKey = `-- name: GetUserByAPIKey :one
-SELECT id, created_at, updated_at, name, api_key
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, created_at, updated_at, name, username, password_hash, is_admin, disabled_at
 FROM users
-WHERE api_key = $1
+WHERE id = $1
 `
 
-func (q *Queries) GetUserByAPIKey(ctx context.Context, apiKey string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByAPIKey, apiKey)
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Username,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, created_at, updated_at, name, username, password_hash, is_admin, disabled_at
+FROM users
+WHERE lower(username) = lower($1)
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Username,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const getUsers = `-- name: GetUsers :many
+SELECT id, created_at, updated_at, name, username, password_hash, is_admin, disabled_at
+FROM users
+ORDER BY created_at
+LIMIT $2 OFFSET $1
+`
+
+type GetUsersParams struct {
+	RowOffset int32
+	RowLimit  int32
+}
+
+func (q *Queries) GetUsers(ctx context.Context, arg GetUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsers, arg.RowOffset, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Username,
+			&i.PasswordHash,
+			&i.IsAdmin,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockUserAccounts = `-- name: LockUserAccounts :exec
+SELECT pg_advisory_xact_lock(7413001)
+`
+
+// Held until the transaction ends. Sign-ups and removing or disabling
+// users take it, so deciding who is the first user or the last admin
+// can not race.
+func (q *Queries) LockUserAccounts(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, lockUserAccounts)
+	return err
+}
+
+const setUserDisabled = `-- name: SetUserDisabled :one
+UPDATE users
+SET disabled_at = $1,
+    updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, name, username, password_hash, is_admin, disabled_at
+`
+
+type SetUserDisabledParams struct {
+	DisabledAt sql.NullTime
+	UpdatedAt  time.Time
+	ID         uuid.UUID
+}
+
+// Pass a time to disable the user, or NULL to enable them again.
+func (q *Queries) SetUserDisabled(ctx context.Context, arg SetUserDisabledParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserDisabled, arg.DisabledAt, arg.UpdatedAt, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Username,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const setUserPassword = `-- name: SetUserPassword :one
+UPDATE users
+SET username = $1,
+    password_hash = $2,
+    updated_at = $3
+WHERE id = $4
+RETURNING id, created_at, updated_at, name, username, password_hash, is_admin, disabled_at
+`
+
+type SetUserPasswordParams struct {
+	Username     sql.NullString
+	PasswordHash sql.NullString
+	UpdatedAt    time.Time
+	ID           uuid.UUID
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserPassword,
+		arg.Username,
+		arg.PasswordHash,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Username,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
+	)
+	return i, err
+}
+
+const updateUserName = `-- name: UpdateUserName :one
+UPDATE users
+SET name = $1,
+    updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, name, username, password_hash, is_admin, disabled_at
+`
+
+type UpdateUserNameParams struct {
+	Name      string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateUserName(ctx context.Context, arg UpdateUserNameParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserName, arg.Name, arg.UpdatedAt, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
-		&i.ApiKey,
+		&i.Username,
+		&i.PasswordHash,
+		&i.IsAdmin,
+		&i.DisabledAt,
 	)
 	return i, err
 }
@@ -0,0 +1,611 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const claimDueWebhookDeliveries = `-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries d
+SET next_attempt_at = $1,
+    updated_at = $2
+FROM webhooks w
+WHERE w.id = d.webhook_id
+  AND d.id IN (
+      SELECT dd.id
+      FROM webhook_deliveries dd
+      JOIN webhooks ww ON ww.id = dd.webhook_id
+      WHERE dd.status = 'pending'
+        AND dd.next_attempt_at <= $2
+        AND ww.enabled
+      ORDER BY dd.next_attempt_at
+      LIMIT $3
+      FOR UPDATE OF dd SKIP LOCKED
+  )
+RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret
+`
+
+type ClaimDueWebhookDeliveriesParams struct {
+	LeaseUntil time.Time
+	Now        time.Time
+	RowLimit   int32
+}
+
+type ClaimDueWebhookDeliveriesRow struct {
+	ID        uuid.UUID
+	WebhookID uuid.UUID
+	Event     string
+	Payload   string
+	Attempts  int32
+	Url       string
+	Secret    string
+}
+
+// Takes due deliveries to enabled webhooks and pushes their next attempt
+// to @lease_until, so a worker that dies mid-delivery doesn't lose them and
+// concurrent workers don't send them twice.
+func (q *Queries) ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]ClaimDueWebhookDeliveriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueWebhookDeliveries, arg.LeaseUntil, arg.Now, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimDueWebhookDeliveriesRow
+	for rows.Next() {
+		var i ClaimDueWebhookDeliveriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.Event,
+			&i.Payload,
+			&i.Attempts,
+			&i.Url,
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, feed_id, folder_id, rule_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, created_at, updated_at, user_id, url, secret, feed_id, folder_id, rule_id, enabled, consecutive_failures, disabled_at, disabled_reason
+`
+
+type CreateWebhookParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	FeedID    uuid.NullUUID
+	FolderID  uuid.NullUUID
+	RuleID    uuid.NullUUID
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+		arg.FeedID,
+		arg.FolderID,
+		arg.RuleID,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.FeedID,
+		&i.FolderID,
+		&i.RuleID,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.DisabledReason,
+	)
+	return i, err
+}
+
+const createWebhookDeliveryAttempt = `-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, response_status, error, duration_ms)
+VALUES ($1, $2, $3, $4, $5, $6)
+`
+
+type CreateWebhookDeliveryAttemptParams struct {
+	ID             uuid.UUID
+	DeliveryID     uuid.UUID
+	AttemptedAt    time.Time
+	ResponseStatus sql.NullInt32
+	Error          string
+	DurationMs     int32
+}
+
+func (q *Queries) CreateWebhookDeliveryAttempt(ctx context.Context, arg CreateWebhookDeliveryAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookDeliveryAttempt,
+		arg.ID,
+		arg.DeliveryID,
+		arg.AttemptedAt,
+		arg.ResponseStatus,
+		arg.Error,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const deleteOldWebhookDeliveries = `-- name: DeleteOldWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending' AND updated_at < $1
+`
+
+func (q *Queries) DeleteOldWebhookDeliveries(ctx context.Context, updatedAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOldWebhookDeliveries, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteWebhookParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhook, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableWebhook = `-- name: DisableWebhook :exec
+UPDATE webhooks
+SET enabled = FALSE,
+    disabled_at = $1::timestamp,
+    disabled_reason = $2,
+    updated_at = $1
+WHERE id = $3 AND enabled
+`
+
+type DisableWebhookParams struct {
+	Now    time.Time
+	Reason string
+	ID     uuid.UUID
+}
+
+func (q *Queries) DisableWebhook(ctx context.Context, arg DisableWebhookParams) error {
+	_, err := q.db.ExecContext(ctx, disableWebhook, arg.Now, arg.Reason, arg.ID)
+	return err
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, post_id, event, payload, next_attempt_at)
+SELECT gen_random_uuid(), $1, $1, w.id, $2, $3, $4, $1
+FROM webhooks w
+JOIN feed_follows ff ON ff.user_id = w.user_id AND ff.feed_id = $5
+WHERE w.enabled
+  AND (
+      (w.feed_id IS NULL AND w.folder_id IS NULL AND w.rule_id IS NULL)
+      OR w.feed_id = $5
+      OR w.folder_id = ff.folder_id
+      OR w.rule_id = ANY($6::uuid[])
+  )
+ON CONFLICT (webhook_id, post_id, event) DO NOTHING
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	Now            time.Time
+	PostID         uuid.UUID
+	Event          string
+	Payload        string
+	FeedID         uuid.UUID
+	MatchedRuleIds []uuid.UUID
+}
+
+// Queues a delivery of the post to each enabled webhook whose owner
+// follows its feed and whose filter, if any, covers it: the feed itself,
+// the folder the follow is in, or a rule that matched the post.
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enqueueWebhookDeliveries,
+		arg.Now,
+		arg.PostID,
+		arg.Event,
+		arg.Payload,
+		arg.FeedID,
+		pq.Array(arg.MatchedRuleIds),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, created_at, updated_at, user_id, url, secret, feed_id, folder_id, rule_id, enabled, consecutive_failures, disabled_at, disabled_reason
+FROM webhooks
+WHERE id = $1 AND user_id = $2
+`
+
+type GetWebhookParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetWebhook(ctx context.Context, arg GetWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, arg.ID, arg.UserID)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.FeedID,
+		&i.FolderID,
+		&i.RuleID,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.DisabledReason,
+	)
+	return i, err
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+SELECT id, created_at, updated_at, webhook_id, post_id, event, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, delivered_at
+FROM webhook_deliveries
+WHERE webhook_id = $1
+  AND ($2::text IS NULL OR status = $2)
+ORDER BY created_at DESC
+LIMIT $4 OFFSET $3
+`
+
+type GetWebhookDeliveriesParams struct {
+	WebhookID uuid.UUID
+	Status    sql.NullString
+	RowOffset int32
+	RowLimit  int32
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveries,
+		arg.WebhookID,
+		arg.Status,
+		arg.RowOffset,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.WebhookID,
+			&i.PostID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.LastError,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, created_at, updated_at, webhook_id, post_id, event, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, delivered_at
+FROM webhook_deliveries
+WHERE id = $1 AND webhook_id = $2
+`
+
+type GetWebhookDeliveryParams struct {
+	ID        uuid.UUID
+	WebhookID uuid.UUID
+}
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, arg GetWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookDelivery, arg.ID, arg.WebhookID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.WebhookID,
+		&i.PostID,
+		&i.Event,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.LastError,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const getWebhookDeliveryAttempts = `-- name: GetWebhookDeliveryAttempts :many
+SELECT id, delivery_id, attempted_at, response_status, error, duration_ms
+FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at
+`
+
+func (q *Queries) GetWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]WebhookDeliveryAttempt, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveryAttempts, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDeliveryAttempt
+	for rows.Next() {
+		var i WebhookDeliveryAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.AttemptedAt,
+			&i.ResponseStatus,
+			&i.Error,
+			&i.DurationMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhooksForUser = `-- name: GetWebhooksForUser :many
+SELECT id, created_at, updated_at, user_id, url, secret, feed_id, folder_id, rule_id, enabled, consecutive_failures, disabled_at, disabled_reason
+FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetWebhooksForUser(ctx context.Context, userID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooksForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Url,
+			&i.Secret,
+			&i.FeedID,
+			&i.FolderID,
+			&i.RuleID,
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+			&i.DisabledReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const incrementWebhookFailures = `-- name: IncrementWebhookFailures :one
+UPDATE webhooks
+SET consecutive_failures = consecutive_failures + 1
+WHERE id = $1
+RETURNING consecutive_failures
+`
+
+func (q *Queries) IncrementWebhookFailures(ctx context.Context, id uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, incrementWebhookFailures, id)
+	var consecutive_failures int32
+	err := row.Scan(&consecutive_failures)
+	return consecutive_failures, err
+}
+
+const markWebhookDeliveryFailed = `-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = CASE WHEN $1::boolean THEN 'failed' ELSE 'pending' END,
+    attempts = attempts + 1,
+    last_attempt_at = $2::timestamp,
+    response_status = $3,
+    last_error = $4,
+    next_attempt_at = $5,
+    updated_at = $2
+WHERE id = $6
+`
+
+type MarkWebhookDeliveryFailedParams struct {
+	GiveUp         bool
+	Now            time.Time
+	ResponseStatus sql.NullInt32
+	LastError      string
+	NextAttemptAt  time.Time
+	ID             uuid.UUID
+}
+
+// Records a failed attempt. The delivery stays pending until
+// @next_attempt_at unless @give_up is set.
+func (q *Queries) MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markWebhookDeliveryFailed,
+		arg.GiveUp,
+		arg.Now,
+		arg.ResponseStatus,
+		arg.LastError,
+		arg.NextAttemptAt,
+		arg.ID,
+	)
+	return err
+}
+
+const markWebhookDeliverySucceeded = `-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    attempts = attempts + 1,
+    last_attempt_at = $1::timestamp,
+    response_status = $2::int,
+    last_error = '',
+    delivered_at = $1::timestamp,
+    updated_at = $1
+WHERE id = $3
+`
+
+type MarkWebhookDeliverySucceededParams struct {
+	Now            time.Time
+	ResponseStatus int32
+	ID             uuid.UUID
+}
+
+func (q *Queries) MarkWebhookDeliverySucceeded(ctx context.Context, arg MarkWebhookDeliverySucceededParams) error {
+	_, err := q.db.ExecContext(ctx, markWebhookDeliverySucceeded, arg.Now, arg.ResponseStatus, arg.ID)
+	return err
+}
+
+const resetWebhookFailures = `-- name: ResetWebhookFailures :exec
+UPDATE webhooks
+SET consecutive_failures = 0
+WHERE id = $1 AND consecutive_failures <> 0
+`
+
+func (q *Queries) ResetWebhookFailures(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resetWebhookFailures, id)
+	return err
+}
+
+const retryWebhookDelivery = `-- name: RetryWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending',
+    next_attempt_at = $1,
+    updated_at = $1
+WHERE id = $2 AND status <> 'pending'
+`
+
+type RetryWebhookDeliveryParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+// Queues a finished delivery to be sent again straight away.
+func (q *Queries) RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, retryWebhookDelivery, arg.Now, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateWebhook = `-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = $1,
+    enabled = $2,
+    consecutive_failures = CASE WHEN $2::boolean AND NOT enabled THEN 0 ELSE consecutive_failures END,
+    disabled_at = CASE WHEN $2::boolean THEN NULL ELSE COALESCE(disabled_at, $3) END,
+    disabled_reason = CASE WHEN $2::boolean THEN '' ELSE disabled_reason END,
+    updated_at = $3
+WHERE id = $4 AND user_id = $5
+RETURNING id, created_at, updated_at, user_id, url, secret, feed_id, folder_id, rule_id, enabled, consecutive_failures, disabled_at, disabled_reason
+`
+
+type UpdateWebhookParams struct {
+	Url       string
+	Enabled   bool
+	UpdatedAt time.Time
+	ID        uuid.UUID
+	UserID    uuid.UUID
+}
+
+// Re-enabling a webhook clears its failure count and disabled reason.
+func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, updateWebhook,
+		arg.Url,
+		arg.Enabled,
+		arg.UpdatedAt,
+		arg.ID,
+		arg.UserID,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+		&i.FeedID,
+		&i.FolderID,
+		&i.RuleID,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.DisabledReason,
+	)
+	return i, err
+}
@@ -0,0 +1,280 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: websub.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const activateWebSubSubscription = `-- name: ActivateWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = 'active',
+    lease_expires_at = $1::timestamp,
+    last_error = '',
+    updated_at = $2
+WHERE id = $3
+`
+
+type ActivateWebSubSubscriptionParams struct {
+	LeaseExpiresAt time.Time
+	Now            time.Time
+	ID             uuid.UUID
+}
+
+func (q *Queries) ActivateWebSubSubscription(ctx context.Context, arg ActivateWebSubSubscriptionParams) error {
+	_, err := q.db.ExecContext(ctx, activateWebSubSubscription, arg.LeaseExpiresAt, arg.Now, arg.ID)
+	return err
+}
+
+const deleteWebSubSubscriptionForFeed = `-- name: DeleteWebSubSubscriptionForFeed :execrows
+DELETE FROM websub_subscriptions
+WHERE feed_id = $1
+`
+
+func (q *Queries) DeleteWebSubSubscriptionForFeed(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebSubSubscriptionForFeed, feedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const denyWebSubSubscription = `-- name: DenyWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = 'denied',
+    lease_expires_at = NULL,
+    last_error = $1,
+    updated_at = $2
+WHERE id = $3
+`
+
+type DenyWebSubSubscriptionParams struct {
+	Reason string
+	Now    time.Time
+	ID     uuid.UUID
+}
+
+func (q *Queries) DenyWebSubSubscription(ctx context.Context, arg DenyWebSubSubscriptionParams) error {
+	_, err := q.db.ExecContext(ctx, denyWebSubSubscription, arg.Reason, arg.Now, arg.ID)
+	return err
+}
+
+const failWebSubSubscription = `-- name: FailWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = CASE WHEN state = 'active' THEN state ELSE 'failed' END,
+    last_error = $1,
+    updated_at = $2
+WHERE id = $3
+`
+
+type FailWebSubSubscriptionParams struct {
+	LastError string
+	Now       time.Time
+	ID        uuid.UUID
+}
+
+// A failed renewal leaves an active subscription alone until its lease
+// runs out; anything else is marked failed.
+func (q *Queries) FailWebSubSubscription(ctx context.Context, arg FailWebSubSubscriptionParams) error {
+	_, err := q.db.ExecContext(ctx, failWebSubSubscription, arg.LastError, arg.Now, arg.ID)
+	return err
+}
+
+const getWebSubSubscription = `-- name: GetWebSubSubscription :one
+SELECT id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state, requested_at, lease_expires_at, last_push_at, last_error
+FROM websub_subscriptions
+WHERE id = $1
+`
+
+func (q *Queries) GetWebSubSubscription(ctx context.Context, id uuid.UUID) (WebsubSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebSubSubscription, id)
+	var i WebsubSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.HubUrl,
+		&i.TopicUrl,
+		&i.Secret,
+		&i.State,
+		&i.RequestedAt,
+		&i.LeaseExpiresAt,
+		&i.LastPushAt,
+		&i.LastError,
+	)
+	return i, err
+}
+
+const getWebSubSubscriptionForFeed = `-- name: GetWebSubSubscriptionForFeed :one
+SELECT id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state, requested_at, lease_expires_at, last_push_at, last_error
+FROM websub_subscriptions
+WHERE feed_id = $1
+`
+
+func (q *Queries) GetWebSubSubscriptionForFeed(ctx context.Context, feedID uuid.UUID) (WebsubSubscription, error) {
+	row := q.db.QueryRowContext(ctx, getWebSubSubscriptionForFeed, feedID)
+	var i WebsubSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.HubUrl,
+		&i.TopicUrl,
+		&i.Secret,
+		&i.State,
+		&i.RequestedAt,
+		&i.LeaseExpiresAt,
+		&i.LastPushAt,
+		&i.LastError,
+	)
+	return i, err
+}
+
+const getWebSubSubscriptionsToRenew = `-- name: GetWebSubSubscriptionsToRenew :many
+SELECT s.id, s.created_at, s.updated_at, s.feed_id, s.hub_url, s.topic_url, s.secret, s.state, s.requested_at, s.lease_expires_at, s.last_push_at, s.last_error
+FROM websub_subscriptions s
+JOIN feeds f ON f.id = s.feed_id
+WHERE s.requested_at < $1::timestamp
+  AND (s.state <> 'active' OR s.lease_expires_at < $2::timestamp)
+  AND f.deactivated_at IS NULL
+ORDER BY s.requested_at
+LIMIT $3::int
+`
+
+type GetWebSubSubscriptionsToRenewParams struct {
+	RetryBefore time.Time
+	RenewBefore time.Time
+	RowLimit    int32
+}
+
+// Active subscriptions whose lease ends before @renew_before, and ones that
+// never became active, as long as nothing was requested since
+// @retry_before. Subscriptions for feeds nobody follows are left to lapse.
+func (q *Queries) GetWebSubSubscriptionsToRenew(ctx context.Context, arg GetWebSubSubscriptionsToRenewParams) ([]WebsubSubscription, error) {
+	rows, err := q.db.QueryContext(ctx, getWebSubSubscriptionsToRenew, arg.RetryBefore, arg.RenewBefore, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebsubSubscription
+	for rows.Next() {
+		var i WebsubSubscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FeedID,
+			&i.HubUrl,
+			&i.TopicUrl,
+			&i.Secret,
+			&i.State,
+			&i.RequestedAt,
+			&i.LeaseExpiresAt,
+			&i.LastPushAt,
+			&i.LastError,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markWebSubPush = `-- name: MarkWebSubPush :exec
+UPDATE websub_subscriptions
+SET last_push_at = $1::timestamp, updated_at = $1
+WHERE id = $2
+`
+
+type MarkWebSubPushParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) MarkWebSubPush(ctx context.Context, arg MarkWebSubPushParams) error {
+	_, err := q.db.ExecContext(ctx, markWebSubPush, arg.Now, arg.ID)
+	return err
+}
+
+const markWebSubRequested = `-- name: MarkWebSubRequested :exec
+UPDATE websub_subscriptions
+SET requested_at = $1, updated_at = $1
+WHERE id = $2
+`
+
+type MarkWebSubRequestedParams struct {
+	Now time.Time
+	ID  uuid.UUID
+}
+
+func (q *Queries) MarkWebSubRequested(ctx context.Context, arg MarkWebSubRequestedParams) error {
+	_, err := q.db.ExecContext(ctx, markWebSubRequested, arg.Now, arg.ID)
+	return err
+}
+
+const upsertWebSubSubscription = `-- name: UpsertWebSubSubscription :one
+INSERT INTO websub_subscriptions (id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state, requested_at)
+VALUES ($1, $2, $2, $3, $4, $5, $6, 'pending', $2)
+ON CONFLICT (feed_id) DO UPDATE
+SET hub_url = EXCLUDED.hub_url,
+    topic_url = EXCLUDED.topic_url,
+    secret = EXCLUDED.secret,
+    state = 'pending',
+    requested_at = EXCLUDED.requested_at,
+    lease_expires_at = NULL,
+    last_error = '',
+    updated_at = EXCLUDED.updated_at
+RETURNING id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state, requested_at, lease_expires_at, last_push_at, last_error
+`
+
+type UpsertWebSubSubscriptionParams struct {
+	ID       uuid.UUID
+	Now      time.Time
+	FeedID   uuid.UUID
+	HubUrl   string
+	TopicUrl string
+	Secret   string
+}
+
+// Starts a new subscription for the feed, replacing any earlier one. The id
+// of an existing row is kept so its callback URL stays the same.
+func (q *Queries) UpsertWebSubSubscription(ctx context.Context, arg UpsertWebSubSubscriptionParams) (WebsubSubscription, error) {
+	row := q.db.QueryRowContext(ctx, upsertWebSubSubscription,
+		arg.ID,
+		arg.Now,
+		arg.FeedID,
+		arg.HubUrl,
+		arg.TopicUrl,
+		arg.Secret,
+	)
+	var i WebsubSubscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FeedID,
+		&i.HubUrl,
+		&i.TopicUrl,
+		&i.Secret,
+		&i.State,
+		&i.RequestedAt,
+		&i.LeaseExpiresAt,
+		&i.LastPushAt,
+		&i.LastError,
+	)
+	return i, err
+}
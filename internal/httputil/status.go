@@ -0,0 +1,74 @@
+package httputil
+
+import (
+    "bufio"
+    "errors"
+    "net"
+    "net/http"
+)
+
+// StatusRecorder records the status and size of a response for middleware
+// that logs or measures requests. It passes through Flush for server-sent
+// events and Hijack for WebSockets.
+type StatusRecorder struct {
+    http.ResponseWriter
+    status int
+    bytes  int64
+}
+
+// NewStatusRecorder wraps w.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+    return &StatusRecorder{ResponseWriter: w}
+}
+
+// Status is the status code sent, or 200 if the handler never set one.
+func (w *StatusRecorder) Status() int {
+    if w.status == 0 {
+        return http.StatusOK
+    }
+    return w.status
+}
+
+// Bytes is how much of the body has been written.
+func (w *StatusRecorder) Bytes() int64 {
+    return w.bytes
+}
+
+func (w *StatusRecorder) WriteHeader(status int) {
+    if w.status == 0 {
+        w.status = status
+    }
+    w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *StatusRecorder) Write(b []byte) (int, error) {
+    if w.status == 0 {
+        w.status = http.StatusOK
+    }
+    n, err := w.ResponseWriter.Write(b)
+    w.bytes += int64(n)
+    return n, err
+}
+
+func (w *StatusRecorder) Flush() {
+    if f, ok := w.ResponseWriter.(http.Flusher); ok {
+        f.Flush()
+    }
+}
+
+func (w *StatusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+    h, ok := w.ResponseWriter.(http.Hijacker)
+    if !ok {
+        return nil, nil, errors.New("httputil: response writer does not support hijacking")
+    }
+    // A hijacked connection is upgraded, usually to a WebSocket.
+    if w.status == 0 {
+        w.status = http.StatusSwitchingProtocols
+    }
+    return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *StatusRecorder) Unwrap() http.ResponseWriter {
+    return w.ResponseWriter
+}
@@ -0,0 +1,186 @@
+package icons
+
+import (
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "net/url"
+    "strings"
+
+    "golang.org/x/net/html"
+)
+
+// maxIconSize caps how large an icon we are willing to download and store.
+const maxIconSize = 1 << 20
+
+// maxPageSize caps how much of a site's homepage we read looking for icons.
+const maxPageSize = 2 << 20
+
+// ErrNoIcon is returned by Resolve when none of the candidate locations
+// yields an image.
+var ErrNoIcon = errors.New("no icon found")
+
+// Icon is a downloaded feed icon.
+type Icon struct {
+    URL         string
+    ContentType string
+    Data        []byte
+    Hash        string
+}
+
+// Resolve finds and downloads an icon for a feed, trying in order the
+// feed's own image (RSS <image>, Atom <icon>/<logo>), the site's
+// <link rel="icon"> and finally the site's /favicon.ico.
+func Resolve(ctx context.Context, client *http.Client, imageURL, siteURL string) (*Icon, error) {
+    var candidates []string
+    if imageURL != "" {
+        candidates = append(candidates, imageURL)
+    }
+
+    if site, err := url.Parse(siteURL); err == nil && site.Host != "" {
+        candidates = append(candidates, siteIconLinks(ctx, client, site)...)
+        candidates = append(candidates, (&url.URL{Scheme: site.Scheme, Host: site.Host, Path: "/favicon.ico"}).String())
+    }
+
+    seen := map[string]bool{}
+    for _, c := range candidates {
+        if seen[c] {
+            continue
+        }
+        seen[c] = true
+
+        icon, err := download(ctx, client, c)
+        if err == nil {
+            return icon, nil
+        }
+    }
+
+    return nil, ErrNoIcon
+}
+
+// download fetches iconURL and returns it if it is an image.
+func download(ctx context.Context, client *http.Client, iconURL string) (*Icon, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, iconURL, nil)
+    if err != nil {
+        return nil, fmt.Errorf("create request: %w", err)
+    }
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil, fmt.Errorf("do request: %w", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+    }
+
+    data, err := io.ReadAll(io.LimitReader(resp.Body, maxIconSize+1))
+    if err != nil {
+        return nil, fmt.Errorf("read body: %w", err)
+    }
+    if len(data) == 0 {
+        return nil, errors.New("empty icon")
+    }
+    if len(data) > maxIconSize {
+        return nil, errors.New("icon too large")
+    }
+
+    contentType := imageContentType(resp.Header.Get("Content-Type"), data)
+    if contentType == "" {
+        return nil, errors.New("not an image")
+    }
+
+    sum := sha256.Sum256(data)
+    return &Icon{
+        URL:         iconURL,
+        ContentType: contentType,
+        Data:        data,
+        Hash:        hex.EncodeToString(sum[:]),
+    }, nil
+}
+
+// imageContentType returns the image media type of data, preferring the
+// server's Content-Type and falling back to sniffing. It returns "" for
+// anything that isn't an image.
+func imageContentType(header string, data []byte) string {
+    if mt, _, err := mime.ParseMediaType(header); err == nil && strings.HasPrefix(mt, "image/") {
+        return mt
+    }
+    sniffed := http.DetectContentType(data)
+    if strings.HasPrefix(sniffed, "image/") {
+        return sniffed
+    }
+    // http.DetectContentType doesn't know SVG.
+    if bytes.Contains(data[:min(len(data), 512)], []byte("<svg")) {
+        return "image/svg+xml"
+    }
+    return ""
+}
+
+// siteIconLinks returns the icons a site's homepage declares through
+// <link rel="icon">, <link rel="shortcut icon"> or
+// <link rel="apple-touch-icon">, resolved against the page URL.
+func siteIconLinks(ctx context.Context, client *http.Client, site *url.URL) []string {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, site.String(), nil)
+    if err != nil {
+        return nil
+    }
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return nil
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil
+    }
+
+    doc, err := html.Parse(io.LimitReader(resp.Body, maxPageSize))
+    if err != nil {
+        return nil
+    }
+
+    base := resp.Request.URL
+    var links []string
+
+    var walk func(n *html.Node)
+    walk = func(n *html.Node) {
+        if n.Type == html.ElementNode {
+            switch n.Data {
+            case "link":
+                rel := strings.ToLower(attr(n, "rel"))
+                if !strings.Contains(rel, "icon") {
+                    break
+                }
+                if u, err := base.Parse(strings.TrimSpace(attr(n, "href"))); err == nil && u.Host != "" {
+                    links = append(links, u.String())
+                }
+            case "body":
+                return
+            }
+        }
+        for c := n.FirstChild; c != nil; c = c.NextSibling {
+            walk(c)
+        }
+    }
+    walk(doc)
+
+    return links
+}
+
+func attr(n *html.Node, key string) string {
+    for _, a := range n.Attr {
+        if strings.EqualFold(a.Key, key) {
+            return a.Val
+        }
+    }
+    return ""
+}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "log/slog"
+    "net/http"
+    "time"
+
+    "github.com/go-chi/chi/v5"
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+)
+
+// RequestIDHeader carries the request ID to and from clients and proxies.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID gives every request an ID, reusing the one in X-Request-ID if
+// the client or a proxy sent a sensible one, and echoes it in the response.
+func RequestID(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id := r.Header.Get(RequestIDHeader)
+        if !validRequestID(id) {
+            id = newRequestID()
+        }
+        w.Header().Set(RequestIDHeader, id)
+        next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+    })
+}
+
+// validRequestID accepts up to 128 printable ASCII characters, so IDs from
+// outside can't break up log lines or fill the logs.
+func validRequestID(id string) bool {
+    if id == "" || len(id) > 128 {
+        return false
+    }
+    for i := 0; i < len(id); i++ {
+        if id[i] < 0x21 || id[i] > 0x7e {
+            return false
+        }
+    }
+    return true
+}
+
+func newRequestID() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return uuid.NewString()
+    }
+    return hex.EncodeToString(b)
+}
+
+// accessInfo is filled in while a request is handled, for its access log
+// line.
+type accessInfo struct {
+    userID uuid.UUID
+}
+
+type accessInfoKey struct{}
+
+// SetUserID records which user a request was authenticated as, for the
+// access log.
+func SetUserID(ctx context.Context, id uuid.UUID) {
+    if info, ok := ctx.Value(accessInfoKey{}).(*accessInfo); ok {
+        info.userID = id
+    }
+}
+
+// AccessLog logs one line per request once it has been handled, with its
+// method, route pattern, status, size, latency and user.
+func AccessLog(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        info := &accessInfo{}
+        sw := httputil.NewStatusRecorder(w)
+
+        next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), accessInfoKey{}, info)))
+
+        status := sw.Status()
+
+        attrs := []slog.Attr{
+            slog.String("method", r.Method),
+            slog.String("path", r.URL.Path),
+            slog.String("route", RoutePattern(r)),
+            slog.Int("status", status),
+            slog.Int64("bytes", sw.Bytes()),
+            slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+            slog.String("remote_addr", r.RemoteAddr),
+        }
+        if info.userID != uuid.Nil {
+            attrs = append(attrs, slog.String("user_id", info.userID.String()))
+        }
+
+        level := slog.LevelInfo
+        if status >= 500 {
+            level = slog.LevelError
+        }
+        slog.LogAttrs(r.Context(), level, "request", attrs...)
+    })
+}
+
+// RoutePattern returns the chi route pattern a request matched, such as
+// /v1/posts/{postID}, or "" if it matched none.
+func RoutePattern(r *http.Request) string {
+    if rctx := chi.RouteContext(r.Context()); rctx != nil {
+        return rctx.RoutePattern()
+    }
+    return ""
+}
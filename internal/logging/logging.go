@@ -0,0 +1,66 @@
+// Package logging sets up structured JSON logging and the HTTP middleware
+// that tags requests with an ID and logs them.
+package logging
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "log/slog"
+    "strings"
+)
+
+// ParseLevel parses a level name: debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+    var level slog.Level
+    if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+        return 0, fmt.Errorf("invalid log level %q: must be debug, info, warn or error", s)
+    }
+    return level, nil
+}
+
+// Setup makes slog's default logger write JSON lines at level and above to
+// w. Messages logged with a request's context carry its request_id, and
+// anything still using the log package goes through slog too.
+func Setup(w io.Writer, level slog.Level) {
+    h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+    slog.SetDefault(slog.New(contextHandler{h}))
+}
+
+// contextHandler adds the request ID from the context to each record.
+type contextHandler struct {
+    slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+    if id, ok := RequestIDFromContext(ctx); ok {
+        r.AddAttrs(slog.String("request_id", id))
+    }
+    return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+    return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying a request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+    return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+    id, ok := ctx.Value(requestIDKey{}).(string)
+    return id, ok
+}
+
+// Err is an attribute for an error, logged under "error".
+func Err(err error) slog.Attr {
+    return slog.Any("error", err)
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+)
+
+// unmatchedRoute labels requests that matched no route, so probes for
+// random paths can't each make a new series.
+const unmatchedRoute = "unmatched"
+
+// Middleware counts and times requests by method, route pattern and status.
+func Middleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        sw := httputil.NewStatusRecorder(w)
+
+        next.ServeHTTP(sw, r)
+
+        route := logging.RoutePattern(r)
+        if route == "" {
+            route = unmatchedRoute
+        }
+        method := methodLabel(r.Method)
+        status := strconv.Itoa(sw.Status())
+
+        httpRequests.WithLabelValues(method, route, status).Inc()
+        httpDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+    })
+}
+
+// methodLabel passes through the standard methods and lumps anything else
+// together, for the same reason as unmatchedRoute.
+func methodLabel(method string) string {
+    switch method {
+    case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+        http.MethodPatch, http.MethodDelete, http.MethodOptions:
+        return method
+    }
+    return "other"
+}
@@ -0,0 +1,137 @@
+// Package metrics collects Prometheus metrics for the HTTP API, the feed
+// worker and the database pool, and serves them for scraping.
+package metrics
+
+import (
+    "context"
+    "database/sql"
+    "log/slog"
+    "net/http"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/collectors"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+
+    "github.com/mdbailin/go-rss-server/internal/logging"
+)
+
+// registry holds everything we export. It is our own rather than
+// Prometheus' global one so only what we register here is served.
+var registry = prometheus.NewRegistry()
+
+var (
+    httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "http_requests_total",
+        Help: "HTTP requests handled, by method, route pattern and status.",
+    }, []string{"method", "route", "status"})
+
+    httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Name:    "http_request_duration_seconds",
+        Help:    "How long HTTP requests took to handle, by method, route pattern and status. Streams are observed when they close.",
+        Buckets: prometheus.DefBuckets,
+    }, []string{"method", "route", "status"})
+
+    feedFetches = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "feed_fetches_total",
+        Help: "Feeds polled by the feed worker, by result (ok or error).",
+    }, []string{"result"})
+
+    feedFetchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+        Name:    "feed_fetch_duration_seconds",
+        Help:    "How long fetching and parsing a feed took, successful or not.",
+        Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+    })
+
+    feedFetchErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "feed_fetch_errors_total",
+        Help: "Failed feed fetches, by kind of failure.",
+    }, []string{"kind"})
+
+    postsInserted = prometheus.NewCounter(prometheus.CounterOpts{
+        Name: "posts_inserted_total",
+        Help: "Feed items stored as new posts, polled or pushed over WebSub.",
+    })
+
+    postsDuplicate = prometheus.NewCounter(prometheus.CounterOpts{
+        Name: "posts_duplicates_skipped_total",
+        Help: "Feed items skipped because they were already stored.",
+    })
+)
+
+func init() {
+    registry.MustRegister(
+        collectors.NewGoCollector(),
+        collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+        httpRequests,
+        httpDuration,
+        feedFetches,
+        feedFetchDuration,
+        feedFetchErrors,
+        postsInserted,
+        postsDuplicate,
+    )
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+    return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exports the connection pool stats of db, from db.Stats(), as
+// the go_sql_* metrics.
+func RegisterDB(db *sql.DB, name string) {
+    registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterQueueLag exports feed_queue_lag_seconds, which lag computes when
+// the metrics are scraped. A lag that can't be computed is left out of
+// that scrape.
+func RegisterQueueLag(lag func(ctx context.Context) (float64, error)) {
+    registry.MustRegister(&queueLagCollector{lag: lag})
+}
+
+var queueLagDesc = prometheus.NewDesc(
+    "feed_queue_lag_seconds",
+    "Time since the least recently fetched active feed was last fetched.",
+    nil, nil,
+)
+
+type queueLagCollector struct {
+    lag func(ctx context.Context) (float64, error)
+}
+
+func (c *queueLagCollector) Describe(ch chan<- *prometheus.Desc) {
+    ch <- queueLagDesc
+}
+
+func (c *queueLagCollector) Collect(ch chan<- prometheus.Metric) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+
+    lag, err := c.lag(ctx)
+    if err != nil {
+        slog.Error("computing feed queue lag failed", logging.Err(err))
+        return
+    }
+    ch <- prometheus.MustNewConstMetric(queueLagDesc, prometheus.GaugeValue, lag)
+}
+
+// FeedFetched records one poll of a feed that took d. errKind says how it
+// failed, or is "" if it succeeded.
+func FeedFetched(d time.Duration, errKind string) {
+    feedFetchDuration.Observe(d.Seconds())
+    if errKind == "" {
+        feedFetches.WithLabelValues("ok").Inc()
+        return
+    }
+    feedFetches.WithLabelValues("error").Inc()
+    feedFetchErrors.WithLabelValues(errKind).Inc()
+}
+
+// PostsIngested records what was done with the items of one copy of a
+// feed.
+func PostsIngested(inserted, duplicates int) {
+    postsInserted.Add(float64(inserted))
+    postsDuplicate.Add(float64(duplicates))
+}
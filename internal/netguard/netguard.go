@@ -0,0 +1,203 @@
+package netguard
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "strings"
+    "syscall"
+    "time"
+)
+
+// ErrBlocked is returned when a URL or connection targets an address that
+// outbound fetches may not reach.
+var ErrBlocked = errors.New("destination not allowed")
+
+// blockedNets are special-purpose ranges not covered by the net.IP helpers
+// used in isBlockedIP.
+var blockedNets = mustParseCIDRs(
+    "0.0.0.0/8",       // "this" network
+    "100.64.0.0/10",   // carrier-grade NAT, also used by some cloud metadata services
+    "192.0.0.0/24",    // IETF protocol assignments
+    "192.0.2.0/24",    // TEST-NET-1
+    "198.18.0.0/15",   // benchmarking
+    "198.51.100.0/24", // TEST-NET-2
+    "203.0.113.0/24",  // TEST-NET-3
+    "240.0.0.0/4",     // reserved, including broadcast
+    "64:ff9b::/96",    // NAT64, can embed any IPv4 address
+    "2001:db8::/32",   // documentation
+)
+
+// Guard decides which network destinations outbound fetches may reach.
+// Private, loopback, link-local (including cloud metadata) and other
+// non-public addresses are rejected unless explicitly allowlisted.
+type Guard struct {
+    allowNets  []*net.IPNet
+    allowHosts map[string]bool
+}
+
+// New builds a Guard. Each allowlist entry is a CIDR range, a single IP or a
+// hostname, for feeds that are intentionally served from internal hosts.
+func New(allowlist []string) (*Guard, error) {
+    g := &Guard{allowHosts: map[string]bool{}}
+    for _, entry := range allowlist {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+        if _, n, err := net.ParseCIDR(entry); err == nil {
+            g.allowNets = append(g.allowNets, n)
+            continue
+        }
+        if ip := net.ParseIP(entry); ip != nil {
+            bits := 8 * len(ip.To16())
+            if ip.To4() != nil {
+                ip, bits = ip.To4(), 32
+            }
+            g.allowNets = append(g.allowNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+            continue
+        }
+        if strings.ContainsAny(entry, "/:") {
+            return nil, fmt.Errorf("invalid allowlist entry %q", entry)
+        }
+        g.allowHosts[strings.ToLower(entry)] = true
+    }
+    return g, nil
+}
+
+// ValidateURL checks a user-submitted URL before it is stored: it must be
+// http or https and its host must resolve only to allowed addresses.
+func (g *Guard) ValidateURL(ctx context.Context, rawURL string) error {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return fmt.Errorf("invalid url: %w", err)
+    }
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return fmt.Errorf("%w: scheme %q", ErrBlocked, u.Scheme)
+    }
+    if u.User != nil {
+        return fmt.Errorf("%w: urls with credentials", ErrBlocked)
+    }
+
+    host := u.Hostname()
+    if host == "" {
+        return errors.New("invalid url: missing host")
+    }
+    if g.allowHosts[strings.ToLower(host)] {
+        return nil
+    }
+
+    ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+    if err != nil {
+        return fmt.Errorf("resolve %s: %w", host, err)
+    }
+    for _, ip := range ips {
+        if !g.allowedIP(ip.IP) {
+            return fmt.Errorf("%w: %s resolves to %s", ErrBlocked, host, ip.IP)
+        }
+    }
+    return nil
+}
+
+// Client returns an HTTP client whose connections are checked after DNS
+// resolution, so redirects and DNS rebinding can't reach blocked addresses.
+// Proxies from the environment are ignored since they would bypass the
+// check.
+func (g *Guard) Client(timeout time.Duration) *http.Client {
+    dialer := &net.Dialer{
+        Timeout:   10 * time.Second,
+        KeepAlive: 30 * time.Second,
+        Control:   g.control,
+    }
+    unchecked := &net.Dialer{
+        Timeout:   10 * time.Second,
+        KeepAlive: 30 * time.Second,
+    }
+
+    transport := &http.Transport{
+        Proxy: nil,
+        DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+            host, _, err := net.SplitHostPort(addr)
+            if err == nil && g.allowHosts[strings.ToLower(host)] {
+                return unchecked.DialContext(ctx, network, addr)
+            }
+            return dialer.DialContext(ctx, network, addr)
+        },
+        ForceAttemptHTTP2:     true,
+        MaxIdleConns:          100,
+        IdleConnTimeout:       90 * time.Second,
+        TLSHandshakeTimeout:   10 * time.Second,
+        ExpectContinueTimeout: time.Second,
+    }
+
+    return &http.Client{
+        Timeout:   timeout,
+        Transport: transport,
+        CheckRedirect: func(req *http.Request, via []*http.Request) error {
+            if len(via) >= 10 {
+                return errors.New("stopped after 10 redirects")
+            }
+            if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+                return fmt.Errorf("%w: redirect to scheme %q", ErrBlocked, req.URL.Scheme)
+            }
+            return nil
+        },
+    }
+}
+
+// control runs after DNS resolution, just before each connection is made.
+func (g *Guard) control(network, address string, _ syscall.RawConn) error {
+    host, _, err := net.SplitHostPort(address)
+    if err != nil {
+        return err
+    }
+    ip := net.ParseIP(host)
+    if ip == nil {
+        return fmt.Errorf("%w: unresolved address %s", ErrBlocked, host)
+    }
+    if !g.allowedIP(ip) {
+        return fmt.Errorf("%w: %s", ErrBlocked, ip)
+    }
+    return nil
+}
+
+func (g *Guard) allowedIP(ip net.IP) bool {
+    for _, n := range g.allowNets {
+        if n.Contains(ip) {
+            return true
+        }
+    }
+    return !isBlockedIP(ip)
+}
+
+func isBlockedIP(ip net.IP) bool {
+    if ip4 := ip.To4(); ip4 != nil {
+        ip = ip4
+    }
+    if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+        ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+        ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+        return true
+    }
+    for _, n := range blockedNets {
+        if n.Contains(ip) {
+            return true
+        }
+    }
+    return false
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+    out := make([]*net.IPNet, 0, len(cidrs))
+    for _, c := range cidrs {
+        _, n, err := net.ParseCIDR(c)
+        if err != nil {
+            panic(err)
+        }
+        out = append(out, n)
+    }
+    return out
+}
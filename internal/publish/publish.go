@@ -0,0 +1,286 @@
+package publish
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "time"
+
+    "github.com/mdbailin/go-rss-server/internal/sanitize"
+)
+
+// Generator names this server in generated documents.
+const Generator = "go-rss-server"
+
+// Content types of the generated documents.
+const (
+    ContentTypeAtom = "application/atom+xml; charset=utf-8"
+    ContentTypeRSS  = "application/rss+xml; charset=utf-8"
+    ContentTypeJSON = "application/feed+json; charset=utf-8"
+)
+
+// Feed is a format-neutral description of a generated feed.
+type Feed struct {
+    // ID is a stable, globally unique identifier such as a urn:uuid.
+    ID          string
+    Title       string
+    Description string
+    // SelfURL is where the document itself is served.
+    SelfURL string
+    Author  string
+    Updated time.Time
+    Items   []Item
+}
+
+// Item is one entry of a generated feed. Summary and ContentHTML are HTML
+// that has already been sanitized.
+type Item struct {
+    ID          string
+    Title       string
+    URL         string
+    Summary     string
+    ContentHTML string
+    Author      string
+    Categories  []string
+    Published   time.Time
+    Updated     time.Time
+}
+
+type atomFeed struct {
+    XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+    ID        string      `xml:"id"`
+    Title     string      `xml:"title"`
+    Subtitle  string      `xml:"subtitle,omitempty"`
+    Updated   string      `xml:"updated"`
+    Links     []atomLink  `xml:"link"`
+    Author    *atomPerson `xml:"author"`
+    Generator string      `xml:"generator"`
+    Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+    Rel  string `xml:"rel,attr,omitempty"`
+    Href string `xml:"href,attr"`
+    Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+    Name string `xml:"name"`
+}
+
+type atomText struct {
+    Type string `xml:"type,attr"`
+    Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+    Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+    ID         string         `xml:"id"`
+    Title      string         `xml:"title"`
+    Links      []atomLink     `xml:"link"`
+    Published  string         `xml:"published"`
+    Updated    string         `xml:"updated"`
+    Author     *atomPerson    `xml:"author"`
+    Categories []atomCategory `xml:"category"`
+    Summary    *atomText      `xml:"summary"`
+    Content    *atomText      `xml:"content"`
+}
+
+// Atom renders the feed as an Atom 1.0 document.
+func Atom(f Feed) ([]byte, error) {
+    doc := atomFeed{
+        ID:        f.ID,
+        Title:     f.Title,
+        Subtitle:  f.Description,
+        Updated:   f.Updated.UTC().Format(time.RFC3339),
+        Links:     []atomLink{{Rel: "self", Href: f.SelfURL, Type: "application/atom+xml"}},
+        Author:    &atomPerson{Name: f.Author},
+        Generator: Generator,
+    }
+
+    for _, it := range f.Items {
+        e := atomEntry{
+            ID:        it.ID,
+            Title:     it.Title,
+            Published: it.Published.UTC().Format(time.RFC3339),
+            Updated:   it.Updated.UTC().Format(time.RFC3339),
+        }
+        if it.URL != "" {
+            e.Links = []atomLink{{Rel: "alternate", Href: it.URL}}
+        }
+        if it.Author != "" {
+            e.Author = &atomPerson{Name: it.Author}
+        }
+        for _, c := range it.Categories {
+            e.Categories = append(e.Categories, atomCategory{Term: c})
+        }
+        if it.Summary != "" {
+            e.Summary = &atomText{Type: "html", Body: it.Summary}
+        }
+        if it.ContentHTML != "" {
+            e.Content = &atomText{Type: "html", Body: it.ContentHTML}
+        }
+        doc.Entries = append(doc.Entries, e)
+    }
+
+    return marshalXML(doc)
+}
+
+type rssDoc struct {
+    XMLName   xml.Name   `xml:"rss"`
+    Version   string     `xml:"version,attr"`
+    AtomNS    string     `xml:"xmlns:atom,attr"`
+    ContentNS string     `xml:"xmlns:content,attr"`
+    DCNS      string     `xml:"xmlns:dc,attr"`
+    Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+    Title         string    `xml:"title"`
+    Link          string    `xml:"link"`
+    Description   string    `xml:"description"`
+    SelfLink      atomLink  `xml:"atom:link"`
+    LastBuildDate string    `xml:"lastBuildDate"`
+    Generator     string    `xml:"generator"`
+    Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+    IsPermaLink string `xml:"isPermaLink,attr"`
+    Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+    Title       string   `xml:"title"`
+    Link        string   `xml:"link,omitempty"`
+    GUID        rssGUID  `xml:"guid"`
+    PubDate     string   `xml:"pubDate"`
+    Creator     string   `xml:"dc:creator,omitempty"`
+    Categories  []string `xml:"category"`
+    Description string   `xml:"description,omitempty"`
+    Content     string   `xml:"content:encoded,omitempty"`
+}
+
+// RSS renders the feed as an RSS 2.0 document, with content:encoded for
+// full content and dc:creator for authors.
+func RSS(f Feed) ([]byte, error) {
+    description := f.Description
+    if description == "" {
+        description = f.Title
+    }
+
+    doc := rssDoc{
+        Version:   "2.0",
+        AtomNS:    "http://www.w3.org/2005/Atom",
+        ContentNS: "http://purl.org/rss/1.0/modules/content/",
+        DCNS:      "http://purl.org/dc/elements/1.1/",
+        Channel: rssChannel{
+            Title:         f.Title,
+            Link:          f.SelfURL,
+            Description:   description,
+            SelfLink:      atomLink{Rel: "self", Href: f.SelfURL, Type: "application/rss+xml"},
+            LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+            Generator:     Generator,
+        },
+    }
+
+    for _, it := range f.Items {
+        doc.Channel.Items = append(doc.Channel.Items, rssItem{
+            Title:       it.Title,
+            Link:        it.URL,
+            GUID:        rssGUID{IsPermaLink: "false", Value: it.ID},
+            PubDate:     it.Published.UTC().Format(time.RFC1123Z),
+            Creator:     it.Author,
+            Categories:  it.Categories,
+            Description: it.Summary,
+            Content:     it.ContentHTML,
+        })
+    }
+
+    return marshalXML(doc)
+}
+
+type jsonFeed struct {
+    Version     string       `json:"version"`
+    Title       string       `json:"title"`
+    Description string       `json:"description,omitempty"`
+    FeedURL     string       `json:"feed_url"`
+    Authors     []jsonAuthor `json:"authors,omitempty"`
+    Items       []jsonItem   `json:"items"`
+}
+
+type jsonAuthor struct {
+    Name string `json:"name"`
+}
+
+// jsonItem follows JSON Feed, where summary is plain text.
+type jsonItem struct {
+    ID            string       `json:"id"`
+    URL           string       `json:"url,omitempty"`
+    Title         string       `json:"title,omitempty"`
+    ContentHTML   string       `json:"content_html"`
+    Summary       string       `json:"summary,omitempty"`
+    DatePublished string       `json:"date_published"`
+    DateModified  string       `json:"date_modified"`
+    Authors       []jsonAuthor `json:"authors,omitempty"`
+    Tags          []string     `json:"tags,omitempty"`
+}
+
+// JSON renders the feed as a JSON Feed 1.1 document.
+func JSON(f Feed) ([]byte, error) {
+    doc := jsonFeed{
+        Version:     "https://jsonfeed.org/version/1.1",
+        Title:       f.Title,
+        Description: f.Description,
+        FeedURL:     f.SelfURL,
+        Items:       []jsonItem{},
+    }
+    if f.Author != "" {
+        doc.Authors = []jsonAuthor{{Name: f.Author}}
+    }
+
+    for _, it := range f.Items {
+        item := jsonItem{
+            ID:            it.ID,
+            URL:           it.URL,
+            Title:         it.Title,
+            ContentHTML:   it.ContentHTML,
+            Summary:       sanitize.Text(it.Summary),
+            DatePublished: it.Published.UTC().Format(time.RFC3339),
+            DateModified:  it.Updated.UTC().Format(time.RFC3339),
+            Tags:          it.Categories,
+        }
+        // JSON Feed requires content_html or content_text.
+        if item.ContentHTML == "" {
+            item.ContentHTML = it.Summary
+        }
+        if it.Author != "" {
+            item.Authors = []jsonAuthor{{Name: it.Author}}
+        }
+        doc.Items = append(doc.Items, item)
+    }
+
+    var b bytes.Buffer
+    enc := json.NewEncoder(&b)
+    enc.SetEscapeHTML(false)
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(doc); err != nil {
+        return nil, err
+    }
+    return b.Bytes(), nil
+}
+
+func marshalXML(v any) ([]byte, error) {
+    var b bytes.Buffer
+    b.WriteString(xml.Header)
+    enc := xml.NewEncoder(&b)
+    enc.Indent("", "  ")
+    if err := enc.Encode(v); err != nil {
+        return nil, err
+    }
+    b.WriteString("\n")
+    return b.Bytes(), nil
+}
@@ -0,0 +1,187 @@
+// Package ratelimit limits how often clients may call the API, using a
+// token bucket per client.
+package ratelimit
+
+import (
+    "fmt"
+    "math"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/mdbailin/go-rss-server/internal/httputil"
+)
+
+// Limit allows Requests requests per Per, in bursts of up to Requests.
+type Limit struct {
+    Requests int
+    Per      time.Duration
+}
+
+// ParseLimit parses a limit written as "<requests>/<unit>", where unit is
+// s, m, h or d, such as "120/m". "off" gives the zero Limit, which means
+// no limit.
+func ParseLimit(s string) (Limit, error) {
+    s = strings.TrimSpace(s)
+    if s == "off" {
+        return Limit{}, nil
+    }
+
+    n, unit, ok := strings.Cut(s, "/")
+    if !ok {
+        return Limit{}, fmt.Errorf("invalid limit %q: want requests/unit, like 120/m", s)
+    }
+    requests, err := strconv.Atoi(n)
+    if err != nil || requests < 1 {
+        return Limit{}, fmt.Errorf("invalid limit %q: requests must be a positive number", s)
+    }
+
+    var per time.Duration
+    switch unit {
+    case "s":
+        per = time.Second
+    case "m":
+        per = time.Minute
+    case "h":
+        per = time.Hour
+    case "d":
+        per = 24 * time.Hour
+    default:
+        return Limit{}, fmt.Errorf("invalid limit %q: unit must be s, m, h or d", s)
+    }
+
+    return Limit{Requests: requests, Per: per}, nil
+}
+
+// Off reports whether l is the zero Limit, which allows everything.
+func (l Limit) Off() bool {
+    return l.Requests == 0
+}
+
+func (l Limit) String() string {
+    return fmt.Sprintf("%d;w=%d", l.Requests, int(l.Per.Seconds()))
+}
+
+type bucket struct {
+    tokens float64
+    last   time.Time
+}
+
+// Limiter keeps a token bucket per key. Buckets that have filled up again
+// are dropped, so memory stays proportional to recently active clients.
+type Limiter struct {
+    limit Limit
+
+    mu        sync.Mutex
+    buckets   map[string]*bucket
+    lastSweep time.Time
+}
+
+// New returns a Limiter enforcing limit.
+func New(limit Limit) *Limiter {
+    return &Limiter{
+        limit:   limit,
+        buckets: map[string]*bucket{},
+    }
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+    Allowed   bool
+    Limit     Limit
+    Remaining int
+
+    // Reset is how long until the bucket is full again.
+    Reset time.Duration
+
+    // RetryAfter is how long until a token is available, when Allowed is
+    // false.
+    RetryAfter time.Duration
+}
+
+// Take takes a token from key's bucket, if there is one.
+func (l *Limiter) Take(key string, now time.Time) Result {
+    capacity := float64(l.limit.Requests)
+    perToken := l.limit.Per / time.Duration(l.limit.Requests)
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    if now.Sub(l.lastSweep) > l.limit.Per {
+        l.sweep(now)
+        l.lastSweep = now
+    }
+
+    b, ok := l.buckets[key]
+    if !ok {
+        b = &bucket{tokens: capacity, last: now}
+        l.buckets[key] = b
+    }
+
+    elapsed := now.Sub(b.last)
+    b.tokens = math.Min(capacity, b.tokens+elapsed.Seconds()/perToken.Seconds())
+    b.last = now
+
+    res := Result{Limit: l.limit}
+    if b.tokens >= 1 {
+        b.tokens--
+        res.Allowed = true
+    } else {
+        res.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+    }
+    res.Remaining = int(b.tokens)
+    res.Reset = time.Duration((capacity - b.tokens) * float64(perToken))
+    return res
+}
+
+// sweep drops buckets that would be full by now.
+func (l *Limiter) sweep(now time.Time) {
+    for key, b := range l.buckets {
+        if now.Sub(b.last) >= l.limit.Per {
+            delete(l.buckets, key)
+        }
+    }
+}
+
+// SetHeaders writes the RateLimit-* headers for res, and Retry-After if the
+// request was refused.
+func SetHeaders(w http.ResponseWriter, res Result) {
+    h := w.Header()
+    h.Set("RateLimit-Limit", strconv.Itoa(res.Limit.Requests))
+    h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+    h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+    h.Set("RateLimit-Policy", res.Limit.String())
+    if !res.Allowed {
+        h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+    }
+}
+
+func ceilSeconds(d time.Duration) int {
+    return int(math.Ceil(d.Seconds()))
+}
+
+// Middleware limits requests using the Limiter pick returns for them,
+// keyed by key. Requests pick returns nil for are not limited. Refused
+// requests get a 429.
+func Middleware(pick func(*http.Request) *Limiter, key func(*http.Request) string) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            l := pick(r)
+            if l == nil || l.limit.Off() {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            res := l.Take(key(r), time.Now())
+            SetHeaders(w, res)
+            if !res.Allowed {
+                httputil.RespondWithError(w, http.StatusTooManyRequests, "rate limit exceeded")
+                return
+            }
+
+            next.ServeHTTP(w, r)
+        })
+    }
+}
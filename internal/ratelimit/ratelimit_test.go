@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestParseLimit(t *testing.T) {
+    tests := []struct {
+        in      string
+        want    Limit
+        wantErr bool
+    }{
+        {in: "120/m", want: Limit{Requests: 120, Per: time.Minute}},
+        {in: "10/s", want: Limit{Requests: 10, Per: time.Second}},
+        {in: " 5/h ", want: Limit{Requests: 5, Per: time.Hour}},
+        {in: "1000/d", want: Limit{Requests: 1000, Per: 24 * time.Hour}},
+        {in: "off", want: Limit{}},
+        {in: "", wantErr: true},
+        {in: "120", wantErr: true},
+        {in: "0/m", wantErr: true},
+        {in: "-1/m", wantErr: true},
+        {in: "x/m", wantErr: true},
+        {in: "10/w", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        got, err := ParseLimit(tt.in)
+        if tt.wantErr {
+            if err == nil {
+                t.Errorf("ParseLimit(%q) = %+v, want an error", tt.in, got)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("ParseLimit(%q): %v", tt.in, err)
+            continue
+        }
+        if got != tt.want {
+            t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestTake(t *testing.T) {
+    start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+    tests := []struct {
+        name  string
+        limit Limit
+        // at is when each take happens, relative to start.
+        at   []time.Duration
+        want []bool
+    }{
+        {
+            name:  "burst up to the limit",
+            limit: Limit{Requests: 3, Per: time.Minute},
+            at:    []time.Duration{0, 0, 0, 0},
+            want:  []bool{true, true, true, false},
+        },
+        {
+            name:  "a token comes back after per/requests",
+            limit: Limit{Requests: 2, Per: time.Minute},
+            at:    []time.Duration{0, 0, 0, 29 * time.Second, 30 * time.Second},
+            want:  []bool{true, true, false, false, true},
+        },
+        {
+            name:  "refills to capacity, no further",
+            limit: Limit{Requests: 2, Per: time.Minute},
+            at:    []time.Duration{0, 0, time.Hour, time.Hour, time.Hour},
+            want:  []bool{true, true, true, true, false},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            l := New(tt.limit)
+            for i, d := range tt.at {
+                res := l.Take("k", start.Add(d))
+                if res.Allowed != tt.want[i] {
+                    t.Fatalf("take %d at %v: allowed = %v, want %v", i, d, res.Allowed, tt.want[i])
+                }
+                if !res.Allowed && res.RetryAfter <= 0 {
+                    t.Errorf("take %d: refused with RetryAfter %v", i, res.RetryAfter)
+                }
+            }
+        })
+    }
+}
+
+func TestTakeKeysAreSeparate(t *testing.T) {
+    now := time.Now()
+    l := New(Limit{Requests: 1, Per: time.Minute})
+
+    if !l.Take("a", now).Allowed {
+        t.Fatal("first take for a refused")
+    }
+    if l.Take("a", now).Allowed {
+        t.Fatal("second take for a allowed")
+    }
+    if !l.Take("b", now).Allowed {
+        t.Fatal("b shares a's bucket")
+    }
+}
+
+func TestMiddleware(t *testing.T) {
+    l := New(Limit{Requests: 1, Per: time.Minute})
+    h := Middleware(
+        func(*http.Request) *Limiter { return l },
+        func(*http.Request) string { return "k" },
+    )(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+    rec := httptest.NewRecorder()
+    h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+    if rec.Code != http.StatusOK {
+        t.Fatalf("first request: status %d", rec.Code)
+    }
+    if got := rec.Header().Get("RateLimit-Limit"); got != "1" {
+        t.Errorf("RateLimit-Limit = %q, want 1", got)
+    }
+
+    rec = httptest.NewRecorder()
+    h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+    if rec.Code != http.StatusTooManyRequests {
+        t.Fatalf("second request: status %d, want 429", rec.Code)
+    }
+    if rec.Header().Get("Retry-After") == "" {
+        t.Error("429 without Retry-After")
+    }
+}
@@ -0,0 +1,290 @@
+package rss
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+
+    "golang.org/x/net/html"
+)
+
+// Client is the HTTP client used for all outbound feed requests.
+var Client = &http.Client{Timeout: 30 * time.Second}
+
+// maxBodySize caps how much of a remote document we are willing to read.
+const maxBodySize = 10 << 20
+
+// ErrNoFeedFound is returned by Discover when neither the page itself nor
+// anything it links to looks like a feed.
+var ErrNoFeedFound = errors.New("no feed found")
+
+// commonFeedPaths are probed under the page's directory and the site root
+// when a page doesn't advertise any feeds through <link rel="alternate">.
+var commonFeedPaths = []string{"/feed", "/rss.xml", "/index.xml", "/atom.xml", "/feed.xml"}
+
+// feedMIMETypes are the <link type="..."> values we treat as feeds.
+var feedMIMETypes = map[string]bool{
+    "application/rss+xml":   true,
+    "application/atom+xml":  true,
+    "application/feed+json": true,
+}
+
+// Candidate is a feed URL found while inspecting a web page.
+type Candidate struct {
+    URL   string `json:"url"`
+    Title string `json:"title,omitempty"`
+    Type  string `json:"type,omitempty"`
+}
+
+// Discovery is the result of resolving a user-submitted URL to a feed.
+type Discovery struct {
+    // FeedURL is set when the URL resolves to exactly one feed.
+    FeedURL    string
+    Candidates []Candidate
+}
+
+// Discover fetches rawURL and works out which feed it refers to. If the URL
+// is already a feed it is returned as is; if it is an HTML page, the page's
+// alternate links are collected, falling back to probing common feed paths.
+func Discover(ctx context.Context, rawURL string) (*Discovery, error) {
+    body, contentType, finalURL, err := fetch(ctx, rawURL)
+    if err != nil {
+        return nil, err
+    }
+
+    if looksLikeFeed(contentType, body) {
+        return &Discovery{FeedURL: rawURL}, nil
+    }
+
+    if !looksLikeHTML(contentType, body) {
+        return nil, ErrNoFeedFound
+    }
+
+    candidates, err := findAlternateLinks(finalURL, body)
+    if err != nil {
+        return nil, fmt.Errorf("parse html: %w", err)
+    }
+
+    if len(candidates) == 0 {
+        candidates = probeCommonPaths(ctx, finalURL)
+    }
+
+    switch len(candidates) {
+    case 0:
+        return nil, ErrNoFeedFound
+    case 1:
+        return &Discovery{FeedURL: candidates[0].URL, Candidates: candidates}, nil
+    default:
+        return &Discovery{Candidates: candidates}, nil
+    }
+}
+
+// fetch GETs rawURL and returns its body, content type and the URL the
+// request ended up at after redirects.
+func fetch(ctx context.Context, rawURL string) ([]byte, string, *url.URL, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+    if err != nil {
+        return nil, "", nil, fmt.Errorf("create request: %w", err)
+    }
+
+    resp, err := Client.Do(req)
+    if err != nil {
+        return nil, "", nil, fmt.Errorf("do request: %w", err)
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, "", nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+    }
+
+    body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+    if err != nil {
+        return nil, "", nil, fmt.Errorf("read body: %w", err)
+    }
+
+    return body, resp.Header.Get("Content-Type"), resp.Request.URL, nil
+}
+
+func mediaType(contentType string) string {
+    mt, _, err := mime.ParseMediaType(contentType)
+    if err != nil {
+        return ""
+    }
+    return mt
+}
+
+func looksLikeFeed(contentType string, body []byte) bool {
+    switch mediaType(contentType) {
+    case "application/rss+xml", "application/atom+xml", "application/feed+json":
+        return true
+    case "text/html", "application/xhtml+xml":
+        return false
+    }
+
+    head := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+    if len(head) > 1024 {
+        head = head[:1024]
+    }
+
+    if bytes.HasPrefix(head, []byte("{")) {
+        return bytes.Contains(head, []byte("jsonfeed.org/version"))
+    }
+
+    return bytes.Contains(head, []byte("<rss")) ||
+        bytes.Contains(head, []byte("<feed")) ||
+        bytes.Contains(head, []byte("<rdf:RDF"))
+}
+
+func looksLikeHTML(contentType string, body []byte) bool {
+    switch mediaType(contentType) {
+    case "text/html", "application/xhtml+xml":
+        return true
+    }
+    return strings.HasPrefix(http.DetectContentType(body), "text/html")
+}
+
+// findAlternateLinks returns the feeds advertised by an HTML page through
+// <link rel="alternate" type="...">, resolved against the page URL (or its
+// <base href>).
+func findAlternateLinks(pageURL *url.URL, body []byte) ([]Candidate, error) {
+    doc, err := html.Parse(bytes.NewReader(body))
+    if err != nil {
+        return nil, err
+    }
+
+    base := pageURL
+    var candidates []Candidate
+    seen := map[string]bool{}
+
+    var walk func(n *html.Node)
+    walk = func(n *html.Node) {
+        if n.Type == html.ElementNode {
+            switch n.Data {
+            case "base":
+                if href := attr(n, "href"); href != "" {
+                    if u, err := pageURL.Parse(href); err == nil {
+                        base = u
+                    }
+                }
+            case "link":
+                if !hasToken(attr(n, "rel"), "alternate") {
+                    break
+                }
+                typ := strings.ToLower(strings.TrimSpace(attr(n, "type")))
+                if !feedMIMETypes[typ] {
+                    break
+                }
+                u, err := base.Parse(strings.TrimSpace(attr(n, "href")))
+                if err != nil || seen[u.String()] {
+                    break
+                }
+                seen[u.String()] = true
+                candidates = append(candidates, Candidate{
+                    URL:   u.String(),
+                    Title: strings.TrimSpace(attr(n, "title")),
+                    Type:  typ,
+                })
+            case "body":
+                // Alternate links only count in <head>.
+                return
+            }
+        }
+        for c := n.FirstChild; c != nil; c = c.NextSibling {
+            walk(c)
+        }
+    }
+    walk(doc)
+
+    return candidates, nil
+}
+
+// probeTimeout bounds probing for common feed paths as a whole, so a host
+// that never answers can't hold up adding a feed.
+const probeTimeout = 5 * time.Second
+
+// probeCommonPaths tries the usual feed locations under the page's own
+// directory and the site root, all at once, and returns those that respond
+// with something that looks like a feed. Paths that redirect to the same
+// document count once, under the URL they end up at.
+func probeCommonPaths(ctx context.Context, pageURL *url.URL) []Candidate {
+    ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+    defer cancel()
+
+    urls := probeURLs(pageURL)
+    found := make([]*Candidate, len(urls))
+
+    var wg sync.WaitGroup
+    for i, u := range urls {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            body, contentType, finalURL, err := fetch(ctx, u)
+            if err != nil || !looksLikeFeed(contentType, body) {
+                return
+            }
+            found[i] = &Candidate{URL: finalURL.String(), Type: mediaType(contentType)}
+        }()
+    }
+    wg.Wait()
+
+    var candidates []Candidate
+    seen := map[string]bool{}
+    for _, c := range found {
+        if c == nil || seen[c.URL] {
+            continue
+        }
+        seen[c.URL] = true
+        candidates = append(candidates, *c)
+    }
+    return candidates
+}
+
+// probeURLs lists the URLs probeCommonPaths tries, in order of preference:
+// commonFeedPaths under the page's directory, such as /blog/feed for
+// /blog/ or /blog/index.html, then under the site root.
+func probeURLs(pageURL *url.URL) []string {
+    dir := pageURL.Path
+    if i := strings.LastIndex(dir, "/"); i >= 0 && strings.Contains(dir[i:], ".") {
+        dir = dir[:i]
+    }
+    dir = strings.TrimSuffix(dir, "/")
+
+    var urls []string
+    seen := map[string]bool{}
+    for _, prefix := range []string{dir, ""} {
+        for _, p := range commonFeedPaths {
+            u := (&url.URL{Scheme: pageURL.Scheme, Host: pageURL.Host, Path: prefix + p}).String()
+            if !seen[u] {
+                seen[u] = true
+                urls = append(urls, u)
+            }
+        }
+    }
+    return urls
+}
+
+func attr(n *html.Node, key string) string {
+    for _, a := range n.Attr {
+        if strings.EqualFold(a.Key, key) {
+            return a.Val
+        }
+    }
+    return ""
+}
+
+func hasToken(list, token string) bool {
+    for _, f := range strings.Fields(list) {
+        if strings.EqualFold(f, token) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,242 @@
+package rss
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+const minimalRSS = `<?xml version="1.0"?><rss version="2.0"><channel><title>t</title></channel></rss>`
+
+// page is one document served by the discovery test server. A page with
+// redirect set answers with a 302 to that path instead.
+type page struct {
+    contentType string
+    body        string
+    redirect    string
+}
+
+func htmlPage(head string) page {
+    return page{contentType: "text/html; charset=utf-8", body: "<!DOCTYPE html><html><head>" + head + "</head><body><p>hi</p></body></html>"}
+}
+
+func TestDiscover(t *testing.T) {
+    tests := []struct {
+        name  string
+        pages map[string]page
+        // start is the path Discover is called with. In the wants, "{}"
+        // stands for the test server's URL.
+        start          string
+        wantFeedURL    string
+        wantCandidates []string
+        wantErr        error
+    }{
+        {
+            name:        "url is already a feed",
+            pages:       map[string]page{"/feed.xml": {contentType: "application/rss+xml", body: minimalRSS}},
+            start:       "/feed.xml",
+            wantFeedURL: "{}/feed.xml",
+        },
+        {
+            name:        "feed served as text/xml",
+            pages:       map[string]page{"/feed": {contentType: "text/xml", body: minimalRSS}},
+            start:       "/feed",
+            wantFeedURL: "{}/feed",
+        },
+        {
+            name:        "json feed",
+            pages:       map[string]page{"/feed.json": {contentType: "application/json", body: `{"version": "https://jsonfeed.org/version/1.1", "items": []}`}},
+            start:       "/feed.json",
+            wantFeedURL: "{}/feed.json",
+        },
+        {
+            name: "single alternate link",
+            pages: map[string]page{
+                "/": htmlPage(`<link rel="alternate" type="application/rss+xml" title="Posts" href="/posts.rss">`),
+            },
+            start:          "/",
+            wantFeedURL:    "{}/posts.rss",
+            wantCandidates: []string{"{}/posts.rss"},
+        },
+        {
+            name: "alternate links resolved against base href",
+            pages: map[string]page{
+                "/": htmlPage(`<base href="/blog/"><link rel="alternate" type="application/atom+xml" href="atom.xml">`),
+            },
+            start:          "/",
+            wantFeedURL:    "{}/blog/atom.xml",
+            wantCandidates: []string{"{}/blog/atom.xml"},
+        },
+        {
+            name: "several alternate links are ambiguous",
+            pages: map[string]page{
+                "/": htmlPage(`
+                    <link rel="alternate" type="application/rss+xml" title="Posts" href="/posts.rss">
+                    <link rel="alternate" type="application/atom+xml" title="Comments" href="/comments.atom">
+                    <link rel="alternate" type="application/rss+xml" href="/posts.rss">
+                    <link rel="alternate" type="text/html" hreflang="fr" href="/fr/">
+                    <link rel="stylesheet" type="text/css" href="/style.css">`),
+            },
+            start:          "/",
+            wantCandidates: []string{"{}/posts.rss", "{}/comments.atom"},
+        },
+        {
+            name: "links in body are ignored",
+            pages: map[string]page{
+                "/": {contentType: "text/html", body: `<html><head></head><body><link rel="alternate" type="application/rss+xml" href="/posts.rss"></body></html>`},
+            },
+            start:   "/",
+            wantErr: ErrNoFeedFound,
+        },
+        {
+            name: "probe finds a root feed",
+            pages: map[string]page{
+                "/":        htmlPage(""),
+                "/rss.xml": {contentType: "application/rss+xml", body: minimalRSS},
+            },
+            start:          "/",
+            wantFeedURL:    "{}/rss.xml",
+            wantCandidates: []string{"{}/rss.xml"},
+        },
+        {
+            name: "probe prefers the page directory",
+            pages: map[string]page{
+                "/blog/index.html": htmlPage(""),
+                "/blog/feed":       {contentType: "application/atom+xml", body: `<feed xmlns="http://www.w3.org/2005/Atom"></feed>`},
+                "/feed":            {contentType: "application/rss+xml", body: minimalRSS},
+            },
+            start:          "/blog/index.html",
+            wantCandidates: []string{"{}/blog/feed", "{}/feed"},
+        },
+        {
+            name: "probed paths that redirect to the same feed count once",
+            pages: map[string]page{
+                "/":         htmlPage(""),
+                "/feed":     {redirect: "/feed.xml"},
+                "/rss.xml":  {redirect: "/feed.xml"},
+                "/feed.xml": {contentType: "application/rss+xml", body: minimalRSS},
+            },
+            start:          "/",
+            wantFeedURL:    "{}/feed.xml",
+            wantCandidates: []string{"{}/feed.xml"},
+        },
+        {
+            name: "probe ignores html answers",
+            pages: map[string]page{
+                "/":     htmlPage(""),
+                "/feed": htmlPage(""),
+            },
+            start:   "/",
+            wantErr: ErrNoFeedFound,
+        },
+        {
+            name:    "neither html nor a feed",
+            pages:   map[string]page{"/logo.png": {contentType: "image/png", body: "\x89PNG\r\n\x1a\n"}},
+            start:   "/logo.png",
+            wantErr: ErrNoFeedFound,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                p, ok := tt.pages[r.URL.Path]
+                if !ok {
+                    http.NotFound(w, r)
+                    return
+                }
+                if p.redirect != "" {
+                    http.Redirect(w, r, p.redirect, http.StatusFound)
+                    return
+                }
+                w.Header().Set("Content-Type", p.contentType)
+                w.Write([]byte(p.body))
+            }))
+            defer srv.Close()
+
+            expand := func(s string) string { return strings.ReplaceAll(s, "{}", srv.URL) }
+
+            d, err := Discover(context.Background(), srv.URL+tt.start)
+            if tt.wantErr != nil {
+                if !errors.Is(err, tt.wantErr) {
+                    t.Fatalf("Discover = %+v, %v; want %v", d, err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Discover: %v", err)
+            }
+
+            if want := expand(tt.wantFeedURL); d.FeedURL != want {
+                t.Errorf("FeedURL = %q, want %q", d.FeedURL, want)
+            }
+            var got, want []string
+            for _, c := range d.Candidates {
+                got = append(got, c.URL)
+            }
+            for _, c := range tt.wantCandidates {
+                want = append(want, expand(c))
+            }
+            if !reflect.DeepEqual(got, want) {
+                t.Errorf("Candidates = %q, want %q", got, want)
+            }
+        })
+    }
+}
+
+func TestDiscoverStatusError(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        http.Error(w, "gone", http.StatusGone)
+    }))
+    defer srv.Close()
+
+    if _, err := Discover(context.Background(), srv.URL); err == nil || errors.Is(err, ErrNoFeedFound) {
+        t.Errorf("Discover = %v, want a fetch error", err)
+    }
+}
+
+func TestProbeURLs(t *testing.T) {
+    tests := []struct {
+        page string
+        want []string
+    }{
+        {
+            page: "https://example.com/",
+            want: []string{"/feed", "/rss.xml", "/index.xml", "/atom.xml", "/feed.xml"},
+        },
+        {
+            page: "https://example.com/blog/",
+            want: []string{
+                "/blog/feed", "/blog/rss.xml", "/blog/index.xml", "/blog/atom.xml", "/blog/feed.xml",
+                "/feed", "/rss.xml", "/index.xml", "/atom.xml", "/feed.xml",
+            },
+        },
+        {
+            page: "https://example.com/blog/index.html?page=2",
+            want: []string{
+                "/blog/feed", "/blog/rss.xml", "/blog/index.xml", "/blog/atom.xml", "/blog/feed.xml",
+                "/feed", "/rss.xml", "/index.xml", "/atom.xml", "/feed.xml",
+            },
+        },
+        {
+            page: "https://example.com/index.html",
+            want: []string{"/feed", "/rss.xml", "/index.xml", "/atom.xml", "/feed.xml"},
+        },
+    }
+
+    for _, tt := range tests {
+        u, _ := url.Parse(tt.page)
+        var want []string
+        for _, p := range tt.want {
+            want = append(want, "https://example.com"+p)
+        }
+        if got := probeURLs(u); !reflect.DeepEqual(got, want) {
+            t.Errorf("probeURLs(%q)\n got %q\nwant %q", tt.page, got, want)
+        }
+    }
+}
@@ -0,0 +1,480 @@
+package rss
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "html"
+    "io"
+    "net/url"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "golang.org/x/net/html/charset"
+)
+
+// Format identifies the syndication format a document was parsed as.
+type Format string
+
+const (
+    FormatRSS      Format = "rss"
+    FormatRDF      Format = "rdf"
+    FormatAtom     Format = "atom"
+    FormatJSONFeed Format = "json"
+)
+
+// ErrUnknownFormat is returned by Parse for documents that aren't RSS, RDF,
+// Atom or JSON Feed.
+var ErrUnknownFormat = errors.New("unknown feed format")
+
+// Parse detects the format of a feed document and decodes it into an
+// RSSFeed, so callers can treat every supported format the same way.
+// Recoverable problems, such as invalid UTF-8, are reported in
+// RSSFeed.Warnings rather than failing the parse.
+func Parse(body []byte) (*RSSFeed, error) {
+    var warnings []string
+
+    body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+    if !utf8.Valid(body) && !declaresEncoding(body) {
+        warnings = append(warnings, "document contains invalid UTF-8; invalid bytes were replaced")
+        body = bytes.ToValidUTF8(body, []byte("�"))
+    }
+
+    var (
+        feed *RSSFeed
+        err  error
+    )
+    if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+        feed, err = parseJSONFeed(trimmed)
+    } else {
+        feed, err = parseXML(body, &warnings)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    feed.Warnings = append(warnings, feed.Warnings...)
+    return feed, nil
+}
+
+// declaresEncoding reports whether an XML document names a non-UTF-8
+// encoding in its prolog, in which case the raw bytes aren't expected to be
+// valid UTF-8.
+func declaresEncoding(body []byte) bool {
+    head := body
+    if len(head) > 256 {
+        head = head[:256]
+    }
+    if !bytes.HasPrefix(head, []byte("<?xml")) {
+        return false
+    }
+    end := bytes.Index(head, []byte("?>"))
+    if end < 0 {
+        return false
+    }
+    prolog := strings.ToLower(string(head[:end]))
+    i := strings.Index(prolog, "encoding=")
+    if i < 0 {
+        return false
+    }
+    enc := strings.Trim(prolog[i+len("encoding="):], `"' `)
+    if j := strings.IndexAny(enc, `"' `); j >= 0 {
+        enc = enc[:j]
+    }
+    return enc != "utf-8" && enc != "utf8"
+}
+
+func newXMLDecoder(body []byte, warnings *[]string) *xml.Decoder {
+    decoder := xml.NewDecoder(bytes.NewReader(body))
+    decoder.Strict = false
+    decoder.Entity = xml.HTMLEntity
+    decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+        r, err := charset.NewReaderLabel(label, input)
+        if err != nil {
+            return nil, err
+        }
+        *warnings = append(*warnings, fmt.Sprintf("document is encoded as %q and was converted to UTF-8", label))
+        return r, nil
+    }
+    return decoder
+}
+
+func parseXML(body []byte, warnings *[]string) (*RSSFeed, error) {
+    root, err := rootElement(body)
+    if err != nil {
+        return nil, err
+    }
+
+    decoder := newXMLDecoder(body, warnings)
+
+    switch root {
+    case "rss":
+        var feed RSSFeed
+        if err := decoder.Decode(&feed); err != nil {
+            return nil, fmt.Errorf("decode rss: %w", err)
+        }
+        feed.Format = FormatRSS
+        feed.Channel.resolve()
+        return &feed, nil
+    case "RDF":
+        var rdf rdfFeed
+        if err := decoder.Decode(&rdf); err != nil {
+            return nil, fmt.Errorf("decode rdf: %w", err)
+        }
+        return rdf.toRSSFeed(), nil
+    case "feed":
+        var atom atomFeed
+        if err := decoder.Decode(&atom); err != nil {
+            return nil, fmt.Errorf("decode atom: %w", err)
+        }
+        return atom.toRSSFeed(), nil
+    default:
+        return nil, fmt.Errorf("%w: root element <%s>", ErrUnknownFormat, root)
+    }
+}
+
+// rootElement returns the local name of the document's first element.
+func rootElement(body []byte) (string, error) {
+    var ignored []string
+    decoder := newXMLDecoder(body, &ignored)
+    for {
+        tok, err := decoder.Token()
+        if err == io.EOF {
+            return "", ErrUnknownFormat
+        }
+        if err != nil {
+            return "", fmt.Errorf("decode xml: %w", err)
+        }
+        if start, ok := tok.(xml.StartElement); ok {
+            return start.Name.Local, nil
+        }
+    }
+}
+
+// ParseDate parses the date formats commonly found in feeds: RFC 822/1123
+// variants used by RSS and RFC 3339 used by Atom and JSON Feed.
+func ParseDate(s string) (time.Time, error) {
+    s = strings.TrimSpace(s)
+    for _, layout := range dateLayouts {
+        if t, err := time.Parse(layout, s); err == nil {
+            return t.UTC(), nil
+        }
+    }
+    return time.Time{}, fmt.Errorf("unrecognised date %q", s)
+}
+
+var dateLayouts = []string{
+    time.RFC1123Z,
+    time.RFC1123,
+    time.RFC3339,
+    time.RFC3339Nano,
+    "Mon, 2 Jan 2006 15:04:05 -0700",
+    "Mon, 2 Jan 2006 15:04:05 MST",
+    "2 Jan 2006 15:04:05 -0700",
+    "2 Jan 2006 15:04:05 MST",
+    "Mon, 2 Jan 2006 15:04 -0700",
+    "Mon, 2 Jan 2006 15:04 MST",
+    time.RFC822Z,
+    time.RFC822,
+    time.RFC850,
+    "2006-01-02T15:04:05",
+    "2006-01-02 15:04:05",
+    "2006-01-02",
+}
+
+// RDF (RSS 1.0) keeps items and the image beside the channel rather than
+// inside it.
+type rdfFeed struct {
+    Channel struct {
+        Title       string `xml:"title"`
+        Link        string `xml:"link"`
+        Description string `xml:"description"`
+        Language    string `xml:"language"`
+        Date        string `xml:"date"`
+    } `xml:"channel"`
+    Image struct {
+        URL string `xml:"url"`
+    } `xml:"image"`
+    Items []struct {
+        Title       string   `xml:"title"`
+        Link        string   `xml:"link"`
+        Description string   `xml:"description"`
+        Date        string   `xml:"date"`
+        Creator     string   `xml:"creator"`
+        Subjects    []string `xml:"subject"`
+    } `xml:"item"`
+}
+
+func (f rdfFeed) toRSSFeed() *RSSFeed {
+    out := &RSSFeed{Format: FormatRDF}
+    out.Channel.Title = f.Channel.Title
+    out.Channel.Link = strings.TrimSpace(f.Channel.Link)
+    out.Channel.Description = f.Channel.Description
+    out.Channel.Language = f.Channel.Language
+    out.Channel.LastBuildDate = f.Channel.Date
+    out.Channel.ImageURL = strings.TrimSpace(f.Image.URL)
+    for _, it := range f.Items {
+        out.Channel.Items = append(out.Channel.Items, RSSItem{
+            Title:       it.Title,
+            Link:        it.Link,
+            Description: it.Description,
+            PubDate:     it.Date,
+            Creator:     it.Creator,
+            Categories:  it.Subjects,
+        })
+    }
+    return out
+}
+
+type atomFeed struct {
+    Base      string      `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+    Lang      string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+    Title     atomText    `xml:"title"`
+    Subtitle  atomText    `xml:"subtitle"`
+    Links     []atomLink  `xml:"link"`
+    Icon      string      `xml:"icon"`
+    Logo      string      `xml:"logo"`
+    Generator string      `xml:"generator"`
+    Updated   string      `xml:"updated"`
+    Entries   []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+    Base      string     `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+    Title     atomText   `xml:"title"`
+    Links     []atomLink `xml:"link"`
+    Summary   atomText   `xml:"summary"`
+    Content   atomText   `xml:"content"`
+    Published string     `xml:"published"`
+    Updated   string     `xml:"updated"`
+
+    Authors []struct {
+        Name string `xml:"name"`
+    } `xml:"author"`
+    Categories []struct {
+        Term  string `xml:"term,attr"`
+        Label string `xml:"label,attr"`
+    } `xml:"category"`
+}
+
+type atomLink struct {
+    Href string `xml:"href,attr"`
+    Rel  string `xml:"rel,attr"`
+    Type string `xml:"type,attr"`
+}
+
+// atomText is an Atom text construct; xhtml content keeps its markup.
+type atomText struct {
+    Type  string `xml:"type,attr"`
+    Text  string `xml:",chardata"`
+    Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+    if t.Type == "xhtml" {
+        return strings.TrimSpace(t.Inner)
+    }
+    return strings.TrimSpace(t.Text)
+}
+
+// HTML returns the construct as markup, escaping plain-text constructs.
+func (t atomText) HTML() string {
+    if t.Type == "" || t.Type == "text" {
+        return html.EscapeString(t.String())
+    }
+    return t.String()
+}
+
+// joinBase resolves an entry's xml:base against the feed's.
+func joinBase(feedBase, entryBase string) string {
+    if entryBase == "" || feedBase == "" {
+        if entryBase != "" {
+            return entryBase
+        }
+        return feedBase
+    }
+    b, err := url.Parse(feedBase)
+    if err != nil {
+        return entryBase
+    }
+    e, err := b.Parse(entryBase)
+    if err != nil {
+        return entryBase
+    }
+    return e.String()
+}
+
+// relLink returns the first link with the given rel.
+func relLink(links []atomLink, rel string) string {
+    for _, l := range links {
+        if l.Rel == rel {
+            return strings.TrimSpace(l.Href)
+        }
+    }
+    return ""
+}
+
+// alternateLink picks the entry's HTML permalink: rel="alternate" or a link
+// with no rel at all.
+func alternateLink(links []atomLink) string {
+    for _, l := range links {
+        if l.Rel == "" || l.Rel == "alternate" {
+            return l.Href
+        }
+    }
+    return ""
+}
+
+func (f atomFeed) toRSSFeed() *RSSFeed {
+    out := &RSSFeed{Format: FormatAtom}
+    out.Channel.Title = f.Title.String()
+    out.Channel.Link = alternateLink(f.Links)
+    out.Channel.HubURL = relLink(f.Links, "hub")
+    out.Channel.SelfURL = relLink(f.Links, "self")
+    out.Channel.Description = f.Subtitle.String()
+    out.Channel.Language = f.Lang
+    out.Channel.Generator = strings.TrimSpace(f.Generator)
+    out.Channel.LastBuildDate = f.Updated
+    // Prefer the wider logo, falling back to the square icon.
+    out.Channel.ImageURL = strings.TrimSpace(f.Logo)
+    if out.Channel.ImageURL == "" {
+        out.Channel.ImageURL = strings.TrimSpace(f.Icon)
+    }
+    for _, e := range f.Entries {
+        description := e.Summary.HTML()
+        if description == "" {
+            description = e.Content.HTML()
+        }
+        date := e.Published
+        if date == "" {
+            date = e.Updated
+        }
+        var authors, categories []string
+        for _, a := range e.Authors {
+            if name := strings.TrimSpace(a.Name); name != "" {
+                authors = append(authors, name)
+            }
+        }
+        for _, c := range e.Categories {
+            if c.Label != "" {
+                categories = append(categories, c.Label)
+            } else if c.Term != "" {
+                categories = append(categories, c.Term)
+            }
+        }
+        out.Channel.Items = append(out.Channel.Items, RSSItem{
+            Title:       e.Title.String(),
+            Link:        alternateLink(e.Links),
+            Description: description,
+            PubDate:     date,
+            Content:     e.Content.HTML(),
+            Base:        joinBase(f.Base, e.Base),
+            Creator:     strings.Join(authors, ", "),
+            Categories:  categories,
+        })
+    }
+    return out
+}
+
+type jsonFeed struct {
+    Version     string         `json:"version"`
+    Title       string         `json:"title"`
+    HomePageURL string         `json:"home_page_url"`
+    FeedURL     string         `json:"feed_url"`
+    Description string         `json:"description"`
+    Icon        string         `json:"icon"`
+    Favicon     string         `json:"favicon"`
+    Language    string         `json:"language"`
+    Items       []jsonFeedItem `json:"items"`
+    Hubs        []jsonFeedHub  `json:"hubs"`
+}
+
+type jsonFeedHub struct {
+    Type string `json:"type"`
+    URL  string `json:"url"`
+}
+
+type jsonFeedItem struct {
+    URL           string   `json:"url"`
+    Title         string   `json:"title"`
+    Summary       string   `json:"summary"`
+    ContentHTML   string   `json:"content_html"`
+    ContentText   string   `json:"content_text"`
+    DatePublished string   `json:"date_published"`
+    DateModified  string   `json:"date_modified"`
+    Tags          []string `json:"tags"`
+
+    // JSON Feed 1.1 uses authors; 1.0 had a single author.
+    Authors []jsonFeedAuthor `json:"authors"`
+    Author  *jsonFeedAuthor  `json:"author"`
+}
+
+type jsonFeedAuthor struct {
+    Name string `json:"name"`
+}
+
+func parseJSONFeed(body []byte) (*RSSFeed, error) {
+    var f jsonFeed
+    if err := json.Unmarshal(body, &f); err != nil {
+        return nil, fmt.Errorf("decode json feed: %w", err)
+    }
+    if !strings.Contains(f.Version, "jsonfeed.org/version/") {
+        return nil, fmt.Errorf("%w: json document without a JSON Feed version", ErrUnknownFormat)
+    }
+
+    out := &RSSFeed{Format: FormatJSONFeed}
+    out.Channel.Title = f.Title
+    out.Channel.Link = f.HomePageURL
+    out.Channel.SelfURL = f.FeedURL
+    for _, h := range f.Hubs {
+        if strings.EqualFold(h.Type, "websub") && h.URL != "" {
+            out.Channel.HubURL = h.URL
+            break
+        }
+    }
+    out.Channel.Description = f.Description
+    out.Channel.Language = f.Language
+    out.Channel.ImageURL = f.Icon
+    if out.Channel.ImageURL == "" {
+        out.Channel.ImageURL = f.Favicon
+    }
+    for _, it := range f.Items {
+        // summary and content_text are plain text; only content_html is
+        // markup.
+        content := it.ContentHTML
+        if content == "" {
+            content = html.EscapeString(it.ContentText)
+        }
+        description := html.EscapeString(it.Summary)
+        if description == "" {
+            description = content
+        }
+        date := it.DatePublished
+        if date == "" {
+            date = it.DateModified
+        }
+        authors := it.Authors
+        if len(authors) == 0 && it.Author != nil {
+            authors = append(authors, *it.Author)
+        }
+        var names []string
+        for _, a := range authors {
+            if a.Name != "" {
+                names = append(names, a.Name)
+            }
+        }
+        out.Channel.Items = append(out.Channel.Items, RSSItem{
+            Title:       it.Title,
+            Link:        it.URL,
+            Description: description,
+            PubDate:     date,
+            Content:     content,
+            Creator:     strings.Join(names, ", "),
+            Categories:  it.Tags,
+        })
+    }
+    return out, nil
+}
@@ -0,0 +1,374 @@
+package rss
+
+import (
+    "errors"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+const rssDoc = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+    xmlns:atom="http://www.w3.org/2005/Atom"
+    xmlns:content="http://purl.org/rss/1.0/modules/content/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel>
+    <title>Example Blog</title>
+    <link>https://example.com/</link>
+    <atom:link rel="self" href="https://example.com/feed.xml"/>
+    <atom:link rel="hub" href="https://hub.example.com/"/>
+    <description>Posts &amp; notes</description>
+    <language>en</language>
+    <itunes:image href="https://example.com/itunes.png"/>
+    <image><url>https://example.com/logo.png</url></image>
+    <item>
+        <title>First post</title>
+        <link>https://example.com/first</link>
+        <description>&lt;p&gt;Summary&lt;/p&gt;</description>
+        <content:encoded><![CDATA[<p>Full <b>body</b></p>]]></content:encoded>
+        <pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate>
+        <dc:creator>Alice</dc:creator>
+        <category>go</category>
+        <category>web</category>
+    </item>
+</channel>
+</rss>`
+
+const rdfDoc = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+    xmlns="http://purl.org/rss/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel rdf:about="https://example.org/">
+    <title>RDF Site</title>
+    <link> https://example.org/ </link>
+    <description>Old school</description>
+    <dc:date>2006-01-02T15:04:05Z</dc:date>
+</channel>
+<image rdf:about="https://example.org/logo.gif"><url>https://example.org/logo.gif</url></image>
+<item rdf:about="https://example.org/1">
+    <title>Item one</title>
+    <link>https://example.org/1</link>
+    <description>One</description>
+    <dc:date>2006-01-02T15:04:05Z</dc:date>
+    <dc:creator>Bob</dc:creator>
+    <dc:subject>news</dc:subject>
+</item>
+</rdf:RDF>`
+
+const atomDoc = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xml:base="https://example.net/" xml:lang="fr">
+    <title>Atom Site</title>
+    <subtitle type="html">A &lt;i&gt;subtitle&lt;/i&gt;</subtitle>
+    <link href="https://example.net/"/>
+    <link rel="self" href="https://example.net/atom.xml"/>
+    <link rel="hub" href="https://hub.example.net/"/>
+    <icon>https://example.net/icon.png</icon>
+    <updated>2006-01-02T15:04:05Z</updated>
+    <entry xml:base="posts/">
+        <title>Text &amp; title</title>
+        <link rel="alternate" href="https://example.net/posts/1"/>
+        <link rel="enclosure" href="https://example.net/1.mp3"/>
+        <summary>1 &lt; 2</summary>
+        <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Body</p></div></content>
+        <updated>2006-01-03T00:00:00Z</updated>
+        <author><name>Carol</name></author>
+        <author><name>Dave</name></author>
+        <category term="tech" label="Technology"/>
+        <category term="misc"/>
+    </entry>
+</feed>`
+
+const jsonFeedDoc = `{
+    "version": "https://jsonfeed.org/version/1.1",
+    "title": "JSON Site",
+    "home_page_url": "https://example.io/",
+    "feed_url": "https://example.io/feed.json",
+    "favicon": "https://example.io/favicon.ico",
+    "hubs": [{"type": "rssCloud", "url": "https://cloud.example.io/"}, {"type": "WebSub", "url": "https://hub.example.io/"}],
+    "items": [
+        {
+            "id": "1",
+            "url": "https://example.io/1",
+            "title": "HTML item",
+            "summary": "Tom & <Jerry>",
+            "content_html": "<p>Hello</p>",
+            "date_published": "2006-01-02T15:04:05Z",
+            "authors": [{"name": "Erin"}],
+            "tags": ["a", "b"]
+        },
+        {
+            "id": "2",
+            "url": "https://example.io/2",
+            "title": "Text item",
+            "content_text": "x < y",
+            "date_modified": "2006-01-04T00:00:00Z",
+            "author": {"name": "Frank"}
+        }
+    ]
+}`
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        name    string
+        body    string
+        format  Format
+        channel RSSChannel
+        items   []RSSItem
+    }{
+        {
+            name:   "rss",
+            body:   rssDoc,
+            format: FormatRSS,
+            channel: RSSChannel{
+                Title:       "Example Blog",
+                Link:        "https://example.com/",
+                Description: "Posts & notes",
+                Language:    "en",
+                ImageURL:    "https://example.com/logo.png",
+                HubURL:      "https://hub.example.com/",
+                SelfURL:     "https://example.com/feed.xml",
+            },
+            items: []RSSItem{{
+                Title:       "First post",
+                Link:        "https://example.com/first",
+                Description: "<p>Summary</p>",
+                Content:     "<p>Full <b>body</b></p>",
+                PubDate:     "Mon, 02 Jan 2006 15:04:05 +0000",
+                Creator:     "Alice",
+                Categories:  []string{"go", "web"},
+            }},
+        },
+        {
+            name:   "rdf",
+            body:   rdfDoc,
+            format: FormatRDF,
+            channel: RSSChannel{
+                Title:         "RDF Site",
+                Link:          "https://example.org/",
+                Description:   "Old school",
+                LastBuildDate: "2006-01-02T15:04:05Z",
+                ImageURL:      "https://example.org/logo.gif",
+            },
+            items: []RSSItem{{
+                Title:       "Item one",
+                Link:        "https://example.org/1",
+                Description: "One",
+                PubDate:     "2006-01-02T15:04:05Z",
+                Creator:     "Bob",
+                Categories:  []string{"news"},
+            }},
+        },
+        {
+            name:   "atom",
+            body:   atomDoc,
+            format: FormatAtom,
+            channel: RSSChannel{
+                Title:         "Atom Site",
+                Link:          "https://example.net/",
+                Description:   "A <i>subtitle</i>",
+                Language:      "fr",
+                LastBuildDate: "2006-01-02T15:04:05Z",
+                ImageURL:      "https://example.net/icon.png",
+                HubURL:        "https://hub.example.net/",
+                SelfURL:       "https://example.net/atom.xml",
+            },
+            items: []RSSItem{{
+                Title:       "Text & title",
+                Link:        "https://example.net/posts/1",
+                Description: "1 &lt; 2",
+                Content:     `<div xmlns="http://www.w3.org/1999/xhtml"><p>Body</p></div>`,
+                PubDate:     "2006-01-03T00:00:00Z",
+                Base:        "https://example.net/posts/",
+                Creator:     "Carol, Dave",
+                Categories:  []string{"Technology", "misc"},
+            }},
+        },
+        {
+            name:   "json feed",
+            body:   jsonFeedDoc,
+            format: FormatJSONFeed,
+            channel: RSSChannel{
+                Title:    "JSON Site",
+                Link:     "https://example.io/",
+                ImageURL: "https://example.io/favicon.ico",
+                HubURL:   "https://hub.example.io/",
+                SelfURL:  "https://example.io/feed.json",
+            },
+            items: []RSSItem{
+                {
+                    Title:       "HTML item",
+                    Link:        "https://example.io/1",
+                    Description: "Tom &amp; &lt;Jerry&gt;",
+                    Content:     "<p>Hello</p>",
+                    PubDate:     "2006-01-02T15:04:05Z",
+                    Creator:     "Erin",
+                    Categories:  []string{"a", "b"},
+                },
+                {
+                    Title:       "Text item",
+                    Link:        "https://example.io/2",
+                    Description: "x &lt; y",
+                    Content:     "x &lt; y",
+                    PubDate:     "2006-01-04T00:00:00Z",
+                    Creator:     "Frank",
+                },
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            feed, err := Parse([]byte(tt.body))
+            if err != nil {
+                t.Fatalf("Parse: %v", err)
+            }
+            if feed.Format != tt.format {
+                t.Errorf("Format = %q, want %q", feed.Format, tt.format)
+            }
+            if len(feed.Warnings) != 0 {
+                t.Errorf("unexpected warnings: %v", feed.Warnings)
+            }
+
+            got := feed.Channel
+            got.Items, got.RawLinks, got.RawImages = nil, nil, nil
+            if !reflect.DeepEqual(got, tt.channel) {
+                t.Errorf("channel\n got %+v\nwant %+v", got, tt.channel)
+            }
+            for i := range feed.Channel.Items {
+                // Whitespace around chardata depends on the document's
+                // indentation, which isn't what's under test.
+                feed.Channel.Items[i].Description = strings.TrimSpace(feed.Channel.Items[i].Description)
+            }
+            if !reflect.DeepEqual(feed.Channel.Items, tt.items) {
+                t.Errorf("items\n got %+v\nwant %+v", feed.Channel.Items, tt.items)
+            }
+        })
+    }
+}
+
+func TestParseCharsets(t *testing.T) {
+    tests := []struct {
+        name        string
+        body        string
+        wantTitle   string
+        wantWarning string
+    }{
+        {
+            name:      "byte order mark",
+            body:      "\xef\xbb\xbf<rss><channel><title>café</title></channel></rss>",
+            wantTitle: "café",
+        },
+        {
+            name:        "declared latin-1",
+            body:        "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>caf\xe9</title></channel></rss>",
+            wantTitle:   "café",
+            wantWarning: `document is encoded as "ISO-8859-1" and was converted to UTF-8`,
+        },
+        {
+            name:        "declared windows-1252",
+            body:        "<?xml version='1.0' encoding='windows-1252'?><feed xmlns=\"http://www.w3.org/2005/Atom\"><title>\x93quoted\x94</title></feed>",
+            wantTitle:   "“quoted”",
+            wantWarning: `document is encoded as "windows-1252" and was converted to UTF-8`,
+        },
+        {
+            name:        "undeclared invalid utf-8",
+            body:        "<rss><channel><title>caf\xe9</title></channel></rss>",
+            wantTitle:   "caf�",
+            wantWarning: "document contains invalid UTF-8; invalid bytes were replaced",
+        },
+        {
+            name:        "invalid utf-8 declared as utf-8",
+            body:        "<?xml version=\"1.0\" encoding=\"utf-8\"?><rss><channel><title>a\xffb</title></channel></rss>",
+            wantTitle:   "a�b",
+            wantWarning: "document contains invalid UTF-8; invalid bytes were replaced",
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            feed, err := Parse([]byte(tt.body))
+            if err != nil {
+                t.Fatalf("Parse: %v", err)
+            }
+            if feed.Channel.Title != tt.wantTitle {
+                t.Errorf("Title = %q, want %q", feed.Channel.Title, tt.wantTitle)
+            }
+            var want []string
+            if tt.wantWarning != "" {
+                want = []string{tt.wantWarning}
+            }
+            if !reflect.DeepEqual(feed.Warnings, want) {
+                t.Errorf("Warnings = %q, want %q", feed.Warnings, want)
+            }
+        })
+    }
+}
+
+func TestParseErrors(t *testing.T) {
+    tests := []struct {
+        name        string
+        body        string
+        wantUnknown bool
+    }{
+        {"empty", "", true},
+        {"html page", "<!DOCTYPE html><html><body>hi</body></html>", true},
+        {"json without version", `{"title": "not a feed"}`, true},
+        {"unknown encoding", `<?xml version="1.0" encoding="x-made-up"?><rss></rss>`, false},
+        {"broken json", `{"version": `, false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            feed, err := Parse([]byte(tt.body))
+            if err == nil {
+                t.Fatalf("Parse succeeded with %+v", feed)
+            }
+            if errors.Is(err, ErrUnknownFormat) != tt.wantUnknown {
+                t.Errorf("err = %v, want ErrUnknownFormat: %v", err, tt.wantUnknown)
+            }
+        })
+    }
+}
+
+func TestParseDate(t *testing.T) {
+    want := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+
+    tests := []struct {
+        in   string
+        want time.Time
+    }{
+        {"Mon, 02 Jan 2006 15:04:05 +0000", want},
+        {"Mon, 02 Jan 2006 10:04:05 -0500", want},
+        {"Mon, 02 Jan 2006 15:04:05 GMT", want},
+        {"Mon, 2 Jan 2006 15:04:05 +0000", want},
+        {"2 Jan 2006 15:04:05 +0000", want},
+        {"Mon, 2 Jan 2006 15:04 +0000", want.Add(-5 * time.Second)},
+        {"02 Jan 06 15:04 +0000", want.Add(-5 * time.Second)},
+        {"Monday, 02-Jan-06 15:04:05 UTC", want},
+        {"2006-01-02T15:04:05Z", want},
+        {"2006-01-02T17:04:05+02:00", want},
+        {"2006-01-02T15:04:05.123Z", want.Add(123 * time.Millisecond)},
+        {"2006-01-02T15:04:05", want},
+        {"2006-01-02 15:04:05", want},
+        {"  2006-01-02  ", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+    }
+
+    for _, tt := range tests {
+        got, err := ParseDate(tt.in)
+        if err != nil {
+            t.Errorf("ParseDate(%q): %v", tt.in, err)
+            continue
+        }
+        if !got.Equal(tt.want) || got.Location() != time.UTC {
+            t.Errorf("ParseDate(%q) = %v, want %v", tt.in, got, tt.want)
+        }
+    }
+
+    for _, in := range []string{"", "yesterday", "02/01/2006", "2006-13-45"} {
+        if got, err := ParseDate(in); err == nil {
+            t.Errorf("ParseDate(%q) = %v, want an error", in, got)
+        }
+    }
+}
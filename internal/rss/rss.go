@@ -4,16 +4,87 @@ import (
     "context"
     "encoding/xml"
     "fmt"
+    "io"
     "net/http"
+    "strings"
 )
 
+// RSSFeed is the parsed form of a feed. Atom, RDF and JSON Feed documents are
+// mapped onto the same structure; Format records which one it came from.
 type RSSFeed struct {
     Channel RSSChannel `xml:"channel"`
+
+    Format   Format   `xml:"-"`
+    Warnings []string `xml:"-"`
 }
 
 type RSSChannel struct {
-    Title string    `xml:"title"`
-    Items []RSSItem `xml:"item"`
+    Title         string    `xml:"title"`
+    Description   string    `xml:"description"`
+    Language      string    `xml:"language"`
+    Generator     string    `xml:"generator"`
+    LastBuildDate string    `xml:"lastBuildDate"`
+    Items         []RSSItem `xml:"item"`
+
+    // Link and ImageURL are resolved from RawLinks and RawImages after
+    // decoding, because other namespaces (atom:link, itunes:image) reuse
+    // the same element names.
+    Link     string `xml:"-"`
+    ImageURL string `xml:"-"`
+
+    // HubURL and SelfURL come from <link rel="hub"> and <link rel="self">
+    // and are used to subscribe to the feed over WebSub.
+    HubURL  string `xml:"-"`
+    SelfURL string `xml:"-"`
+
+    RawLinks  []RSSLink  `xml:"link"`
+    RawImages []RSSImage `xml:"image"`
+}
+
+// RSSLink is any <link> element inside a channel, namespaced or not.
+type RSSLink struct {
+    XMLName xml.Name
+    Href    string `xml:"href,attr"`
+    Rel     string `xml:"rel,attr"`
+    Text    string `xml:",chardata"`
+}
+
+// RSSImage is any <image> element inside a channel: the RSS <image><url>
+// form or the iTunes <itunes:image href="..."> form.
+type RSSImage struct {
+    URL  string `xml:"url"`
+    Href string `xml:"href,attr"`
+}
+
+// resolve fills in Link and ImageURL from the raw elements.
+func (c *RSSChannel) resolve() {
+    for _, l := range c.RawLinks {
+        if l.XMLName.Space == "" && strings.TrimSpace(l.Text) != "" {
+            c.Link = strings.TrimSpace(l.Text)
+            break
+        }
+    }
+    for _, l := range c.RawLinks {
+        href := strings.TrimSpace(l.Href)
+        if href == "" {
+            continue
+        }
+        switch {
+        case l.Rel == "hub" && c.HubURL == "":
+            c.HubURL = href
+        case l.Rel == "self" && c.SelfURL == "":
+            c.SelfURL = href
+        }
+    }
+    for _, img := range c.RawImages {
+        if u := strings.TrimSpace(img.URL); u != "" {
+            c.ImageURL = u
+            break
+        }
+        if u := strings.TrimSpace(img.Href); u != "" && c.ImageURL == "" {
+            c.ImageURL = u
+        }
+    }
 }
 
 type RSSItem struct {
@@ -21,6 +92,20 @@ type RSSItem struct {
     Link        string `xml:"link"`
     Description string `xml:"description"`
     PubDate     string `xml:"pubDate"`
+
+    // Content is the full body (content:encoded, Atom <content>, JSON Feed
+    // content_html) when the feed provides one separately from Description.
+    Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+
+    // Base is the item's xml:base, used to resolve relative URLs in its
+    // markup.
+    Base string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+
+    // Author is RSS's <author>, usually an email address; Creator is the
+    // Dublin Core name most feeds use instead.
+    Author     string   `xml:"author"`
+    Creator    string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+    Categories []string `xml:"category"`
 }
 
 // FetchRSSFeed fetches & parses a remote RSS feed URL
@@ -30,23 +115,50 @@ func FetchRSSFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
         return nil, fmt.Errorf("create request: %w", err)
     }
 
-    resp, err := http.DefaultClient.Do(req)
+    resp, err := Client.Do(req)
     if err != nil {
         return nil, fmt.Errorf("do request: %w", err)
     }
     defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+        return nil, &StatusError{StatusCode: resp.StatusCode}
     }
 
-    var rss RSSFeed
-    decoder := xml.NewDecoder(resp.Body)
-    if err := decoder.Decode(&rss); err != nil {
-        return nil, fmt.Errorf("decode rss: %w", err)
+    body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+    if err != nil {
+        return nil, fmt.Errorf("read body: %w", err)
     }
 
-    return &rss, nil
+    feed, err := Parse(body)
+    if err != nil {
+        return nil, &ParseError{Err: err}
+    }
+    return feed, nil
+}
+
+// StatusError is returned by FetchRSSFeed when the server answers with
+// anything but 200 OK.
+type StatusError struct {
+    StatusCode int
+}
+
+func (e *StatusError) Error() string {
+    return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// ParseError is returned by FetchRSSFeed when the response isn't a feed it
+// can read. Its message is the parser's.
+type ParseError struct {
+    Err error
+}
+
+func (e *ParseError) Error() string {
+    return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+    return e.Err
 }
 
 func DebugTestFetchRSS() {
@@ -0,0 +1,69 @@
+package rules
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/sanitize"
+    "github.com/mdbailin/go-rss-server/internal/stream"
+)
+
+// FromPost builds the view of a stored post that rules match against.
+func FromPost(p database.Post) Post {
+    text := p.PlainText
+    if text == "" {
+        html := p.Content
+        if strings.TrimSpace(html) == "" {
+            html = p.Description
+        }
+        text = sanitize.Text(html)
+    }
+
+    return Post{
+        Title:      p.Title,
+        Content:    text,
+        Author:     p.Author,
+        Categories: p.Categories,
+    }
+}
+
+// Apply performs a rule's action on a post for the rule's owner. Actions
+// that change the post's read, star or hidden state tell the owner's live
+// connections, as marking a post by hand does; inside a transaction the
+// event is only sent once it commits.
+func Apply(ctx context.Context, db *database.Queries, rule database.FilterRule, postID uuid.UUID) error {
+    now := time.Now().UTC()
+
+    var err error
+    switch rule.Action {
+    case ActionHide:
+        err = db.HidePost(ctx, database.HidePostParams{UserID: rule.UserID, PostID: postID, Now: now})
+    case ActionMarkRead:
+        err = db.MarkPostRead(ctx, database.MarkPostReadParams{UserID: rule.UserID, PostID: postID, Now: now})
+    case ActionStar:
+        err = db.StarPost(ctx, database.StarPostParams{UserID: rule.UserID, PostID: postID, Now: now})
+    case ActionTag:
+        return db.TagPost(ctx, database.TagPostParams{
+            UserID:    rule.UserID,
+            PostID:    postID,
+            Tag:       rule.Tag,
+            CreatedAt: now,
+        })
+    default:
+        return fmt.Errorf("unknown action %q", rule.Action)
+    }
+    if err != nil {
+        return err
+    }
+
+    return stream.Publish(ctx, db, stream.Event{
+        Type:   stream.EventPostState,
+        PostID: postID,
+        UserID: rule.UserID,
+    })
+}
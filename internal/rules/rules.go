@@ -0,0 +1,144 @@
+package rules
+
+import (
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// Fields a rule can match against.
+const (
+    FieldAny      = "any"
+    FieldTitle    = "title"
+    FieldContent  = "content"
+    FieldAuthor   = "author"
+    FieldCategory = "category"
+)
+
+// Ways a rule's pattern is interpreted.
+const (
+    // MatchKeyword matches if any of the pattern's comma-separated keywords
+    // appears in the field, ignoring case.
+    MatchKeyword = "keyword"
+    // MatchRegex matches the pattern as a Go regular expression.
+    MatchRegex = "regex"
+)
+
+// Actions a matching rule applies to a post.
+const (
+    ActionHide     = "hide"
+    ActionMarkRead = "mark_read"
+    ActionStar     = "star"
+    ActionTag      = "tag"
+)
+
+// maxPatternLength keeps user-supplied patterns (and compiled regexps) small.
+const maxPatternLength = 1000
+
+// Post is the part of a post rules look at. Content should be plain text.
+type Post struct {
+    Title      string
+    Content    string
+    Author     string
+    Categories []string
+}
+
+// Matcher is a compiled rule condition.
+type Matcher struct {
+    field    string
+    keywords []string
+    re       *regexp.Regexp
+}
+
+// Compile validates a rule's condition and prepares it for matching.
+func Compile(field, matchType, pattern string) (*Matcher, error) {
+    switch field {
+    case FieldAny, FieldTitle, FieldContent, FieldAuthor, FieldCategory:
+    default:
+        return nil, fmt.Errorf("unknown field %q", field)
+    }
+
+    if strings.TrimSpace(pattern) == "" {
+        return nil, errors.New("pattern is required")
+    }
+    if len(pattern) > maxPatternLength {
+        return nil, fmt.Errorf("pattern longer than %d characters", maxPatternLength)
+    }
+
+    m := &Matcher{field: field}
+    switch matchType {
+    case MatchKeyword:
+        for _, kw := range strings.Split(pattern, ",") {
+            if kw = strings.ToLower(strings.TrimSpace(kw)); kw != "" {
+                m.keywords = append(m.keywords, kw)
+            }
+        }
+        if len(m.keywords) == 0 {
+            return nil, errors.New("pattern has no keywords")
+        }
+    case MatchRegex:
+        re, err := regexp.Compile(pattern)
+        if err != nil {
+            return nil, fmt.Errorf("invalid regex: %w", err)
+        }
+        m.re = re
+    default:
+        return nil, fmt.Errorf("unknown match type %q", matchType)
+    }
+
+    return m, nil
+}
+
+// ValidateAction checks a rule's action, and that tag rules name a tag.
+func ValidateAction(action, tag string) error {
+    switch action {
+    case ActionHide, ActionMarkRead, ActionStar:
+        return nil
+    case ActionTag:
+        if strings.TrimSpace(tag) == "" {
+            return errors.New("tag is required for tag rules")
+        }
+        return nil
+    default:
+        return fmt.Errorf("unknown action %q", action)
+    }
+}
+
+// Match reports whether the post satisfies the condition.
+func (m *Matcher) Match(p Post) bool {
+    for _, text := range m.texts(p) {
+        if m.matchText(text) {
+            return true
+        }
+    }
+    return false
+}
+
+func (m *Matcher) texts(p Post) []string {
+    switch m.field {
+    case FieldTitle:
+        return []string{p.Title}
+    case FieldContent:
+        return []string{p.Content}
+    case FieldAuthor:
+        return []string{p.Author}
+    case FieldCategory:
+        return p.Categories
+    default:
+        return append([]string{p.Title, p.Content, p.Author}, p.Categories...)
+    }
+}
+
+func (m *Matcher) matchText(text string) bool {
+    if m.re != nil {
+        return m.re.MatchString(text)
+    }
+    lower := strings.ToLower(text)
+    for _, kw := range m.keywords {
+        if strings.Contains(lower, kw) {
+            return true
+        }
+    }
+    return false
+}
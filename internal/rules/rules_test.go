@@ -0,0 +1,92 @@
+package rules
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestCompileErrors(t *testing.T) {
+    tests := []struct {
+        name                      string
+        field, matchType, pattern string
+    }{
+        {"unknown field", "body", MatchKeyword, "go"},
+        {"unknown match type", FieldTitle, "glob", "go*"},
+        {"empty pattern", FieldTitle, MatchKeyword, "  "},
+        {"only commas", FieldTitle, MatchKeyword, " , ,"},
+        {"bad regex", FieldTitle, MatchRegex, "(unclosed"},
+        {"pattern too long", FieldTitle, MatchKeyword, strings.Repeat("a", maxPatternLength+1)},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if _, err := Compile(tt.field, tt.matchType, tt.pattern); err == nil {
+                t.Errorf("Compile(%q, %q, %q) succeeded, want an error", tt.field, tt.matchType, tt.pattern)
+            }
+        })
+    }
+}
+
+func TestMatch(t *testing.T) {
+    post := Post{
+        Title:      "Go 1.22 Released",
+        Content:    "<p>Range over integers and better loops.</p>",
+        Author:     "The Go Team",
+        Categories: []string{"release", "Programming"},
+    }
+
+    tests := []struct {
+        name                      string
+        field, matchType, pattern string
+        want                      bool
+    }{
+        {"keyword in title", FieldTitle, MatchKeyword, "released", true},
+        {"keyword is case-insensitive", FieldTitle, MatchKeyword, "GO 1.22", true},
+        {"any keyword in a list", FieldTitle, MatchKeyword, "rust, released", true},
+        {"no keyword in the list", FieldTitle, MatchKeyword, "rust, zig", false},
+        {"keyword only checks its field", FieldTitle, MatchKeyword, "integers", false},
+        {"keyword in content", FieldContent, MatchKeyword, "integers", true},
+        {"keyword in author", FieldAuthor, MatchKeyword, "go team", true},
+        {"keyword in a category", FieldCategory, MatchKeyword, "programming", true},
+        {"any field checks content", FieldAny, MatchKeyword, "loops", true},
+        {"any field checks categories", FieldAny, MatchKeyword, "release", true},
+        {"any field without a match", FieldAny, MatchKeyword, "python", false},
+        {"regex in title", FieldTitle, MatchRegex, `^Go 1\.\d+`, true},
+        {"regex is case-sensitive", FieldTitle, MatchRegex, `^go`, false},
+        {"regex with flags", FieldTitle, MatchRegex, `(?i)^go`, true},
+        {"regex against categories", FieldCategory, MatchRegex, `^rel`, true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            m, err := Compile(tt.field, tt.matchType, tt.pattern)
+            if err != nil {
+                t.Fatalf("Compile: %v", err)
+            }
+            if got := m.Match(post); got != tt.want {
+                t.Errorf("Match = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestValidateAction(t *testing.T) {
+    tests := []struct {
+        action, tag string
+        wantErr     bool
+    }{
+        {ActionHide, "", false},
+        {ActionMarkRead, "", false},
+        {ActionStar, "", false},
+        {ActionTag, "golang", false},
+        {ActionTag, " ", true},
+        {"delete", "", true},
+    }
+
+    for _, tt := range tests {
+        err := ValidateAction(tt.action, tt.tag)
+        if (err != nil) != tt.wantErr {
+            t.Errorf("ValidateAction(%q, %q) = %v, wantErr %v", tt.action, tt.tag, err, tt.wantErr)
+        }
+    }
+}
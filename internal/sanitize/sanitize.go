@@ -0,0 +1,262 @@
+package sanitize
+
+import (
+    "net/url"
+    "strconv"
+    "strings"
+
+    "golang.org/x/net/html"
+)
+
+// Policy is an allowlist describing which markup survives sanitization.
+// Anything not listed is removed.
+type Policy struct {
+    // Tags maps allowed element names to the attributes allowed on them.
+    Tags map[string][]string
+
+    // GlobalAttrs are allowed on every allowed element.
+    GlobalAttrs []string
+
+    // URLAttrs are attributes holding URLs; their values are resolved
+    // against the base URL and must use one of URLSchemes.
+    URLAttrs   []string
+    URLSchemes []string
+
+    // DropContent lists elements that are removed together with everything
+    // inside them rather than just unwrapped.
+    DropContent []string
+}
+
+// DefaultPolicy allows basic formatting, links, images and tables, and
+// strips scripts, styles, frames, forms and event handlers.
+var DefaultPolicy = &Policy{
+    Tags: map[string][]string{
+        "a":          {"href"},
+        "abbr":       nil,
+        "b":          nil,
+        "blockquote": {"cite"},
+        "br":         nil,
+        "caption":    nil,
+        "cite":       nil,
+        "code":       nil,
+        "dd":         nil,
+        "del":        nil,
+        "details":    nil,
+        "dfn":        nil,
+        "div":        nil,
+        "dl":         nil,
+        "dt":         nil,
+        "em":         nil,
+        "figcaption": nil,
+        "figure":     nil,
+        "h1":         nil,
+        "h2":         nil,
+        "h3":         nil,
+        "h4":         nil,
+        "h5":         nil,
+        "h6":         nil,
+        "hr":         nil,
+        "i":          nil,
+        "img":        {"src", "alt", "width", "height"},
+        "ins":        nil,
+        "kbd":        nil,
+        "li":         nil,
+        "mark":       nil,
+        "ol":         {"start"},
+        "p":          nil,
+        "pre":        nil,
+        "q":          {"cite"},
+        "s":          nil,
+        "samp":       nil,
+        "small":      nil,
+        "span":       nil,
+        "strong":     nil,
+        "sub":        nil,
+        "summary":    nil,
+        "sup":        nil,
+        "table":      nil,
+        "tbody":      nil,
+        "td":         {"colspan", "rowspan"},
+        "tfoot":      nil,
+        "th":         {"colspan", "rowspan", "scope"},
+        "thead":      nil,
+        "time":       {"datetime"},
+        "tr":         nil,
+        "u":          nil,
+        "ul":         nil,
+    },
+    GlobalAttrs: []string{"title", "lang", "dir"},
+    URLAttrs:    []string{"href", "src", "cite"},
+    URLSchemes:  []string{"http", "https", "mailto"},
+    DropContent: []string{
+        "script", "style", "iframe", "frame", "frameset", "object", "embed",
+        "applet", "noscript", "template", "form", "select", "textarea",
+        "button", "svg", "math", "head", "title",
+    },
+}
+
+// voidElements never have content or an end tag.
+var voidElements = map[string]bool{
+    "br": true, "hr": true, "img": true, "wbr": true,
+    "area": true, "base": true, "col": true, "embed": true, "input": true,
+    "link": true, "meta": true, "param": true, "source": true, "track": true,
+}
+
+// HTML sanitizes an HTML fragment with DefaultPolicy. Relative URLs are
+// resolved against base, which may be nil.
+func HTML(s string, base *url.URL) string {
+    return DefaultPolicy.HTML(s, base)
+}
+
+// HTML sanitizes an HTML fragment according to the policy. Relative URLs
+// are resolved against base, which may be nil. Unbalanced tags are closed so
+// the result can be embedded safely.
+func (p *Policy) HTML(s string, base *url.URL) string {
+    var b strings.Builder
+    var open []string
+    dropDepth := 0
+    dropTag := ""
+
+    z := html.NewTokenizer(strings.NewReader(s))
+    for {
+        tt := z.Next()
+        if tt == html.ErrorToken {
+            break
+        }
+        tok := z.Token()
+
+        if dropDepth > 0 {
+            switch tt {
+            case html.StartTagToken:
+                if tok.Data == dropTag {
+                    dropDepth++
+                }
+            case html.EndTagToken:
+                if tok.Data == dropTag {
+                    dropDepth--
+                }
+            }
+            continue
+        }
+
+        switch tt {
+        case html.TextToken:
+            b.WriteString(html.EscapeString(tok.Data))
+
+        case html.StartTagToken, html.SelfClosingTagToken:
+            if contains(p.DropContent, tok.Data) {
+                if tt == html.StartTagToken && !voidElements[tok.Data] {
+                    dropDepth, dropTag = 1, tok.Data
+                }
+                continue
+            }
+            allowed, ok := p.Tags[tok.Data]
+            if !ok {
+                continue
+            }
+            attrs := p.filterAttrs(tok, allowed, base)
+            if tok.Data == "img" && (isTrackingPixel(attrs) || getAttr(attrs, "src") == "") {
+                continue
+            }
+            if tok.Data == "a" && getAttr(attrs, "href") != "" {
+                attrs = append(attrs, html.Attribute{Key: "rel", Val: "nofollow noopener noreferrer"})
+            }
+            writeStartTag(&b, tok.Data, attrs)
+            if !voidElements[tok.Data] && tt == html.StartTagToken {
+                open = append(open, tok.Data)
+            }
+
+        case html.EndTagToken:
+            // Close back to the matching open element; stray end tags are
+            // ignored.
+            for i := len(open) - 1; i >= 0; i-- {
+                if open[i] != tok.Data {
+                    continue
+                }
+                for j := len(open) - 1; j >= i; j-- {
+                    b.WriteString("</" + open[j] + ">")
+                }
+                open = open[:i]
+                break
+            }
+        }
+    }
+
+    for i := len(open) - 1; i >= 0; i-- {
+        b.WriteString("</" + open[i] + ">")
+    }
+
+    return b.String()
+}
+
+func (p *Policy) filterAttrs(tok html.Token, allowed []string, base *url.URL) []html.Attribute {
+    var out []html.Attribute
+    for _, a := range tok.Attr {
+        key := strings.ToLower(a.Key)
+        if a.Namespace != "" || (!contains(allowed, key) && !contains(p.GlobalAttrs, key)) {
+            continue
+        }
+        val := a.Val
+        if contains(p.URLAttrs, key) {
+            var ok bool
+            if val, ok = p.cleanURL(val, base); !ok {
+                continue
+            }
+        }
+        out = append(out, html.Attribute{Key: key, Val: val})
+    }
+    return out
+}
+
+// cleanURL resolves raw against base and reports whether the result uses an
+// allowed scheme.
+func (p *Policy) cleanURL(raw string, base *url.URL) (string, bool) {
+    u, err := url.Parse(strings.TrimSpace(raw))
+    if err != nil {
+        return "", false
+    }
+    if base != nil {
+        u = base.ResolveReference(u)
+    }
+    if !contains(p.URLSchemes, strings.ToLower(u.Scheme)) {
+        return "", false
+    }
+    return u.String(), true
+}
+
+// isTrackingPixel reports whether an image is sized 0 or 1 pixel in either
+// dimension, the usual shape of an analytics beacon.
+func isTrackingPixel(attrs []html.Attribute) bool {
+    for _, key := range []string{"width", "height"} {
+        if n, err := strconv.Atoi(strings.TrimSuffix(getAttr(attrs, key), "px")); err == nil && n <= 1 {
+            return true
+        }
+    }
+    return false
+}
+
+func writeStartTag(b *strings.Builder, tag string, attrs []html.Attribute) {
+    b.WriteString("<" + tag)
+    for _, a := range attrs {
+        b.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+    }
+    b.WriteString(">")
+}
+
+func getAttr(attrs []html.Attribute, key string) string {
+    for _, a := range attrs {
+        if a.Key == key {
+            return a.Val
+        }
+    }
+    return ""
+}
+
+func contains(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
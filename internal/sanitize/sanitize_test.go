@@ -0,0 +1,78 @@
+package sanitize
+
+import (
+    "net/url"
+    "testing"
+)
+
+func TestHTML(t *testing.T) {
+    base, _ := url.Parse("https://example.com/blog/post")
+
+    tests := []struct {
+        name string
+        in   string
+        want string
+    }{
+        {"formatting kept", `<p>Hi <b>there</b></p>`, `<p>Hi <b>there</b></p>`},
+        {"script dropped with content", `<p>a<script>alert(1)</script>b</p>`, `<p>ab</p>`},
+        {"script body is raw text", `<script><script>x</script>y</script>z`, `yz`},
+        {"nested dropped elements", `<object><object>x</object>y</object>z`, `z`},
+        {"style and iframe dropped", `<style>p{}</style><iframe src="x"></iframe>ok`, `ok`},
+        {"event handlers stripped", `<p onclick="x()" onmouseover="y()">a</p>`, `<p>a</p>`},
+        {"style attribute stripped", `<span style="position:fixed">a</span>`, `<span>a</span>`},
+        {"unknown tags unwrapped", `<custom>a<blink>b</blink></custom>`, `ab`},
+        {"javascript url removed", `<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+        {"javascript url with case and spaces", `<a href=" JaVaScRiPt:alert(1)">x</a>`, `<a>x</a>`},
+        {"javascript url with entity", `<a href="java&#x0A;script:alert(1)">x</a>`, `<a>x</a>`},
+        {"vbscript url removed", `<a href="vbscript:msgbox">x</a>`, `<a>x</a>`},
+        {"data image removed", `<img src="data:image/png;base64,AAA">`, ``},
+        {"relative link resolved", `<a href="/rel">x</a>`, `<a href="https://example.com/rel" rel="nofollow noopener noreferrer">x</a>`},
+        {"relative image resolved", `<img src="a.png" alt="b">`, `<img src="https://example.com/blog/a.png" alt="b">`},
+        {"mailto kept", `<a href="mailto:a@b.c">m</a>`, `<a href="mailto:a@b.c" rel="nofollow noopener noreferrer">m</a>`},
+        {"tracking pixel removed", `<img src="a.png" width="1" height="1">`, ``},
+        {"tracking pixel with px", `<img src="a.png" width="0px">`, ``},
+        {"unclosed tags closed", `<div><p>open`, `<div><p>open</p></div>`},
+        {"stray end tag ignored", `a</p>b`, `ab`},
+        {"text escaped", `x < y & z`, `x &lt; y &amp; z`},
+        {"attribute quotes escaped", `<a href="https://e.com/?q=&quot;x&quot;" title="a&quot;b">x</a>`, `<a href="https://e.com/?q=&#34;x&#34;" title="a&#34;b" rel="nofollow noopener noreferrer">x</a>`},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := HTML(tt.in, base); got != tt.want {
+                t.Errorf("HTML(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestHTMLWithoutBase(t *testing.T) {
+    got := HTML(`<a href="/rel">x</a><a href="javascript:x">y</a>`, nil)
+    want := `<a>x</a><a>y</a>`
+    if got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+}
+
+func TestText(t *testing.T) {
+    tests := []struct {
+        name string
+        in   string
+        want string
+    }{
+        {"markup removed", `<p>Hi <b>there</b></p>`, "Hi there"},
+        {"blocks become lines", `<h1>Title</h1><p>One</p><p>Two</p>`, "Title\nOne\nTwo"},
+        {"whitespace collapsed", "<p>  a \t b  </p>", "a b"},
+        {"script content dropped", `a<script>alert(1)</script>b`, "ab"},
+        {"cells separated", `<table><tr><td>a</td><td>b</td></tr></table>`, "a b"},
+        {"entities decoded", `x &lt; y &amp;amp; z`, "x < y &amp; z"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Text(tt.in); got != tt.want {
+                t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+            }
+        })
+    }
+}
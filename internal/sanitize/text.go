@@ -0,0 +1,68 @@
+package sanitize
+
+import (
+    "strings"
+
+    "golang.org/x/net/html"
+)
+
+// blockElements start a new line in the plain-text rendition.
+var blockElements = map[string]bool{
+    "address": true, "article": true, "aside": true, "blockquote": true,
+    "br": true, "dd": true, "div": true, "dl": true, "dt": true,
+    "figcaption": true, "figure": true, "footer": true, "h1": true,
+    "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+    "header": true, "hr": true, "li": true, "ol": true, "p": true,
+    "pre": true, "section": true, "table": true, "tr": true, "ul": true,
+}
+
+// Text renders an HTML fragment as plain text for search and previews:
+// markup is removed, block elements become line breaks and runs of
+// whitespace are collapsed.
+func Text(s string) string {
+    var b strings.Builder
+    dropDepth := 0
+    dropTag := ""
+
+    z := html.NewTokenizer(strings.NewReader(s))
+    for {
+        tt := z.Next()
+        if tt == html.ErrorToken {
+            break
+        }
+        tok := z.Token()
+
+        if dropDepth > 0 {
+            switch {
+            case tt == html.StartTagToken && tok.Data == dropTag:
+                dropDepth++
+            case tt == html.EndTagToken && tok.Data == dropTag:
+                dropDepth--
+            }
+            continue
+        }
+
+        switch tt {
+        case html.TextToken:
+            b.WriteString(tok.Data)
+        case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+            if tt == html.StartTagToken && contains(DefaultPolicy.DropContent, tok.Data) && !voidElements[tok.Data] {
+                dropDepth, dropTag = 1, tok.Data
+                continue
+            }
+            if blockElements[tok.Data] {
+                b.WriteString("\n")
+            } else if tok.Data == "td" || tok.Data == "th" {
+                b.WriteString(" ")
+            }
+        }
+    }
+
+    var lines []string
+    for _, line := range strings.Split(b.String(), "\n") {
+        if line = strings.Join(strings.Fields(line), " "); line != "" {
+            lines = append(lines, line)
+        }
+    }
+    return strings.Join(lines, "\n")
+}
@@ -0,0 +1,160 @@
+package stream
+
+import (
+    "context"
+    "encoding/json"
+    "log/slog"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+    "github.com/lib/pq"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+)
+
+// Channel is the Postgres NOTIFY channel events travel on, so every server
+// instance sees posts stored by any worker.
+const Channel = "stream_events"
+
+// subscriberBuffer is how many events a subscriber may fall behind by
+// before it is dropped.
+const subscriberBuffer = 64
+
+// Event types.
+const (
+    // EventPost: a new post was stored (PostID, FeedID).
+    EventPost = "post"
+    // EventPostState: a user read, unread, starred, unstarred, hid or
+    // unhid a post (UserID, PostID).
+    EventPostState = "post_state"
+    // EventFeedHealth: a feed started failing or recovered (FeedID).
+    EventFeedHealth = "feed_health"
+)
+
+// Event is a notification that something a client may be watching changed.
+// It carries IDs only; subscribers load what they need for their user.
+type Event struct {
+    Type   string    `json:"type"`
+    PostID uuid.UUID `json:"post_id"`
+    FeedID uuid.UUID `json:"feed_id"`
+    UserID uuid.UUID `json:"user_id"`
+}
+
+// Publish sends an event to every instance's Hub through NOTIFY.
+func Publish(ctx context.Context, db *database.Queries, e Event) error {
+    payload, err := json.Marshal(e)
+    if err != nil {
+        return err
+    }
+    return db.NotifyStreamEvent(ctx, string(payload))
+}
+
+// Hub fans events received from Postgres out to in-process subscribers.
+type Hub struct {
+    mu   sync.Mutex
+    subs map[chan Event]struct{}
+}
+
+func NewHub() *Hub {
+    return &Hub{subs: map[chan Event]struct{}{}}
+}
+
+// Subscribe returns a channel of events and a function to stop receiving
+// them. The channel is closed if the subscriber falls too far behind, so
+// it can reconnect and catch up rather than silently miss events.
+func (h *Hub) Subscribe() (<-chan Event, func()) {
+    ch := make(chan Event, subscriberBuffer)
+
+    h.mu.Lock()
+    h.subs[ch] = struct{}{}
+    h.mu.Unlock()
+
+    var once sync.Once
+    return ch, func() {
+        once.Do(func() {
+            h.mu.Lock()
+            defer h.mu.Unlock()
+            if _, ok := h.subs[ch]; ok {
+                delete(h.subs, ch)
+                close(ch)
+            }
+        })
+    }
+}
+
+func (h *Hub) broadcast(e Event) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    for ch := range h.subs {
+        select {
+        case ch <- e:
+        default:
+            delete(h.subs, ch)
+            close(ch)
+        }
+    }
+}
+
+// Listen LISTENs on Channel using its own connection to dbURL and
+// broadcasts what arrives until ctx is done. pq.Listener reconnects by
+// itself; events sent while it was disconnected are lost, which clients
+// recover from by resuming with Last-Event-ID. If the LISTEN itself is
+// refused, it is retried with backoff rather than leaving the hub dead.
+func (h *Hub) Listen(ctx context.Context, dbURL string) error {
+    backoff := time.Second
+    for {
+        listener, err := listen(dbURL)
+        if err == nil {
+            defer listener.Close()
+            return h.receive(ctx, listener)
+        }
+
+        slog.Error("stream listen failed", "error", err, "retry_in", backoff.String())
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-time.After(backoff):
+        }
+        backoff = min(backoff*2, time.Minute)
+    }
+}
+
+func listen(dbURL string) (*pq.Listener, error) {
+    listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+        if err != nil {
+            slog.Error("stream listener error", "error", err)
+        }
+    })
+    if err := listener.Listen(Channel); err != nil {
+        listener.Close()
+        return nil, err
+    }
+    return listener, nil
+}
+
+// receive broadcasts notifications from listener until ctx is done.
+func (h *Hub) receive(ctx context.Context, listener *pq.Listener) error {
+    for {
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+
+        case n := <-listener.Notify:
+            // nil is sent after a reconnect.
+            if n == nil {
+                continue
+            }
+            var e Event
+            if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+                slog.Error("bad stream event payload", "payload", n.Extra, "error", err)
+                continue
+            }
+            h.broadcast(e)
+
+        case <-time.After(90 * time.Second):
+            go listener.Ping()
+        }
+    }
+}
@@ -0,0 +1,145 @@
+package webhooks
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+)
+
+// EventPostCreated is sent when the worker stores a new post.
+const EventPostCreated = "post.created"
+
+// Headers sent with every delivery.
+const (
+    HeaderEvent     = "X-Webhook-Event"
+    HeaderDelivery  = "X-Webhook-Delivery"
+    HeaderSignature = "X-Webhook-Signature"
+)
+
+// Retry schedule: the first retry waits baseBackoff and each later one
+// doubles it, up to maxBackoff. A delivery is abandoned after MaxAttempts.
+const (
+    MaxAttempts = 8
+    baseBackoff = 30 * time.Second
+    maxBackoff  = 6 * time.Hour
+)
+
+// maxResponseSize caps how much of a receiver's response we read.
+const maxResponseSize = 64 << 10
+
+// Payload is the JSON body of a post.created delivery.
+type Payload struct {
+    Event string      `json:"event"`
+    Feed  PayloadFeed `json:"feed"`
+    Post  PayloadPost `json:"post"`
+}
+
+type PayloadFeed struct {
+    ID   uuid.UUID `json:"id"`
+    Name string    `json:"name"`
+    URL  string    `json:"url"`
+}
+
+type PayloadPost struct {
+    ID          uuid.UUID `json:"id"`
+    Title       string    `json:"title"`
+    URL         string    `json:"url"`
+    Description string    `json:"description"`
+    Content     string    `json:"content"`
+    Author      string    `json:"author,omitempty"`
+    Categories  []string  `json:"categories,omitempty"`
+    PublishedAt time.Time `json:"published_at"`
+}
+
+// PostCreated builds the payload announcing a new post.
+func PostCreated(feed database.Feed, post database.Post) ([]byte, error) {
+    return json.Marshal(Payload{
+        Event: EventPostCreated,
+        Feed: PayloadFeed{
+            ID:   feed.ID,
+            Name: feed.Name,
+            URL:  feed.Url,
+        },
+        Post: PayloadPost{
+            ID:          post.ID,
+            Title:       post.Title,
+            URL:         post.Url,
+            Description: post.Description,
+            Content:     post.Content,
+            Author:      post.Author,
+            Categories:  post.Categories,
+            PublishedAt: post.PublishedAt,
+        },
+    })
+}
+
+// NewSecret returns a random signing secret for a new webhook.
+func NewSecret() (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Webhook-Signature value for a body sent at ts:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">". Receivers
+// recompute the HMAC with their secret, compare in constant time and reject
+// old timestamps to prevent replays.
+func Sign(secret string, ts time.Time, body []byte) string {
+    t := strconv.FormatInt(ts.Unix(), 10)
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(t))
+    mac.Write([]byte("."))
+    mac.Write(body)
+    return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send POSTs a signed delivery. Any 2xx response counts as success; the
+// status code is returned whenever the receiver answered.
+func Send(ctx context.Context, client *http.Client, url, secret string, deliveryID uuid.UUID, event string, body []byte) (int, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+    if err != nil {
+        return 0, fmt.Errorf("create request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("User-Agent", "go-rss-server-webhooks/1.0")
+    req.Header.Set(HeaderEvent, event)
+    req.Header.Set(HeaderDelivery, deliveryID.String())
+    req.Header.Set(HeaderSignature, Sign(secret, time.Now(), body))
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return 0, fmt.Errorf("do request: %w", err)
+    }
+    defer resp.Body.Close()
+    io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+    }
+    return resp.StatusCode, nil
+}
+
+// Backoff returns how long to wait before retrying a delivery that has
+// failed attempts times.
+func Backoff(attempts int) time.Duration {
+    d := baseBackoff
+    for i := 1; i < attempts && d < maxBackoff; i++ {
+        d *= 2
+    }
+    return min(d, maxBackoff)
+}
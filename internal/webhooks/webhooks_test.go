@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+func TestSign(t *testing.T) {
+    ts := time.Unix(1700000000, 0)
+    body := []byte(`{"event":"post.created"}`)
+
+    mac := hmac.New(sha256.New, []byte("whsec_test"))
+    mac.Write([]byte("1700000000." + string(body)))
+    want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+    tests := []struct {
+        name   string
+        secret string
+        ts     time.Time
+        body   []byte
+        same   bool
+    }{
+        {"same inputs", "whsec_test", ts, body, true},
+        {"different secret", "whsec_other", ts, body, false},
+        {"different time", "whsec_test", ts.Add(time.Second), body, false},
+        {"different body", "whsec_test", ts, []byte(`{"event":"post.deleted"}`), false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := Sign(tt.secret, tt.ts, tt.body)
+            if (got == want) != tt.same {
+                t.Errorf("Sign = %q, want equal to %q: %v", got, want, tt.same)
+            }
+        })
+    }
+}
+
+func TestBackoff(t *testing.T) {
+    tests := []struct {
+        attempts int
+        want     time.Duration
+    }{
+        {0, 30 * time.Second},
+        {1, 30 * time.Second},
+        {2, time.Minute},
+        {3, 2 * time.Minute},
+        {5, 8 * time.Minute},
+        {MaxAttempts, 64 * time.Minute},
+        {10, 256 * time.Minute},
+        {11, 6 * time.Hour},
+        {100, 6 * time.Hour},
+    }
+
+    for _, tt := range tests {
+        if got := Backoff(tt.attempts); got != tt.want {
+            t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+        }
+    }
+}
+
+func TestSend(t *testing.T) {
+    tests := []struct {
+        name    string
+        status  int
+        wantErr bool
+    }{
+        {"ok", http.StatusOK, false},
+        {"accepted", http.StatusAccepted, false},
+        {"redirect is a failure", http.StatusNotModified, true},
+        {"server error", http.StatusInternalServerError, true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            body := []byte(`{"event":"post.created"}`)
+            id := uuid.New()
+
+            srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                got, _ := io.ReadAll(r.Body)
+                if string(got) != string(body) {
+                    t.Errorf("body = %q, want %q", got, body)
+                }
+                if r.Header.Get(HeaderEvent) != EventPostCreated {
+                    t.Errorf("%s = %q", HeaderEvent, r.Header.Get(HeaderEvent))
+                }
+                if r.Header.Get(HeaderDelivery) != id.String() {
+                    t.Errorf("%s = %q, want %s", HeaderDelivery, r.Header.Get(HeaderDelivery), id)
+                }
+
+                // Verify the signature the way a receiver would.
+                sig := r.Header.Get(HeaderSignature)
+                ts, _, _ := strings.Cut(strings.TrimPrefix(sig, "t="), ",")
+                unix, err := strconv.ParseInt(ts, 10, 64)
+                if err != nil {
+                    t.Errorf("bad signature header %q", sig)
+                } else if Sign("whsec_test", time.Unix(unix, 0), got) != sig {
+                    t.Errorf("signature %q does not verify", sig)
+                }
+                w.WriteHeader(tt.status)
+            }))
+            defer srv.Close()
+
+            status, err := Send(context.Background(), srv.Client(), srv.URL, "whsec_test", id, EventPostCreated, body)
+            if status != tt.status {
+                t.Errorf("status = %d, want %d", status, tt.status)
+            }
+            if (err != nil) != tt.wantErr {
+                t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+            }
+        })
+    }
+}
@@ -0,0 +1,190 @@
+// Package websub subscribes feeds to their WebSub hubs so new content is
+// pushed to us instead of polled for.
+package websub
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha1"
+    "crypto/sha256"
+    "crypto/sha512"
+    "database/sql"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "hash"
+    "io"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+)
+
+// Subscription states, as stored in websub_subscriptions.state.
+const (
+    StatePending = "pending"
+    StateActive  = "active"
+    StateDenied  = "denied"
+    StateFailed  = "failed"
+)
+
+// LeaseSeconds is the lease we ask hubs for. Hubs may grant a different one.
+const LeaseSeconds = 10 * 24 * 60 * 60
+
+// SignatureHeader carries the HMAC of pushed content.
+const SignatureHeader = "X-Hub-Signature"
+
+// maxResponseSize caps how much of a hub's response we read.
+const maxResponseSize = 64 << 10
+
+// Subscriber sends subscription requests to hubs. Hubs call back to
+// CallbackBase + "/websub/{id}" to verify intent and deliver content.
+type Subscriber struct {
+    DB           *database.Queries
+    Client       *http.Client
+    CallbackBase string
+}
+
+// CallbackURL is where the hub reaches the given subscription.
+func (s *Subscriber) CallbackURL(id uuid.UUID) string {
+    return strings.TrimRight(s.CallbackBase, "/") + "/websub/" + id.String()
+}
+
+// Ensure makes sure the feed is subscribed to hub for topic. An existing
+// subscription to the same hub and topic is left to the renewal worker; one
+// to a different hub is replaced. An empty hub drops the subscription so
+// the feed goes back to being polled.
+func (s *Subscriber) Ensure(ctx context.Context, feedID uuid.UUID, hub, topic string) error {
+    if hub == "" {
+        _, err := s.DB.DeleteWebSubSubscriptionForFeed(ctx, feedID)
+        return err
+    }
+
+    existing, err := s.DB.GetWebSubSubscriptionForFeed(ctx, feedID)
+    if err == nil && existing.HubUrl == hub && existing.TopicUrl == topic {
+        return nil
+    }
+    if err != nil && !errors.Is(err, sql.ErrNoRows) {
+        return fmt.Errorf("get subscription: %w", err)
+    }
+
+    secret, err := newSecret()
+    if err != nil {
+        return fmt.Errorf("generate secret: %w", err)
+    }
+    sub, err := s.DB.UpsertWebSubSubscription(ctx, database.UpsertWebSubSubscriptionParams{
+        ID:       uuid.New(),
+        Now:      time.Now().UTC(),
+        FeedID:   feedID,
+        HubUrl:   hub,
+        TopicUrl: topic,
+        Secret:   secret,
+    })
+    if err != nil {
+        return fmt.Errorf("store subscription: %w", err)
+    }
+
+    return s.request(ctx, sub)
+}
+
+// Renew asks the hub again for a subscription that is about to lapse or
+// never became active.
+func (s *Subscriber) Renew(ctx context.Context, sub database.WebsubSubscription) error {
+    if err := s.DB.MarkWebSubRequested(ctx, database.MarkWebSubRequestedParams{
+        Now: time.Now().UTC(),
+        ID:  sub.ID,
+    }); err != nil {
+        return fmt.Errorf("mark requested: %w", err)
+    }
+    return s.request(ctx, sub)
+}
+
+// request sends the subscription request. The hub answers 202 Accepted and
+// then verifies our intent asynchronously; any other response is recorded
+// as a failure.
+func (s *Subscriber) request(ctx context.Context, sub database.WebsubSubscription) error {
+    err := s.post(ctx, sub)
+    if err != nil {
+        if ferr := s.DB.FailWebSubSubscription(ctx, database.FailWebSubSubscriptionParams{
+            LastError: err.Error(),
+            Now:       time.Now().UTC(),
+            ID:        sub.ID,
+        }); ferr != nil {
+            return fmt.Errorf("%v (and recording it: %v)", err, ferr)
+        }
+    }
+    return err
+}
+
+func (s *Subscriber) post(ctx context.Context, sub database.WebsubSubscription) error {
+    form := url.Values{
+        "hub.mode":          {"subscribe"},
+        "hub.topic":         {sub.TopicUrl},
+        "hub.callback":      {s.CallbackURL(sub.ID)},
+        "hub.secret":        {sub.Secret},
+        "hub.lease_seconds": {strconv.Itoa(LeaseSeconds)},
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.HubUrl, strings.NewReader(form.Encode()))
+    if err != nil {
+        return fmt.Errorf("create request: %w", err)
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+    resp, err := s.Client.Do(req)
+    if err != nil {
+        return fmt.Errorf("do request: %w", err)
+    }
+    defer resp.Body.Close()
+    io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return fmt.Errorf("hub responded with status code %d", resp.StatusCode)
+    }
+    return nil
+}
+
+// VerifySignature checks an X-Hub-Signature header ("<method>=<hex HMAC>")
+// against body. sha1, sha256, sha384 and sha512 are accepted.
+func VerifySignature(secret, header string, body []byte) bool {
+    method, sig, ok := strings.Cut(header, "=")
+    if !ok {
+        return false
+    }
+
+    var h func() hash.Hash
+    switch strings.ToLower(method) {
+    case "sha1":
+        h = sha1.New
+    case "sha256":
+        h = sha256.New
+    case "sha384":
+        h = sha512.New384
+    case "sha512":
+        h = sha512.New
+    default:
+        return false
+    }
+
+    want, err := hex.DecodeString(sig)
+    if err != nil {
+        return false
+    }
+    mac := hmac.New(h, []byte(secret))
+    mac.Write(body)
+    return hmac.Equal(mac.Sum(nil), want)
+}
+
+func newSecret() (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+    "context"
+    "database/sql"
+    "log/slog"
+    "net/url"
+    "time"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+    "github.com/mdbailin/go-rss-server/internal/icons"
+    "github.com/mdbailin/go-rss-server/internal/rss"
+)
+
+// iconMaxAge is how long a resolved (or missing) icon is trusted before we
+// look for it again.
+const iconMaxAge = 7 * 24 * time.Hour
+
+// RunIconWorker periodically resolves and stores icons for feeds whose icon
+// is missing or stale.
+func RunIconWorker(db *database.Queries, interval time.Duration, batchSize int32) {
+    slog.Info("starting icon worker", "interval", interval.String(), "batch_size", batchSize)
+
+    for {
+        ctx := context.Background()
+
+        feeds, err := db.GetFeedsNeedingIcon(ctx, database.GetFeedsNeedingIconParams{
+            IconCheckedAt: sql.NullTime{Time: time.Now().UTC().Add(-iconMaxAge), Valid: true},
+            Limit:         batchSize,
+        })
+        if err != nil {
+            slog.Error("getting feeds needing icons failed", logging.Err(err))
+            time.Sleep(interval)
+            continue
+        }
+
+        for _, feed := range feeds {
+            refreshIcon(ctx, db, feed)
+        }
+
+        time.Sleep(interval)
+    }
+}
+
+func refreshIcon(ctx context.Context, db *database.Queries, feed database.Feed) {
+    siteURL := feed.SiteUrl.String
+    if siteURL == "" {
+        if u, err := url.Parse(feed.Url); err == nil {
+            siteURL = (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+        }
+    }
+
+    icon, err := icons.Resolve(ctx, rss.Client, feed.ImageUrl.String, siteURL)
+    if err != nil {
+        feedLogger(feed).Debug("no icon for feed", logging.Err(err))
+    } else {
+        now := time.Now().UTC()
+        err = db.UpsertFeedIcon(ctx, database.UpsertFeedIconParams{
+            FeedID:      feed.ID,
+            CreatedAt:   now,
+            UpdatedAt:   now,
+            Url:         icon.URL,
+            ContentType: icon.ContentType,
+            Data:        icon.Data,
+            Hash:        icon.Hash,
+        })
+        if err != nil {
+            feedLogger(feed).Error("storing icon failed", logging.Err(err))
+        }
+    }
+
+    if err := db.MarkFeedIconChecked(ctx, feed.ID); err != nil {
+        feedLogger(feed).Error("marking icon checked failed", logging.Err(err))
+    }
+}
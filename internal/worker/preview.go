@@ -0,0 +1,78 @@
+package worker
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/mdbailin/go-rss-server/internal/rss"
+)
+
+// Preview describes what the worker would make of a feed, without storing
+// anything.
+type Preview struct {
+    URL       string        `json:"url"`
+    Format    rss.Format    `json:"format"`
+    Title     string        `json:"title"`
+    ItemCount int           `json:"item_count"`
+    Items     []PreviewItem `json:"items"`
+    Warnings  []string      `json:"warnings"`
+}
+
+// PreviewItem is a single feed item as it would be stored.
+type PreviewItem struct {
+    Title       string     `json:"title"`
+    URL         string     `json:"url"`
+    RawDate     string     `json:"raw_date"`
+    PublishedAt *time.Time `json:"published_at"`
+    Skipped     bool       `json:"skipped"`
+    Warnings    []string   `json:"warnings,omitempty"`
+}
+
+// PreviewFeed fetches and parses feedURL through the same path as
+// processFeed and reports the result.
+func PreviewFeed(ctx context.Context, feedURL string) (*Preview, error) {
+    parsed, err := rss.FetchRSSFeed(ctx, feedURL)
+    if err != nil {
+        return nil, err
+    }
+
+    preview := &Preview{
+        URL:       feedURL,
+        Format:    parsed.Format,
+        Title:     parsed.Channel.Title,
+        ItemCount: len(parsed.Channel.Items),
+        Items:     make([]PreviewItem, 0, len(parsed.Channel.Items)),
+        Warnings:  append([]string{}, parsed.Warnings...),
+    }
+
+    if preview.Title == "" {
+        preview.Warnings = append(preview.Warnings, "feed has no title")
+    }
+    if preview.ItemCount == 0 {
+        preview.Warnings = append(preview.Warnings, "feed has no items")
+    }
+
+    for i, item := range parsed.Channel.Items {
+        post, warnings := normalizeItem(feedURL, item)
+
+        p := PreviewItem{
+            Title:    post.Title,
+            URL:      post.URL,
+            RawDate:  item.PubDate,
+            Skipped:  post.Skip,
+            Warnings: warnings,
+        }
+        if post.DateParsed {
+            t := post.PublishedAt
+            p.PublishedAt = &t
+        }
+        preview.Items = append(preview.Items, p)
+
+        for _, w := range warnings {
+            preview.Warnings = append(preview.Warnings, fmt.Sprintf("item %d: %s", i+1, w))
+        }
+    }
+
+    return preview, nil
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+    "context"
+    "log/slog"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+    "github.com/mdbailin/go-rss-server/internal/rules"
+)
+
+// feedRule is a stored filter rule with its condition compiled.
+type feedRule struct {
+    rule    database.FilterRule
+    matcher *rules.Matcher
+}
+
+// loadFeedRules fetches and compiles the enabled rules that apply to a
+// feed. Rules that no longer compile are logged and skipped.
+func loadFeedRules(ctx context.Context, db *database.Queries, logger *slog.Logger, feedID uuid.UUID) []feedRule {
+    rows, err := db.GetActiveFilterRulesForFeed(ctx, feedID)
+    if err != nil {
+        logger.Error("loading rules failed", logging.Err(err))
+        return nil
+    }
+
+    out := make([]feedRule, 0, len(rows))
+    for _, r := range rows {
+        m, err := rules.Compile(r.Field, r.MatchType, r.Pattern)
+        if err != nil {
+            logger.Warn("skipping invalid rule", "rule_id", r.ID, logging.Err(err))
+            continue
+        }
+        out = append(out, feedRule{rule: r, matcher: m})
+    }
+    return out
+}
+
+// applyFeedRules runs a feed's rules against a newly stored post and
+// returns the IDs of the rules that matched.
+func applyFeedRules(ctx context.Context, db *database.Queries, logger *slog.Logger, feedRules []feedRule, post database.Post) []uuid.UUID {
+    if len(feedRules) == 0 {
+        return nil
+    }
+
+    view := rules.FromPost(post)
+
+    var matched []uuid.UUID
+    for _, fr := range feedRules {
+        if !fr.matcher.Match(view) {
+            continue
+        }
+        matched = append(matched, fr.rule.ID)
+
+        if err := rules.Apply(ctx, db, fr.rule, post.ID); err != nil {
+            logger.Error("applying rule failed", "rule_id", fr.rule.ID, "post_id", post.ID, logging.Err(err))
+        }
+    }
+    return matched
+}
@@ -0,0 +1,178 @@
+package worker
+
+import (
+    "context"
+    "database/sql"
+    "log/slog"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/google/uuid"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+    "github.com/mdbailin/go-rss-server/internal/webhooks"
+)
+
+const (
+    // webhookLease is how long a claimed delivery is reserved for the worker
+    // sending it. It must outlast the HTTP client timeout.
+    webhookLease = 2 * time.Minute
+
+    // webhookMaxFailures is how many failed attempts in a row, across all
+    // of a webhook's deliveries, disable it.
+    webhookMaxFailures = 25
+
+    // webhookRetention is how long finished deliveries and their attempt
+    // logs are kept.
+    webhookRetention = 30 * 24 * time.Hour
+)
+
+// enqueueWebhooks writes a post.created delivery to the outbox for every
+// webhook interested in a newly stored post.
+func enqueueWebhooks(ctx context.Context, db *database.Queries, logger *slog.Logger, feed database.Feed, post database.Post, matchedRules []uuid.UUID) {
+    payload, err := webhooks.PostCreated(feed, post)
+    if err != nil {
+        logger.Error("building webhook payload failed", "post_id", post.ID, logging.Err(err))
+        return
+    }
+
+    if matchedRules == nil {
+        matchedRules = []uuid.UUID{}
+    }
+    if _, err := db.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+        Now:            time.Now().UTC(),
+        PostID:         post.ID,
+        Event:          webhooks.EventPostCreated,
+        Payload:        string(payload),
+        FeedID:         feed.ID,
+        MatchedRuleIds: matchedRules,
+    }); err != nil {
+        logger.Error("queueing webhooks failed", "post_id", post.ID, logging.Err(err))
+    }
+}
+
+// RunWebhookWorker drains the webhook outbox, sending due deliveries with
+// client and rescheduling failures with exponential backoff. Redirects are
+// not followed; a 3xx response counts as a failure.
+func RunWebhookWorker(db *database.Queries, client *http.Client, interval time.Duration, batchSize int32) {
+    slog.Info("starting webhook worker", "interval", interval.String(), "batch_size", batchSize)
+
+    c := *client
+    c.CheckRedirect = func(*http.Request, []*http.Request) error {
+        return http.ErrUseLastResponse
+    }
+
+    var lastCleanup time.Time
+    for {
+        ctx := context.Background()
+        now := time.Now().UTC()
+
+        if now.Sub(lastCleanup) > time.Hour {
+            if n, err := db.DeleteOldWebhookDeliveries(ctx, now.Add(-webhookRetention)); err != nil {
+                slog.Error("deleting old webhook deliveries failed", logging.Err(err))
+            } else if n > 0 {
+                slog.Info("deleted old webhook deliveries", "count", n)
+            }
+            lastCleanup = now
+        }
+
+        deliveries, err := db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+            LeaseUntil: now.Add(webhookLease),
+            Now:        now,
+            RowLimit:   batchSize,
+        })
+        if err != nil {
+            slog.Error("claiming webhook deliveries failed", logging.Err(err))
+            time.Sleep(interval)
+            continue
+        }
+
+        var wg sync.WaitGroup
+        for _, d := range deliveries {
+            delivery := d
+            wg.Add(1)
+
+            go func() {
+                defer wg.Done()
+                deliverWebhook(ctx, db, &c, delivery)
+            }()
+        }
+        wg.Wait()
+
+        // Keep going straight away while there is a backlog.
+        if int32(len(deliveries)) < batchSize {
+            time.Sleep(interval)
+        }
+    }
+}
+
+func deliverWebhook(ctx context.Context, db *database.Queries, client *http.Client, d database.ClaimDueWebhookDeliveriesRow) {
+    logger := slog.With("webhook_id", d.WebhookID, "delivery_id", d.ID)
+    start := time.Now()
+    status, sendErr := webhooks.Send(ctx, client, d.Url, d.Secret, d.ID, d.Event, []byte(d.Payload))
+    now := time.Now().UTC()
+
+    responseStatus := sql.NullInt32{Int32: int32(status), Valid: status != 0}
+    errText := ""
+    if sendErr != nil {
+        errText = sendErr.Error()
+    }
+
+    if err := db.CreateWebhookDeliveryAttempt(ctx, database.CreateWebhookDeliveryAttemptParams{
+        ID:             uuid.New(),
+        DeliveryID:     d.ID,
+        AttemptedAt:    now,
+        ResponseStatus: responseStatus,
+        Error:          errText,
+        DurationMs:     int32(time.Since(start).Milliseconds()),
+    }); err != nil {
+        logger.Error("recording webhook attempt failed", logging.Err(err))
+    }
+
+    if sendErr == nil {
+        if err := db.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+            Now:            now,
+            ResponseStatus: int32(status),
+            ID:             d.ID,
+        }); err != nil {
+            logger.Error("marking webhook delivery succeeded failed", logging.Err(err))
+        }
+        if err := db.ResetWebhookFailures(ctx, d.WebhookID); err != nil {
+            logger.Error("resetting webhook failures failed", logging.Err(err))
+        }
+        return
+    }
+
+    attempts := int(d.Attempts) + 1
+    giveUp := attempts >= webhooks.MaxAttempts
+    logger.Warn("webhook delivery failed", "url", d.Url, "attempt", attempts, "status", status, logging.Err(sendErr))
+
+    if err := db.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+        GiveUp:         giveUp,
+        Now:            now,
+        ResponseStatus: responseStatus,
+        LastError:      errText,
+        NextAttemptAt:  now.Add(webhooks.Backoff(attempts)),
+        ID:             d.ID,
+    }); err != nil {
+        logger.Error("marking webhook delivery failed failed", logging.Err(err))
+    }
+
+    failures, err := db.IncrementWebhookFailures(ctx, d.WebhookID)
+    if err != nil {
+        logger.Error("counting webhook failures failed", logging.Err(err))
+        return
+    }
+    if failures >= webhookMaxFailures {
+        logger.Warn("disabling webhook after consecutive failures", "failures", failures)
+        if err := db.DisableWebhook(ctx, database.DisableWebhookParams{
+            Now:    now,
+            Reason: "too many consecutive failures, last: " + errText,
+            ID:     d.WebhookID,
+        }); err != nil {
+            logger.Error("disabling webhook failed", logging.Err(err))
+        }
+    }
+}
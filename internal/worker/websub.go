@@ -0,0 +1,55 @@
+package worker
+
+import (
+    "context"
+    "log/slog"
+    "time"
+
+    "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+    "github.com/mdbailin/go-rss-server/internal/websub"
+)
+
+const (
+    // websubRenewBefore is how long before its lease ends a subscription is
+    // renewed.
+    websubRenewBefore = 24 * time.Hour
+
+    // websubRetryAfter is how long to wait after a request before asking a
+    // hub again, whether to retry a failed subscription or because the hub
+    // never verified a renewal.
+    websubRetryAfter = time.Hour
+)
+
+// RunWebSubWorker renews WebSub leases before they run out and retries
+// subscriptions that failed or were never verified. A subscription that
+// lapses anyway just means its feed goes back to being polled.
+func RunWebSubWorker(db *database.Queries, sub *websub.Subscriber, interval time.Duration, batchSize int32) {
+    slog.Info("starting websub worker", "interval", interval.String(), "batch_size", batchSize)
+
+    for {
+        ctx := context.Background()
+        now := time.Now().UTC()
+
+        subs, err := db.GetWebSubSubscriptionsToRenew(ctx, database.GetWebSubSubscriptionsToRenewParams{
+            RetryBefore: now.Add(-websubRetryAfter),
+            RenewBefore: now.Add(websubRenewBefore),
+            RowLimit:    batchSize,
+        })
+        if err != nil {
+            slog.Error("getting websub subscriptions to renew failed", logging.Err(err))
+            time.Sleep(interval)
+            continue
+        }
+
+        for _, s := range subs {
+            if err := sub.Renew(ctx, s); err != nil {
+                slog.Error("renewing websub subscription failed", "subscription_id", s.ID, "feed_id", s.FeedID, "hub", s.HubUrl, logging.Err(err))
+                continue
+            }
+            slog.Info("requested websub subscription", "subscription_id", s.ID, "feed_id", s.FeedID, "hub", s.HubUrl)
+        }
+
+        time.Sleep(interval)
+    }
+}
@@ -2,8 +2,12 @@ package worker
 
 import (
     "context"
+    "database/sql"
     "errors"
-    "log"
+    "fmt"
+    "log/slog"
+    "net"
+    "net/url"
     "strings"
     "sync"
     "time"
@@ -12,29 +16,60 @@ import (
     "github.com/lib/pq"
 
     "github.com/mdbailin/go-rss-server/internal/database"
+    "github.com/mdbailin/go-rss-server/internal/logging"
+    "github.com/mdbailin/go-rss-server/internal/metrics"
+    "github.com/mdbailin/go-rss-server/internal/netguard"
     "github.com/mdbailin/go-rss-server/internal/rss"
+    "github.com/mdbailin/go-rss-server/internal/sanitize"
+    "github.com/mdbailin/go-rss-server/internal/stream"
+    "github.com/mdbailin/go-rss-server/internal/websub"
 )
 
-func RunFeedWorker(db *database.Queries, interval time.Duration, batchSize int32) {
-    log.Println("worker: starting feed worker...")
+// Options tunes how the feed worker stores items.
+type Options struct {
+    // StorePlainText also stores a plain-text rendition of each post, for
+    // search and previews.
+    StorePlainText bool
 
+    // WebSub, if set, subscribes feeds that advertise a hub so new content
+    // is pushed to us. Without it every feed is polled.
+    WebSub *websub.Subscriber
+}
+
+// inactiveFeedRetention is how long a feed nobody follows is kept, with its
+// posts, in case someone follows it again.
+const inactiveFeedRetention = 30 * 24 * time.Hour
+
+func RunFeedWorker(db *database.Queries, interval time.Duration, batchSize int32, opts Options) {
+    slog.Info("starting feed worker", "interval", interval.String(), "batch_size", batchSize)
+
+    var lastCleanup time.Time
     for {
         ctx := context.Background()
 
+        if now := time.Now().UTC(); now.Sub(lastCleanup) > time.Hour {
+            if n, err := db.DeleteInactiveFeeds(ctx, now.Add(-inactiveFeedRetention)); err != nil {
+                slog.Error("deleting inactive feeds failed", logging.Err(err))
+            } else if n > 0 {
+                slog.Info("deleted feeds nobody follows", "count", n)
+            }
+            lastCleanup = now
+        }
+
         feeds, err := db.GetNextFeedsToFetch(ctx, batchSize)
         if err != nil {
-            log.Printf("worker: GetNextFeedsToFetch error: %v", err)
+            slog.Error("getting feeds to fetch failed", logging.Err(err))
             time.Sleep(interval)
             continue
         }
 
         if len(feeds) == 0 {
-            log.Println("worker: no feeds to fetch")
+            slog.Debug("no feeds to fetch")
             time.Sleep(interval)
             continue
         }
 
-        log.Printf("worker: fetching %d feeds...", len(feeds))
+        slog.Debug("fetching feeds", "count", len(feeds))
 
         var wg sync.WaitGroup
 
@@ -44,68 +79,365 @@ func RunFeedWorker(db *database.Queries, interval time.Duration, batchSize int32
 
             go func() {
                 defer wg.Done()
-                processFeed(ctx, db, feed)
+                processFeed(ctx, db, feed, opts)
             }()
         }
 
         wg.Wait()
-        log.Println("worker: batch complete")
+        slog.Debug("feed batch complete", "count", len(feeds))
         time.Sleep(interval)
     }
 }
 
-func processFeed(ctx context.Context, db *database.Queries, feed database.Feed) {
-    log.Printf("worker: fetching feed %s (%s)", feed.Name, feed.Url)
+// feedLogger returns a logger that tags lines with the feed they are about.
+func feedLogger(feed database.Feed) *slog.Logger {
+    return slog.With("feed_id", feed.ID, "url", feed.Url)
+}
+
+// fetchLogger is feedLogger for the lines about one fetch or push of a
+// feed. Each line also carries duration_ms, the time since start, and
+// items_new, the new posts res has counted so far.
+func fetchLogger(feed database.Feed, start time.Time, res *IngestResult) *slog.Logger {
+    h := fetchHandler{Handler: slog.Default().Handler(), start: start, res: res}
+    return slog.New(h).With("feed_id", feed.ID, "url", feed.Url)
+}
+
+// fetchHandler adds the fields of fetchLogger that change as the fetch goes
+// on, when each line is logged rather than when the logger is made.
+type fetchHandler struct {
+    slog.Handler
+    start time.Time
+    res   *IngestResult
+}
+
+func (h fetchHandler) Handle(ctx context.Context, r slog.Record) error {
+    r.AddAttrs(
+        slog.Int64("duration_ms", time.Since(h.start).Milliseconds()),
+        slog.Int("items_new", h.res.New),
+    )
+    return h.Handler.Handle(ctx, r)
+}
+
+func (h fetchHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    h.Handler = h.Handler.WithAttrs(attrs)
+    return h
+}
+
+func (h fetchHandler) WithGroup(name string) slog.Handler {
+    h.Handler = h.Handler.WithGroup(name)
+    return h
+}
+
+func processFeed(ctx context.Context, db *database.Queries, feed database.Feed, opts Options) {
+    start := time.Now()
+    var res IngestResult
+    logger := fetchLogger(feed, start, &res)
 
     parsed, err := rss.FetchRSSFeed(ctx, feed.Url)
     if err != nil {
-        log.Printf("worker: error fetching %s: %v", feed.Url, err)
+        kind := fetchErrorKind(err)
+        metrics.FeedFetched(time.Since(start), kind)
+        logger.Warn("fetching feed failed", logging.Err(err), "error_kind", kind)
+        recordFetchError(ctx, db, logger, feed, err)
         return
     }
+    metrics.FeedFetched(time.Since(start), "")
 
-    for _, item := range parsed.Channel.Items {
-        title := strings.TrimSpace(item.Title)
-        url := strings.TrimSpace(item.Link)
+    if n, err := db.ClearFeedFetchError(ctx, feed.ID); err != nil {
+        logger.Error("clearing fetch error failed", logging.Err(err))
+    } else if n > 0 {
+        publishFeedHealth(ctx, db, logger, feed)
+    }
+
+    ingest(ctx, db, logger, feed, parsed, opts, &res)
+    logger.Info("fetched feed",
+        "items", len(parsed.Channel.Items),
+        "items_duplicate", res.Duplicates,
+    )
 
-        if title == "" || url == "" {
+    if opts.WebSub != nil {
+        hub := resolveURL(feed.Url, parsed.Channel.HubURL)
+        topic := resolveURL(feed.Url, parsed.Channel.SelfURL)
+        if topic == "" {
+            topic = feed.Url
+        }
+        if err := opts.WebSub.Ensure(ctx, feed.ID, hub, topic); err != nil {
+            logger.Error("subscribing to websub hub failed", "hub", hub, logging.Err(err))
+        }
+    }
+}
+
+// IngestResult counts what Ingest did with a feed's items.
+type IngestResult struct {
+    // New is how many items were stored as new posts.
+    New int
+
+    // Duplicates is how many items were already stored.
+    Duplicates int
+}
+
+// Ingest stores the items of a parsed copy of feed as posts, running rules,
+// webhooks and notifications for each new one, and marks the feed fetched.
+// Polled fetches and content pushed by a WebSub hub both come through here.
+func Ingest(ctx context.Context, db *database.Queries, feed database.Feed, parsed *rss.RSSFeed, opts Options) IngestResult {
+    var res IngestResult
+    ingest(ctx, db, fetchLogger(feed, time.Now(), &res), feed, parsed, opts, &res)
+    return res
+}
+
+// ingest is Ingest, counting into res as it goes so logger's lines can
+// report progress.
+func ingest(ctx context.Context, db *database.Queries, logger *slog.Logger, feed database.Feed, parsed *rss.RSSFeed, opts Options, res *IngestResult) {
+    if err := db.UpdateFeedMetadata(ctx, feedMetadata(feed, parsed.Channel)); err != nil {
+        logger.Error("updating feed metadata failed", logging.Err(err))
+    }
+
+    feedRules := loadFeedRules(ctx, db, logger, feed.ID)
+
+    for _, item := range parsed.Channel.Items {
+        post, _ := normalizeItem(feed.Url, item)
+        if post.Skip {
             continue
         }
 
-        // Parse pub date; fall back to now if we can't parse
-        pubTime, err := time.Parse(time.RFC1123Z, item.PubDate)
-        if err != nil {
-            pubTime = time.Now().UTC()
+        plainText := ""
+        if opts.StorePlainText {
+            plainText = post.PlainText
         }
 
         now := time.Now().UTC()
-        _, err = db.CreatePost(ctx, database.CreatePostParams{
+        created, err := db.CreatePost(ctx, database.CreatePostParams{
             ID:          uuid.New(),
             CreatedAt:   now,
             UpdatedAt:   now,
-            Title:       title,
-            Url:         url,
-            Description: item.Description,
-            PublishedAt: pubTime,
+            Title:       post.Title,
+            Url:         post.URL,
+            Description: post.Description,
+            PublishedAt: post.PublishedAt,
             FeedID:      feed.ID,
+            Content:     post.Content,
+            PlainText:   plainText,
+            Author:      post.Author,
+            Categories:  post.Categories,
         })
         if err != nil {
             var pqErr *pq.Error
             if errors.As(err, &pqErr) && pqErr.Code == "23505" {
                 // duplicate URL – we’ve already stored this post; skip
-                log.Printf("worker: duplicate post url=%s, skipping", url)
+                res.Duplicates++
                 continue
             }
 
-            log.Printf("worker: error creating post for feed %s: %v", feed.ID, err)
+            logger.Error("storing post failed", "post_url", post.URL, logging.Err(err))
             continue
         }
 
-        log.Printf("worker: stored post %q from feed %s", title, feed.Name)
+        res.New++
+        logger.Debug("stored post", "post_id", created.ID, "post_url", post.URL)
+
+        // Rules run first so posts they hide don't notify anyone.
+        matched := applyFeedRules(ctx, db, logger, feedRules, created)
+
+        if _, err := db.CreateSavedSearchNotifications(ctx, created.ID); err != nil {
+            logger.Error("notifying saved searches failed", "post_id", created.ID, logging.Err(err))
+        }
+
+        enqueueWebhooks(ctx, db, logger, feed, created, matched)
+
+        if err := stream.Publish(ctx, db, stream.Event{
+            Type:   stream.EventPost,
+            PostID: created.ID,
+            FeedID: feed.ID,
+        }); err != nil {
+            logger.Error("publishing post failed", "post_id", created.ID, logging.Err(err))
+        }
     }
 
     if err := db.MarkFeedFetched(ctx, feed.ID); err != nil {
-        log.Printf("worker: error marking feed %s fetched: %v", feed.ID, err)
+        logger.Error("marking feed fetched failed", logging.Err(err))
+    }
+
+    metrics.PostsIngested(res.New, res.Duplicates)
+}
+
+// feedMetadata extracts the channel-level details we keep about a feed.
+// Relative site and image links are resolved against the feed URL.
+func feedMetadata(feed database.Feed, ch rss.RSSChannel) database.UpdateFeedMetadataParams {
+    params := database.UpdateFeedMetadataParams{
+        ID:          feed.ID,
+        Title:       nullString(ch.Title),
+        SiteUrl:     nullString(resolveURL(feed.Url, ch.Link)),
+        Description: nullString(ch.Description),
+        ImageUrl:    nullString(resolveURL(feed.Url, ch.ImageURL)),
+        Language:    nullString(ch.Language),
+        Generator:   nullString(ch.Generator),
+    }
+    if t, err := rss.ParseDate(ch.LastBuildDate); err == nil {
+        params.LastBuildDate = sql.NullTime{Time: t, Valid: true}
+    }
+    return params
+}
+
+func nullString(s string) sql.NullString {
+    s = strings.TrimSpace(s)
+    return sql.NullString{String: s, Valid: s != ""}
+}
+
+// resolveURL resolves ref against base, returning ref unchanged if either
+// fails to parse.
+func resolveURL(base, ref string) string {
+    ref = strings.TrimSpace(ref)
+    if ref == "" {
+        return ""
+    }
+    b, err := url.Parse(base)
+    if err != nil {
+        return ref
+    }
+    r, err := b.Parse(ref)
+    if err != nil {
+        return ref
+    }
+    return r.String()
+}
+
+// normalizedItem is a feed item cleaned up into the shape we store as a post.
+type normalizedItem struct {
+    Title       string
+    URL         string
+    Description string
+    Content     string
+    PlainText   string
+    Author      string
+    Categories  []string
+    PublishedAt time.Time
+
+    // DateParsed is false when the item's date was missing or unparseable
+    // and PublishedAt fell back to now.
+    DateParsed bool
+
+    // Skip is set for items we can't store, such as those without a link.
+    Skip bool
+}
+
+// normalizeItem prepares a parsed feed item for storage, returning any
+// problems found with it alongside. Markup is sanitized, with relative URLs
+// resolved against the item's xml:base, its link or the feed URL.
+func normalizeItem(feedURL string, item rss.RSSItem) (normalizedItem, []string) {
+    var warnings []string
+
+    post := normalizedItem{
+        Title: strings.TrimSpace(item.Title),
+        URL:   resolveURL(feedURL, item.Link),
+    }
+
+    base, _ := url.Parse(feedURL)
+    if base != nil {
+        ref := strings.TrimSpace(item.Base)
+        if ref == "" {
+            ref = strings.TrimSpace(item.Link)
+        }
+        if u, err := base.Parse(ref); err == nil {
+            base = u
+        }
+    }
+
+    post.Description = sanitize.HTML(item.Description, base)
+    post.Content = sanitize.HTML(item.Content, base)
+
+    text := post.Content
+    if strings.TrimSpace(text) == "" {
+        text = post.Description
+    }
+    post.PlainText = sanitize.Text(text)
+
+    post.Author = strings.TrimSpace(item.Creator)
+    if post.Author == "" {
+        post.Author = strings.TrimSpace(item.Author)
+    }
+
+    post.Categories = []string{}
+    seen := map[string]bool{}
+    for _, c := range item.Categories {
+        if c = strings.TrimSpace(c); c != "" && !seen[c] {
+            seen[c] = true
+            post.Categories = append(post.Categories, c)
+        }
+    }
+
+    if post.Title == "" {
+        warnings = append(warnings, "missing title")
+        post.Skip = true
+    }
+    if post.URL == "" {
+        warnings = append(warnings, "missing link")
+        post.Skip = true
+    }
+
+    // Parse pub date; fall back to now if we can't parse
+    pubTime, err := rss.ParseDate(item.PubDate)
+    if err != nil {
+        if item.PubDate == "" {
+            warnings = append(warnings, "missing date")
+        } else {
+            warnings = append(warnings, fmt.Sprintf("unparseable date %q", item.PubDate))
+        }
+        pubTime = time.Now().UTC()
     } else {
-        log.Printf("worker: marked feed %s as fetched", feed.Name)
+        post.DateParsed = true
+    }
+    post.PublishedAt = pubTime
+
+    return post, warnings
+}
+
+// fetchErrorKind sorts a fetch failure into a few kinds for metrics.
+func fetchErrorKind(err error) string {
+    var (
+        statusErr *rss.StatusError
+        parseErr  *rss.ParseError
+        dnsErr    *net.DNSError
+        netErr    net.Error
+    )
+    switch {
+    case errors.Is(err, netguard.ErrBlocked):
+        return "blocked"
+    case errors.As(err, &statusErr):
+        return "http_status"
+    case errors.As(err, &parseErr):
+        return "parse"
+    case errors.Is(err, context.DeadlineExceeded),
+        errors.As(err, &netErr) && netErr.Timeout():
+        return "timeout"
+    case errors.As(err, &dnsErr):
+        return "dns"
+    case errors.As(err, &netErr):
+        return "network"
+    }
+    return "other"
+}
+
+// recordFetchError stores why a feed couldn't be fetched. Clients are told
+// when a feed goes from healthy to failing, not on every failure.
+func recordFetchError(ctx context.Context, db *database.Queries, logger *slog.Logger, feed database.Feed, fetchErr error) {
+    failures, err := db.RecordFeedFetchError(ctx, database.RecordFeedFetchErrorParams{
+        ID:         feed.ID,
+        FetchError: sql.NullString{String: fetchErr.Error(), Valid: true},
+    })
+    if err != nil {
+        logger.Error("recording fetch error failed", logging.Err(err))
+        return
+    }
+    if failures == 1 {
+        publishFeedHealth(ctx, db, logger, feed)
+    }
+}
+
+func publishFeedHealth(ctx context.Context, db *database.Queries, logger *slog.Logger, feed database.Feed) {
+    if err := stream.Publish(ctx, db, stream.Event{
+        Type:   stream.EventFeedHealth,
+        FeedID: feed.ID,
+    }); err != nil {
+        logger.Error("publishing feed health failed", logging.Err(err))
     }
 }
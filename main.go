@@ -1,13 +1,19 @@
 package main
 
 import (
+    "crypto/subtle"
     "database/sql"
     "encoding/json"
+    "errors"
     "fmt"
-    "log"
+    "log/slog"
     "net/http"
+    "net/url"
+    "net"
     "os"
+    "regexp"
     "time"
+    "strconv"
     "strings"
     "context"
 
@@ -17,14 +23,74 @@ import (
     "github.com/google/uuid"
     _ "github.com/lib/pq"
 
+    "github.com/mdbailin/go-rss-server/internal/auth"
     "github.com/mdbailin/go-rss-server/internal/database"
-   // "github.com/mdbailin/go-rss-server/internal/rss"
+    "github.com/mdbailin/go-rss-server/internal/metrics"
+    "github.com/mdbailin/go-rss-server/internal/netguard"
+    "github.com/mdbailin/go-rss-server/internal/ratelimit"
+    "github.com/mdbailin/go-rss-server/internal/rss"
+    "github.com/mdbailin/go-rss-server/internal/sanitize"
+    "github.com/mdbailin/go-rss-server/internal/stream"
+    "github.com/mdbailin/go-rss-server/internal/websub"
     "github.com/mdbailin/go-rss-server/internal/worker"
     "github.com/mdbailin/go-rss-server/internal/httputil"
+    "github.com/mdbailin/go-rss-server/internal/logging"
 )
 
 type apiConfig struct {
-    DB *database.Queries
+    DB     *database.Queries
+    Conn   *sql.DB
+    Guard  *netguard.Guard
+    Stream *stream.Hub
+
+    // FeedOptions is how the feed worker stores posts; content pushed over
+    // WebSub is stored the same way.
+    FeedOptions worker.Options
+
+    // RegistrationMode says who may sign up with POST /v1/users: anyone
+    // (open), people with an invite code (invite), or nobody (closed).
+    RegistrationMode string
+
+    // MaxFeedsPerUser and MaxFollowsPerUser cap how many feeds a user may
+    // add and follow. Zero means no cap; admins are never capped.
+    MaxFeedsPerUser   int64
+    MaxFollowsPerUser int64
+
+    // TrustProxyHeaders says requests come through a single proxy that
+    // appends to X-Forwarded-For, so it can be used to tell clients apart.
+    TrustProxyHeaders bool
+}
+
+// rateLimits holds a limiter per group of routes.
+type rateLimits struct {
+    // IP caps every request from one address before its credentials are
+    // checked, so nobody can make us look up keys and sessions unthrottled.
+    IP *ratelimit.Limiter
+
+    // Auth covers signing up and logging in, and is always keyed by client
+    // IP so guessing passwords can't dodge it.
+    Auth  *ratelimit.Limiter
+    Read  *ratelimit.Limiter
+    Write *ratelimit.Limiter
+}
+
+// withTx runs fn inside a single database transaction. The transaction is
+// committed if fn returns nil and rolled back on any error.
+func (cfg *apiConfig) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+    tx, err := cfg.Conn.BeginTx(ctx, nil)
+    if err != nil {
+        return fmt.Errorf("begin tx: %w", err)
+    }
+    defer tx.Rollback()
+
+    if err := fn(cfg.DB.WithTx(tx)); err != nil {
+        return err
+    }
+
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("commit tx: %w", err)
+    }
+    return nil
 }
 
 //debugging
@@ -33,13 +99,13 @@ func (cfg *apiConfig) debugTestNextFeeds() {
 
     feeds, err := cfg.DB.GetNextFeedsToFetch(ctx, 10)
     if err != nil {
-        log.Printf("GetNextFeedsToFetch error: %v", err)
+        slog.Error("getting feeds to fetch failed", logging.Err(err))
         return
     }
 
-    log.Printf("Next feeds to fetch (%d):", len(feeds))
+    slog.Debug("next feeds to fetch", "count", len(feeds))
     for _, f := range feeds {
-        log.Printf("  id=%s name=%q last_fetched_at=%v", f.ID, f.Name, f.LastFetchedAt)
+        slog.Debug("next feed", "feed_id", f.ID, "name", f.Name, "last_fetched_at", f.LastFetchedAt)
     }
 
     if len(feeds) == 0 {
@@ -49,10 +115,10 @@ func (cfg *apiConfig) debugTestNextFeeds() {
     // Mark the first one as fetched
     first := feeds[0]
     if err := cfg.DB.MarkFeedFetched(ctx, first.ID); err != nil {
-        log.Printf("MarkFeedFetched error: %v", err)
+        slog.Error("marking feed fetched failed", logging.Err(err))
         return
     }
-    log.Printf("Marked feed %s as fetched", first.ID)
+    slog.Debug("marked feed fetched", "feed_id", first.ID)
 }
 
 type Feed struct {
@@ -61,46 +127,192 @@ type Feed struct {
     UpdatedAt     time.Time  `json:"updated_at"`
     Name          string     `json:"name"`
     URL           string     `json:"url"`
-    UserID        uuid.UUID  `json:"user_id"`
+    AddedBy       *uuid.UUID `json:"added_by"`
     LastFetchedAt *time.Time `json:"last_fetched_at"`
+
+    // FollowerCount is how many users follow the feed. Feeds nobody
+    // follows are deactivated, and deleted if nobody follows them again.
+    FollowerCount int32      `json:"follower_count"`
+    DeactivatedAt *time.Time `json:"deactivated_at"`
+
+    // Channel metadata, filled in by the worker on each fetch.
+    Title         *string    `json:"title"`
+    SiteURL       *string    `json:"site_url"`
+    Description   *string    `json:"description"`
+    ImageURL      *string    `json:"image_url"`
+    Language      *string    `json:"language"`
+    Generator     *string    `json:"generator"`
+    LastBuildDate *time.Time `json:"last_build_date"`
+
+    // Fetch health: the last error and how many fetches in a row failed.
+    FetchError    *string    `json:"fetch_error"`
+    FetchErrorAt  *time.Time `json:"fetch_error_at"`
+    FetchFailures int32      `json:"fetch_failures"`
+}
+
+// TimelinePost is a post as seen by a particular user, with their read,
+// star and hidden state and the tags their rules gave it.
+type TimelinePost struct {
+    ID          uuid.UUID `json:"id"`
+    CreatedAt   time.Time `json:"created_at"`
+    UpdatedAt   time.Time `json:"updated_at"`
+    Title       string    `json:"title"`
+    URL         string    `json:"url"`
+    Description string    `json:"description"`
+    Content     string    `json:"content"`
+    Author      string    `json:"author,omitempty"`
+    Categories  []string  `json:"categories,omitempty"`
+    PublishedAt time.Time `json:"published_at"`
+    FeedID      uuid.UUID `json:"feed_id"`
+    FeedTitle   string    `json:"feed_title"`
+    FeedColor   *string   `json:"feed_color"`
+    Read        bool      `json:"read"`
+    Starred     bool      `json:"starred"`
+    Hidden      bool      `json:"hidden,omitempty"`
+    Tags        []string  `json:"tags,omitempty"`
+}
+
+// FeedFollow is a user's follow of a feed with their settings for it. Feed
+// shows the feed under the user's title for it, if they set one.
+type FeedFollow struct {
+    ID               uuid.UUID  `json:"id"`
+    CreatedAt        time.Time  `json:"created_at"`
+    UpdatedAt        time.Time  `json:"updated_at"`
+    FeedID           uuid.UUID  `json:"feed_id"`
+    UserID           uuid.UUID  `json:"user_id"`
+    FolderID         *uuid.UUID `json:"folder_id"`
+    Title            *string    `json:"title"`
+    Color            *string    `json:"color"`
+    Priority         int32      `json:"priority"`
+    HideFromTimeline bool       `json:"hide_from_timeline"`
+    Notify           bool       `json:"notify"`
+    Feed             Feed       `json:"feed"`
 }
 
 func main() {
     godotenv.Load()
 
+    // LOG_LEVEL is debug, info, warn or error.
+    logLevel := slog.LevelInfo
+    if s := os.Getenv("LOG_LEVEL"); s != "" {
+        level, err := logging.ParseLevel(s)
+        if err != nil {
+            fatal("invalid LOG_LEVEL", logging.Err(err))
+        }
+        logLevel = level
+    }
+    logging.Setup(os.Stdout, logLevel)
+
     port := os.Getenv("PORT")
     dbURL := os.Getenv("DB_URL")
 
     db, err := sql.Open("postgres", dbURL)
     if err != nil {
-        log.Fatalf("failed to open db: %v", err)
+        fatal("failed to open db", logging.Err(err))
     }
     defer db.Close()
 
     dbQueries := database.New(db)
 
+    metrics.RegisterDB(db, "postgres")
+    metrics.RegisterQueueLag(dbQueries.GetFeedQueueLag)
+
+    // FETCH_ALLOWLIST lists CIDRs, IPs or hostnames that feeds may be
+    // fetched from even though they are private or internal.
+    guard, err := netguard.New(strings.Split(os.Getenv("FETCH_ALLOWLIST"), ","))
+    if err != nil {
+        fatal("invalid FETCH_ALLOWLIST", logging.Err(err))
+    }
+    rss.Client = guard.Client(30 * time.Second)
+
+    feedOptions := worker.Options{
+        StorePlainText: os.Getenv("STORE_PLAIN_TEXT") == "true",
+    }
+
+    // WEBSUB_CALLBACK_URL is this server's public base URL. Hubs must be able
+    // to reach it; without it feeds with a hub are polled like any other.
+    if callback := os.Getenv("WEBSUB_CALLBACK_URL"); callback != "" {
+        feedOptions.WebSub = &websub.Subscriber{
+            DB:           dbQueries,
+            Client:       guard.Client(15 * time.Second),
+            CallbackBase: callback,
+        }
+    }
+
+    registrationMode := os.Getenv("REGISTRATION_MODE")
+    switch registrationMode {
+    case "":
+        registrationMode = registrationOpen
+    case registrationOpen, registrationInvite, registrationClosed:
+    default:
+        fatal("invalid REGISTRATION_MODE: must be open, invite or closed", "value", registrationMode)
+    }
+
+    // MAX_FEEDS_PER_USER and MAX_FOLLOWS_PER_USER are per-user quotas; 0
+    // turns one off.
+    maxFeeds := envInt("MAX_FEEDS_PER_USER", 200)
+    maxFollows := envInt("MAX_FOLLOWS_PER_USER", 1000)
+
+    // RATE_LIMIT_IP, RATE_LIMIT_AUTH, RATE_LIMIT_READ and RATE_LIMIT_WRITE
+    // are limits like "60/m", or "off". RATE_LIMIT_IP is a ceiling per
+    // address shared by everyone behind it, so it is set well above the
+    // others.
+    limits := rateLimits{
+        IP:    ratelimit.New(envLimit("RATE_LIMIT_IP", "1200/m")),
+        Auth:  ratelimit.New(envLimit("RATE_LIMIT_AUTH", "10/m")),
+        Read:  ratelimit.New(envLimit("RATE_LIMIT_READ", "300/m")),
+        Write: ratelimit.New(envLimit("RATE_LIMIT_WRITE", "60/m")),
+    }
+
     cfg := apiConfig{
-        DB: dbQueries,
+        DB:                dbQueries,
+        Conn:              db,
+        Guard:             guard,
+        Stream:            stream.NewHub(),
+        FeedOptions:       feedOptions,
+        RegistrationMode:  registrationMode,
+        MaxFeedsPerUser:   maxFeeds,
+        MaxFollowsPerUser: maxFollows,
+        TrustProxyHeaders: os.Getenv("TRUST_PROXY_HEADERS") == "true",
     }
 
+    go func() {
+        if err := cfg.Stream.Listen(context.Background(), dbURL); err != nil {
+            slog.Error("stream listen failed", logging.Err(err))
+        }
+    }()
+
     //rss.DebugTestFetchRSS()
-    go worker.RunFeedWorker(cfg.DB, time.Minute, 10)
+    go worker.RunFeedWorker(cfg.DB, time.Minute, 10, cfg.FeedOptions)
+    go worker.RunIconWorker(cfg.DB, 10*time.Minute, 10)
+    go worker.RunWebhookWorker(cfg.DB, guard.Client(15*time.Second), 5*time.Second, 20)
+    if cfg.FeedOptions.WebSub != nil {
+        go worker.RunWebSubWorker(cfg.DB, cfg.FeedOptions.WebSub, 10*time.Minute, 20)
+    }
 
-    fmt.Println("Connected to DB!")
-    fmt.Println("Server starting on port:", port)
+    slog.Info("server starting", "port", port, "log_level", logLevel.String())
 
    // cfg.debugTestNextFeeds()
 
     r := chi.NewRouter()
 
+    r.Use(logging.RequestID)
+    r.Use(logging.AccessLog)
+    r.Use(metrics.Middleware)
+
     r.Use(cors.Handler(cors.Options{
         AllowedOrigins:   []string{"*"},
-        AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+        AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
         AllowedHeaders:   []string{"*"},
+        ExposedHeaders:   []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
         AllowCredentials: false,
     }))
 
     r.Route("/v1", func(v1 chi.Router) {
+        v1.Use(ratelimit.Middleware(limits.pickIP, cfg.ipRateLimitKey))
+        v1.Use(cfg.middlewareCredentials)
+        v1.Use(ratelimit.Middleware(limits.pick, cfg.rateLimitKey))
+
         v1.Get("/readiness", func(w http.ResponseWriter, r *http.Request) {
             httputil.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
         })
@@ -110,54 +322,478 @@ func main() {
 
 	v1.Post("/users", cfg.handleCreateUser)
 
-	v1.Post("/feeds", cfg.middlewareAuth(cfg.handleCreateFeed))
+	v1.Post("/login", cfg.handleLogin)
+
+	v1.Post("/logout", cfg.middlewareAuth(cfg.handleLogout))
+
+	v1.Get("/users/me", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetMe))
+
+	v1.Patch("/users/me", cfg.middlewareScope(auth.ScopeAccount, cfg.handleUpdateMe))
+
+	v1.Delete("/users/me", cfg.middlewareScope(auth.ScopeAccount, cfg.handleDeleteMe))
+
+	v1.Post("/users/me/rotate_key", cfg.middlewareScope(auth.ScopeAccount, cfg.handleRotateKey))
+
+	v1.Put("/users/me/password", cfg.middlewareScope(auth.ScopeAccount, cfg.handleSetPassword))
+
+	v1.Get("/admin/users", cfg.middlewareAdmin(cfg.handleAdminGetUsers))
+
+	v1.Post("/admin/users/{userID}/disable", cfg.middlewareAdmin(cfg.handleAdminSetUserDisabled(true)))
+
+	v1.Delete("/admin/users/{userID}/disable", cfg.middlewareAdmin(cfg.handleAdminSetUserDisabled(false)))
+
+	v1.Delete("/admin/users/{userID}", cfg.middlewareAdmin(cfg.handleAdminDeleteUser))
+
+	v1.Post("/admin/invites", cfg.middlewareAdmin(cfg.handleAdminCreateInvite))
+
+	v1.Get("/admin/invites", cfg.middlewareAdmin(cfg.handleAdminGetInvites))
+
+	v1.Delete("/admin/invites/{inviteID}", cfg.middlewareAdmin(cfg.handleAdminDeleteInvite))
+
+	v1.Post("/api_keys", cfg.middlewareScope(auth.ScopeAccount, cfg.handleCreateAPIKey))
+
+	v1.Get("/api_keys", cfg.middlewareScope(auth.ScopeAccount, cfg.handleGetAPIKeys))
+
+	v1.Delete("/api_keys/{apiKeyID}", cfg.middlewareScope(auth.ScopeAccount, cfg.handleDeleteAPIKey))
+
+	v1.Post("/feeds", cfg.middlewareScope(auth.ScopeFeedsWrite, cfg.handleCreateFeed))
+
+	v1.Post("/feeds/preview", cfg.middlewareScope(auth.ScopeFeedsWrite, cfg.handlePreviewFeed))
 
 	v1.Get("/feeds", cfg.handleGetFeeds)
 
+	v1.Get("/feeds/{feedID}/icon", cfg.handleGetFeedIcon)
+
 	v1.Get("/posts", cfg.handleGetPosts)
 
 	v1.Get("/posts/{postID}", cfg.handleGetPostByID)
 
-	v1.Post("/feed_follows", cfg.middlewareAuth(cfg.handleCreateFeedFollow))
+	v1.Post("/posts/{postID}/read", cfg.middlewareScope(auth.ScopePostsWrite, cfg.handlePostState(postStateRead)))
+
+	v1.Delete("/posts/{postID}/read", cfg.middlewareScope(auth.ScopePostsWrite, cfg.handlePostState(postStateUnread)))
+
+	v1.Post("/posts/{postID}/star", cfg.middlewareScope(auth.ScopePostsWrite, cfg.handlePostState(postStateStarred)))
+
+	v1.Delete("/posts/{postID}/star", cfg.middlewareScope(auth.ScopePostsWrite, cfg.handlePostState(postStateUnstarred)))
+
+	v1.Post("/posts/{postID}/hide", cfg.middlewareScope(auth.ScopePostsWrite, cfg.handlePostState(postStateHidden)))
+
+	v1.Delete("/posts/{postID}/hide", cfg.middlewareScope(auth.ScopePostsWrite, cfg.handlePostState(postStateUnhidden)))
+
+	v1.Get("/me/posts", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetTimeline))
+
+	v1.Get("/me/stream", cfg.middlewareAuthOrQuery(auth.ScopePostsRead, cfg.handleStream))
+
+	v1.Get("/ws", cfg.middlewareAuthOrQuery(auth.ScopePostsRead, cfg.handleWebSocket))
+
+	v1.Get("/search", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleSearch))
+
+	v1.Post("/saved_searches", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleCreateSavedSearch))
+
+	v1.Get("/saved_searches", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetSavedSearches))
+
+	v1.Get("/saved_searches/{savedSearchID}", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetSavedSearch))
+
+	v1.Patch("/saved_searches/{savedSearchID}", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleUpdateSavedSearch))
+
+	v1.Delete("/saved_searches/{savedSearchID}", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleDeleteSavedSearch))
+
+	v1.Get("/saved_searches/{savedSearchID}/posts", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetSavedSearchPosts))
+
+	v1.Get("/notifications", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetNotifications))
+
+	v1.Post("/notifications/{notificationID}/read", cfg.middlewareScope(auth.ScopePostsWrite, cfg.handleMarkNotificationRead))
+
+	v1.Post("/feed_follows", cfg.middlewareScope(auth.ScopeFollowsWrite, cfg.handleCreateFeedFollow))
+
+	v1.Get("/feed_follows", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetFeedFollows))
+
+	v1.Delete("/feed_follows/{feedFollowID}", cfg.middlewareScope(auth.ScopeFollowsWrite, cfg.handleDeleteFeedFollow))
+
+	v1.Patch("/feed_follows/{feedFollowID}", cfg.middlewareScope(auth.ScopeFollowsWrite, cfg.handleUpdateFeedFollow))
+
+	v1.Put("/feed_follows/{feedFollowID}/folder", cfg.middlewareScope(auth.ScopeFollowsWrite, cfg.handleSetFeedFollowFolder))
+
+	v1.Post("/folders", cfg.middlewareScope(auth.ScopeFollowsWrite, cfg.handleCreateFolder))
+
+	v1.Get("/folders", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetFolders))
+
+	v1.Patch("/folders/{folderID}", cfg.middlewareScope(auth.ScopeFollowsWrite, cfg.handleRenameFolder))
+
+	v1.Delete("/folders/{folderID}", cfg.middlewareScope(auth.ScopeFollowsWrite, cfg.handleDeleteFolder))
+
+	v1.Post("/rules", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleCreateRule))
+
+	v1.Get("/rules", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetRules))
+
+	v1.Post("/rules/dry_run", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleDryRunRule))
+
+	v1.Get("/rules/{ruleID}", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetRule))
+
+	v1.Put("/rules/{ruleID}", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleUpdateRule))
+
+	v1.Delete("/rules/{ruleID}", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleDeleteRule))
+
+	v1.Post("/rules/{ruleID}/apply", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleApplyRule))
+
+	v1.Post("/output_feeds", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleCreateOutputFeed))
+
+	v1.Get("/output_feeds", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetOutputFeeds))
+
+	v1.Delete("/output_feeds/{outputFeedID}", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleDeleteOutputFeed))
+
+	v1.Post("/webhooks", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleCreateWebhook))
+
+	v1.Get("/webhooks", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetWebhooks))
+
+	v1.Get("/webhooks/{webhookID}", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetWebhook))
+
+	v1.Patch("/webhooks/{webhookID}", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleUpdateWebhook))
+
+	v1.Delete("/webhooks/{webhookID}", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleDeleteWebhook))
+
+	v1.Get("/webhooks/{webhookID}/deliveries", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetWebhookDeliveries))
 
-	v1.Get("/feed_follows", cfg.middlewareAuth(cfg.handleGetFeedFollows))
+	v1.Get("/webhooks/{webhookID}/deliveries/{deliveryID}", cfg.middlewareScope(auth.ScopePostsRead, cfg.handleGetWebhookDelivery))
 
-	v1.Delete("/feed_follows/{feedFollowID}", cfg.middlewareAuth(cfg.handleDeleteFeedFollow))
+	v1.Post("/webhooks/{webhookID}/deliveries/{deliveryID}/retry", cfg.middlewareScope(auth.ScopeSettingsWrite, cfg.handleRetryWebhookDelivery))
     })
 
+    // Output feeds are public; the token in the path is the credential.
+    r.Get("/u/{token}/feed.atom", cfg.handleServeOutputFeed(outputFormatAtom))
+    r.Get("/u/{token}/feed.rss", cfg.handleServeOutputFeed(outputFormatRSS))
+    r.Get("/u/{token}/feed.json", cfg.handleServeOutputFeed(outputFormatJSON))
+
+    // WebSub hub callbacks: intent verification and content distribution.
+    r.Get("/websub/{subscriptionID}", cfg.handleWebSubVerify)
+    r.Post("/websub/{subscriptionID}", cfg.handleWebSubContent)
+
+    // METRICS_TOKEN, if set, must be sent as a bearer token to scrape
+    // /metrics; without it the endpoint is open.
+    r.Handle("/metrics", middlewareMetricsToken(os.Getenv("METRICS_TOKEN"), metrics.Handler()))
+
     srv := &http.Server{
         Addr:    ":" + port,
         Handler: r,
     }
 
-    log.Fatal(srv.ListenAndServe())
+    fatal("server stopped", logging.Err(srv.ListenAndServe()))
+}
+
+// middlewareMetricsToken requires "Authorization: Bearer <token>" when
+// token is set.
+func middlewareMetricsToken(token string, next http.Handler) http.Handler {
+    if token == "" {
+        return next
+    }
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+        if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+            httputil.RespondWithError(w, http.StatusUnauthorized, "invalid metrics token")
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// fatal logs an error and exits.
+func fatal(msg string, args ...any) {
+    slog.Error(msg, args...)
+    os.Exit(1)
 }
 
 type authedHandler func(http.ResponseWriter, *http.Request, database.User)
 
+// credentials is who a request's API key or session cookie turned out to
+// belong to.
+type credentials struct {
+    user database.User
+
+    // apiKey is set for requests authenticated by API key, session for
+    // ones authenticated by session cookie.
+    apiKey  *database.ApiKey
+    session *database.Session
+
+    // tokenHash is the hash of the session token the request sent, which
+    // may be the one the session was since rotated from.
+    tokenHash string
+
+    // failure says why the credentials the request sent were rejected;
+    // badSession is set if they were a session cookie.
+    failure    string
+    badSession bool
+}
+
+type credentialsKey struct{}
+
+func withCredentials(ctx context.Context, c credentials) context.Context {
+    return context.WithValue(ctx, credentialsKey{}, c)
+}
+
+func credentialsFromContext(ctx context.Context) (credentials, bool) {
+    c, ok := ctx.Value(credentialsKey{}).(credentials)
+    return c, ok
+}
+
+// middlewareCredentials checks the API key or session cookie a request
+// carries, if any, before the rate limiter sees it, so limits are keyed by
+// who the request is really from. middlewareAuth uses the result rather
+// than checking again.
+func (cfg *apiConfig) middlewareCredentials(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if c, ok := cfg.checkCredentials(r); ok {
+            r = r.WithContext(withCredentials(r.Context(), c))
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// checkCredentials looks up the "Authorization: ApiKey" header or, without
+// one, the session cookie set by /v1/login. It returns false if the
+// request sent neither.
+func (cfg *apiConfig) checkCredentials(r *http.Request) (credentials, bool) {
+    authHeader := r.Header.Get("Authorization")
+    if authHeader == "" {
+        cookie, err := r.Cookie(auth.SessionCookie)
+        if err != nil || cookie.Value == "" {
+            return credentials{}, false
+        }
+        return cfg.checkSession(r.Context(), cookie.Value), true
+    }
+
+    apiKey, ok := strings.CutPrefix(authHeader, "ApiKey ")
+    if !ok {
+        return credentials{failure: "missing or invalid Authorization header"}, true
+    }
+    return cfg.checkAPIKey(r.Context(), apiKey), true
+}
+
+func (cfg *apiConfig) checkAPIKey(ctx context.Context, apiKey string) credentials {
+    if apiKey == "" {
+        return credentials{failure: "invalid api key"}
+    }
+    key, user, ok := cfg.userForAPIKey(ctx, apiKey)
+    if !ok {
+        return credentials{failure: "invalid api key or user missing"}
+    }
+    return credentials{user: user, apiKey: &key}
+}
+
+// middlewareAuth authenticates a request by its "Authorization: ApiKey"
+// header or, without one, by the session cookie set by /v1/login.
 func (cfg *apiConfig) middlewareAuth(handler authedHandler) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        authHeader := r.Header.Get("Authorization")
-
-        const prefix = "ApiKey "
-        if !strings.HasPrefix(authHeader, prefix) {
+        c, ok := credentialsFromContext(r.Context())
+        if !ok {
+            c, ok = cfg.checkCredentials(r)
+        }
+        if !ok {
             httputil.RespondWithError(w, http.StatusUnauthorized, "missing or invalid Authorization header")
             return
         }
 
-        apiKey := strings.TrimPrefix(authHeader, prefix)
-        if apiKey == "" {
-            httputil.RespondWithError(w, http.StatusUnauthorized, "invalid api key")
+        if c.failure != "" {
+            if c.badSession {
+                auth.ClearSessionCookies(w, requestIsHTTPS(r))
+            }
+            httputil.RespondWithError(w, http.StatusUnauthorized, c.failure)
             return
         }
 
-        user, err := cfg.DB.GetUserByAPIKey(r.Context(), apiKey)
-        if err != nil {
-            httputil.RespondWithError(w, http.StatusUnauthorized, "invalid api key or user missing")
+        if c.session != nil {
+            cfg.authenticateSession(w, r, c, handler)
+            return
+        }
+
+        logging.SetUserID(r.Context(), c.user.ID)
+        ctx := auth.WithScopes(r.Context(), c.apiKey.Scopes)
+        ctx = auth.WithAPIKey(ctx, c.apiKey.ID)
+        handler(w, r.WithContext(ctx), c.user)
+    }
+}
+
+// apiKeyTouchInterval is how stale an API key's last_used_at may get
+// before a request using the key updates it.
+const apiKeyTouchInterval = 5 * time.Minute
+
+// userForAPIKey finds an API key and the user it belongs to. Candidates are
+// looked up by the key's prefix and the hashes compared in constant time.
+func (cfg *apiConfig) userForAPIKey(ctx context.Context, apiKey string) (database.ApiKey, database.User, bool) {
+    now := time.Now().UTC()
+
+    rows, err := cfg.DB.GetAPIKeysByPrefix(ctx, database.GetAPIKeysByPrefixParams{
+        Prefix: auth.APIKeyPrefix(apiKey),
+        Now:    now,
+    })
+    if err != nil {
+        slog.ErrorContext(ctx, "looking up api key failed", logging.Err(err))
+        return database.ApiKey{}, database.User{}, false
+    }
+
+    for _, row := range rows {
+        if !auth.VerifyAPIKey(apiKey, row.ApiKey.KeyHash) {
+            continue
+        }
+        // last_used_at is only kept to the nearest apiKeyTouchInterval, so
+        // busy keys don't cost a write per request.
+        staleBefore := now.Add(-apiKeyTouchInterval)
+        if !row.ApiKey.LastUsedAt.Valid || row.ApiKey.LastUsedAt.Time.Before(staleBefore) {
+            if err := cfg.DB.TouchAPIKey(ctx, database.TouchAPIKeyParams{
+                Now:         now,
+                ID:          row.ApiKey.ID,
+                StaleBefore: staleBefore,
+            }); err != nil {
+                slog.ErrorContext(ctx, "touching api key failed", "api_key_id", row.ApiKey.ID, logging.Err(err))
+            }
+        }
+        return row.ApiKey, row.User, true
+    }
+    return database.ApiKey{}, database.User{}, false
+}
+
+// middlewareScope is middlewareAuth for routes that need a particular
+// scope. Keys without it get a 403 naming the scope.
+func (cfg *apiConfig) middlewareScope(scope string, handler authedHandler) http.HandlerFunc {
+    return cfg.middlewareAuth(requireScope(scope, handler))
+}
+
+func requireScope(scope string, handler authedHandler) authedHandler {
+    return func(w http.ResponseWriter, r *http.Request, user database.User) {
+        if !auth.HasScope(auth.ScopesFromContext(r.Context()), scope) {
+            httputil.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("api key is missing scope %s", scope))
             return
         }
+        handler(w, r, user)
+    }
+}
 
+// middlewareAdmin is middlewareScope for admin-only routes: the user must
+// be an admin as well as the key having the account scope.
+func (cfg *apiConfig) middlewareAdmin(handler authedHandler) http.HandlerFunc {
+    return cfg.middlewareScope(auth.ScopeAccount, func(w http.ResponseWriter, r *http.Request, user database.User) {
+        if !user.IsAdmin {
+            httputil.RespondWithError(w, http.StatusForbidden, "admin only")
+            return
+        }
         handler(w, r, user)
+    })
+}
+
+// pick chooses the limiter for a request by its route group.
+func (l rateLimits) pick(r *http.Request) *ratelimit.Limiter {
+    if r.Method == http.MethodPost && (r.URL.Path == "/v1/users" || r.URL.Path == "/v1/login") {
+        return l.Auth
+    }
+    if auth.SafeMethod(r.Method) {
+        return l.Read
+    }
+    return l.Write
+}
+
+// pickIP is the limiter applied before credentials are checked.
+func (l rateLimits) pickIP(r *http.Request) *ratelimit.Limiter {
+    return l.IP
+}
+
+func (cfg *apiConfig) ipRateLimitKey(r *http.Request) string {
+    return "ip:" + cfg.clientIP(r)
+}
+
+// rateLimitKey identifies the client a request is counted against: the API
+// key or session middlewareCredentials verified, otherwise its IP, so
+// made-up credentials can't buy a fresh bucket. Signing up and logging in
+// are always counted by IP, as are access_token requests, which are only
+// checked once routed.
+func (cfg *apiConfig) rateLimitKey(r *http.Request) string {
+    if r.URL.Path != "/v1/users" && r.URL.Path != "/v1/login" {
+        if c, ok := credentialsFromContext(r.Context()); ok && c.failure == "" {
+            if c.apiKey != nil {
+                return "key:" + c.apiKey.ID.String()
+            }
+            return "session:" + c.session.ID.String()
+        }
+    }
+    return "ip:" + cfg.clientIP(r)
+}
+
+// clientIP returns the address the request came from, taken from
+// X-Forwarded-For when the server is behind a trusted proxy. Only the
+// right-most entry, the one our proxy appended, is used; anything before
+// it is whatever the client sent.
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+    if cfg.TrustProxyHeaders {
+        if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+            last := fwd[len(fwd)-1]
+            if i := strings.LastIndex(last, ","); i >= 0 {
+                last = last[i+1:]
+            }
+            if ip := strings.TrimSpace(last); ip != "" {
+                return ip
+            }
+        }
+    }
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
+
+// checkFollowQuota responds with an error and returns false if the user
+// already follows MaxFollowsPerUser feeds.
+func (cfg *apiConfig) checkFollowQuota(w http.ResponseWriter, r *http.Request, user database.User) bool {
+    if user.IsAdmin || cfg.MaxFollowsPerUser == 0 {
+        return true
+    }
+
+    n, err := cfg.DB.CountFeedFollowsForUser(r.Context(), user.ID)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not check feed follows")
+        return false
+    }
+    if n >= cfg.MaxFollowsPerUser {
+        httputil.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("you can follow at most %d feeds", cfg.MaxFollowsPerUser))
+        return false
+    }
+    return true
+}
+
+// envInt reads a non-negative integer setting, exiting if it is invalid.
+func envInt(name string, def int64) int64 {
+    s := os.Getenv(name)
+    if s == "" {
+        return def
+    }
+    n, err := strconv.ParseInt(s, 10, 64)
+    if err != nil || n < 0 {
+        fatal("invalid "+name+": must be a number, 0 for no limit", "value", s)
+    }
+    return n
+}
+
+// envLimit reads a rate limit setting, exiting if it is invalid.
+func envLimit(name, def string) ratelimit.Limit {
+    s := os.Getenv(name)
+    if s == "" {
+        s = def
+    }
+    limit, err := ratelimit.ParseLimit(s)
+    if err != nil {
+        fatal("invalid "+name, logging.Err(err))
+    }
+    return limit
+}
+
+// middlewareAuthOrQuery is middlewareScope for endpoints browsers open with
+// EventSource or WebSocket, which can't set headers: the API key may
+// instead be passed as the access_token query parameter.
+func (cfg *apiConfig) middlewareAuthOrQuery(scope string, handler authedHandler) http.HandlerFunc {
+    auth := cfg.middlewareScope(scope, handler)
+    return func(w http.ResponseWriter, r *http.Request) {
+        if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+            r = r.WithContext(withCredentials(r.Context(), cfg.checkAPIKey(r.Context(), token)))
+        }
+        auth(w, r)
     }
 }
 
@@ -167,6 +803,9 @@ func (cfg *apiConfig) handleGetPosts(w http.ResponseWriter, r *http.Request) {
         httputil.RespondWithError(w, http.StatusInternalServerError, "could not get posts")
         return
     }
+    for i := range posts {
+        sanitizePost(&posts[i])
+    }
     httputil.RespondWithJSON(w, http.StatusOK, posts)
 }
 
@@ -184,12 +823,30 @@ func (cfg *apiConfig) handleGetPostByID(w http.ResponseWriter, r *http.Request)
         return
     }
 
+    sanitizePost(&post)
     httputil.RespondWithJSON(w, http.StatusOK, post)
 }
 
+// sanitizePost re-sanitizes a post's markup on the way out. New posts are
+// already clean, but rows stored before sanitization was added are not.
+func sanitizePost(p *database.Post) {
+    base, _ := url.Parse(p.Url)
+    p.Description = sanitize.HTML(p.Description, base)
+    p.Content = sanitize.HTML(p.Content, base)
+}
+
+var (
+    errRegistrationClosed = errors.New("registration is closed")
+    errInviteRequired     = errors.New("invite code required")
+    errInvalidInvite      = errors.New("invalid invite code")
+)
+
 func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
     type requestBody struct {
-        Name string `json:"name"`
+        Name       string `json:"name"`
+        Username   string `json:"username"`
+        Password   string `json:"password"`
+        InviteCode string `json:"invite_code"`
     }
 
     decoder := json.NewDecoder(r.Body)
@@ -204,25 +861,133 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    id := uuid.New()
-    apiKey := uuid.NewString()
+    // A username and password are optional; they let the user log in
+    // from a browser.
+    var username, passwordHash sql.NullString
+    if params.Username != "" || params.Password != "" {
+        if strings.TrimSpace(params.Username) == "" {
+            httputil.RespondWithError(w, http.StatusBadRequest, "username is required with a password")
+            return
+        }
+        hash, err := auth.HashPassword(params.Password)
+        if err != nil {
+            httputil.RespondWithError(w, http.StatusBadRequest, err.Error())
+            return
+        }
+        username = sql.NullString{String: strings.TrimSpace(params.Username), Valid: true}
+        passwordHash = sql.NullString{String: hash, Valid: true}
+    }
+
+    apiKey, prefix, hash, err := auth.NewAPIKey()
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create api key")
+        return
+    }
+
     now := time.Now().UTC()
 
-    user, err := cfg.DB.CreateUser(r.Context(), database.CreateUserParams{
-        ID:        id,
-        CreatedAt: now,
-        UpdatedAt: now,
-        Name:      params.Name,
-        ApiKey:    apiKey,
+    // The user gets a "default" key, shown only in this response.
+    var user database.User
+    err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+        // The first user can always register, and runs the instance. The
+        // lock stops two sign-ups on an empty instance both being first.
+        if err := q.LockUserAccounts(r.Context()); err != nil {
+            return fmt.Errorf("lock users: %w", err)
+        }
+        count, err := q.CountUsers(r.Context())
+        if err != nil {
+            return fmt.Errorf("count users: %w", err)
+        }
+        first := count == 0
+
+        if !first && cfg.RegistrationMode == registrationClosed {
+            return errRegistrationClosed
+        }
+        if !first && cfg.RegistrationMode == registrationInvite {
+            if params.InviteCode == "" {
+                return errInviteRequired
+            }
+            _, err = q.RedeemInvite(r.Context(), database.RedeemInviteParams{
+                Code: params.InviteCode,
+                Now:  now,
+            })
+            if errors.Is(err, sql.ErrNoRows) {
+                return errInvalidInvite
+            }
+            if err != nil {
+                return fmt.Errorf("redeem invite: %w", err)
+            }
+        }
+
+        user, err = q.CreateUser(r.Context(), database.CreateUserParams{
+            ID:           uuid.New(),
+            CreatedAt:    now,
+            UpdatedAt:    now,
+            Name:         params.Name,
+            Username:     username,
+            PasswordHash: passwordHash,
+            IsAdmin:      first,
+        })
+        if err != nil {
+            return fmt.Errorf("create user: %w", err)
+        }
+
+        _, err = q.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+            ID:        uuid.New(),
+            CreatedAt: now,
+            UserID:    user.ID,
+            Name:      "default",
+            Prefix:    prefix,
+            KeyHash:   hash,
+            Scopes:    []string{auth.ScopeAccount},
+        })
+        if err != nil {
+            return fmt.Errorf("create api key: %w", err)
+        }
+        return nil
     })
     if err != nil {
+        if errors.Is(err, errRegistrationClosed) {
+            httputil.RespondWithError(w, http.StatusForbidden, "registration is closed")
+            return
+        }
+        if errors.Is(err, errInviteRequired) {
+            httputil.RespondWithError(w, http.StatusForbidden, "an invite_code is required to register")
+            return
+        }
+        if errors.Is(err, errInvalidInvite) {
+            httputil.RespondWithError(w, http.StatusForbidden, "invite_code is invalid, expired or used up")
+            return
+        }
+        if isUniqueViolation(err) {
+            httputil.RespondWithError(w, http.StatusConflict, "username is taken")
+            return
+        }
+        slog.ErrorContext(r.Context(), "creating user failed", logging.Err(err))
         httputil.RespondWithError(w, http.StatusInternalServerError, "could not create user")
         return
     }
 
-    httputil.RespondWithJSON(w, http.StatusCreated, user)
+    // Same fields as before users had credentials of their own.
+    type response struct {
+        ID        uuid.UUID
+        CreatedAt time.Time
+        UpdatedAt time.Time
+        Name      string
+        ApiKey    string
+    }
+
+    httputil.RespondWithJSON(w, http.StatusCreated, response{
+        ID:        user.ID,
+        CreatedAt: user.CreatedAt,
+        UpdatedAt: user.UpdatedAt,
+        Name:      user.Name,
+        ApiKey:    apiKey,
+    })
 }
 
+var errFeedQuota = errors.New("feed quota reached")
+
 func (cfg *apiConfig) handleCreateFeed(w http.ResponseWriter, r *http.Request, user database.User) {
     type requestBody struct {
         Name string `json:"name"`
@@ -236,54 +1001,160 @@ func (cfg *apiConfig) handleCreateFeed(w http.ResponseWriter, r *http.Request, u
         return
     }
 
-    if params.Name == "" || params.URL == "" {
-        httputil.RespondWithError(w, http.StatusBadRequest, "name and url are required")
+    // name may be left blank; the worker fills it in from the feed's title.
+    if params.URL == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "url is required")
         return
     }
 
-    now := time.Now().UTC()
+    if !cfg.checkFollowQuota(w, r, user) {
+        return
+    }
 
-    // 1. Create the feed
-    feedID := uuid.New()
-    feed, err := cfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
-        ID:        feedID,
-        CreatedAt: now,
-        UpdatedAt: now,
-        Name:      params.Name,
-        Url:       params.URL,
-        UserID:    user.ID,
-    })
+    if err := cfg.Guard.ValidateURL(r.Context(), params.URL); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("url not allowed: %v", err))
+        return
+    }
+
+    // Users often paste a site's homepage rather than its feed, so resolve
+    // the URL to an actual feed before storing it.
+    discovery, err := rss.Discover(r.Context(), params.URL)
     if err != nil {
-        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create feed")
+        httputil.RespondWithError(w, http.StatusUnprocessableEntity, fmt.Sprintf("could not find a feed at url: %v", err))
+        return
+    }
+    if discovery.FeedURL == "" {
+        // Several feeds are advertised; let the client pick one and resubmit.
+        httputil.RespondWithJSON(w, http.StatusMultipleChoices, map[string]interface{}{
+            "error":      "url advertises multiple feeds, choose one",
+            "candidates": discovery.Candidates,
+        })
+        return
+    }
+    if err := cfg.Guard.ValidateURL(r.Context(), discovery.FeedURL); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("feed url not allowed: %v", err))
         return
     }
 
-    // 2. Auto-create follow
-    followID := uuid.New()
-    follow, err := cfg.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
-        ID:        followID,
-        CreatedAt: now,
-        UpdatedAt: now,
-        FeedID:    feed.ID,
-        UserID:    user.ID,
+    now := time.Now().UTC()
+
+    // Feeds are shared: if someone already added this one, follow it
+    // rather than adding it again. Either way the follow is created in the
+    // same transaction, so a failed follow doesn't leave a feed behind.
+    var feed database.Feed
+    var follow database.FeedFollow
+    err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+        var err error
+
+        // 1. Find or create the feed. A name given for a feed someone else
+        // added becomes this user's title for it instead.
+        var title sql.NullString
+        feed, err = q.GetFeedByURL(r.Context(), discovery.FeedURL)
+        if err == nil && params.Name != "" && params.Name != feed.Name {
+            title = sql.NullString{String: params.Name, Valid: true}
+        }
+        if errors.Is(err, sql.ErrNoRows) {
+            if !user.IsAdmin && cfg.MaxFeedsPerUser > 0 {
+                n, err := q.CountFeedsAddedByUser(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+                if err != nil {
+                    return fmt.Errorf("count feeds: %w", err)
+                }
+                if n >= cfg.MaxFeedsPerUser {
+                    return errFeedQuota
+                }
+            }
+            feed, err = q.CreateFeed(r.Context(), database.CreateFeedParams{
+                ID:        uuid.New(),
+                CreatedAt: now,
+                UpdatedAt: now,
+                Name:      params.Name,
+                Url:       discovery.FeedURL,
+                AddedBy:   uuid.NullUUID{UUID: user.ID, Valid: true},
+            })
+        }
+        if err != nil {
+            return fmt.Errorf("create feed: %w", err)
+        }
+
+        // 2. Auto-create follow
+        follow, err = q.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+            ID:        uuid.New(),
+            CreatedAt: now,
+            UpdatedAt: now,
+            FeedID:    feed.ID,
+            UserID:    user.ID,
+            Title:     title,
+        })
+        if err != nil {
+            return fmt.Errorf("create feed follow: %w", err)
+        }
+
+        // The follow may have just reactivated the feed.
+        feed, err = q.GetFeed(r.Context(), feed.ID)
+        if err != nil {
+            return fmt.Errorf("get feed: %w", err)
+        }
+
+        return nil
     })
     if err != nil {
-        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create feed follow")
+        if errors.Is(err, errFeedQuota) {
+            httputil.RespondWithError(w, http.StatusForbidden, fmt.Sprintf("you can add at most %d feeds; follow an existing one instead", cfg.MaxFeedsPerUser))
+            return
+        }
+        if isUniqueViolation(err) {
+            httputil.RespondWithError(w, http.StatusConflict, "already following this feed")
+            return
+        }
+        slog.ErrorContext(r.Context(), "creating feed failed", logging.Err(err))
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not create feed")
         return
     }
 
     // 3. Return both
     type response struct {
         Feed       Feed       `json:"feed"`
-        FeedFollow database.FeedFollow `json:"feed_follow"`
+        FeedFollow FeedFollow `json:"feed_follow"`
     }
 
+    out := databaseFeedFollowToFeedFollow(follow, feed)
     httputil.RespondWithJSON(w, http.StatusCreated, response{
-        Feed:       databaseFeedToFeed(feed),
-        FeedFollow: follow,
+        Feed:       out.Feed,
+        FeedFollow: out,
     })
 }
 
+func (cfg *apiConfig) handlePreviewFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        URL string `json:"url"`
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    if params.URL == "" {
+        httputil.RespondWithError(w, http.StatusBadRequest, "url is required")
+        return
+    }
+
+    if err := cfg.Guard.ValidateURL(r.Context(), params.URL); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("url not allowed: %v", err))
+        return
+    }
+
+    preview, err := worker.PreviewFeed(r.Context(), params.URL)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusUnprocessableEntity, fmt.Sprintf("could not read feed: %v", err))
+        return
+    }
+
+    httputil.RespondWithJSON(w, http.StatusOK, preview)
+}
+
 func (cfg *apiConfig) handleGetFeeds(w http.ResponseWriter, r *http.Request) {
     feeds, err := cfg.DB.GetFeeds(r.Context())
     if err != nil {
@@ -294,6 +1165,38 @@ func (cfg *apiConfig) handleGetFeeds(w http.ResponseWriter, r *http.Request) {
     httputil.RespondWithJSON(w, http.StatusOK, databaseFeedsToFeeds(feeds))
 }
 
+func (cfg *apiConfig) handleGetFeedIcon(w http.ResponseWriter, r *http.Request) {
+    idStr := chi.URLParam(r, "feedID")
+    id, err := uuid.Parse(idStr)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid feedID")
+        return
+    }
+
+    icon, err := cfg.DB.GetFeedIcon(r.Context(), id)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusNotFound, "icon not found")
+        return
+    }
+
+    etag := `"` + icon.Hash + `"`
+    w.Header().Set("ETag", etag)
+    w.Header().Set("Cache-Control", "public, max-age=86400")
+    w.Header().Set("Last-Modified", icon.UpdatedAt.UTC().Format(http.TimeFormat))
+    // Icons may be SVG; make sure they can't run script if opened directly.
+    w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+    w.Header().Set("X-Content-Type-Options", "nosniff")
+
+    if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+        w.WriteHeader(http.StatusNotModified)
+        return
+    }
+
+    w.Header().Set("Content-Type", icon.ContentType)
+    w.WriteHeader(http.StatusOK)
+    w.Write(icon.Data)
+}
+
 func (cfg *apiConfig) handleCreateFeedFollow(w http.ResponseWriter, r *http.Request, user database.User) {
     type requestBody struct {
         FeedID string `json:"feed_id"`
@@ -317,6 +1220,10 @@ func (cfg *apiConfig) handleCreateFeedFollow(w http.ResponseWriter, r *http.Requ
         return
     }
 
+    if !cfg.checkFollowQuota(w, r, user) {
+        return
+    }
+
     id := uuid.New()
     now := time.Now().UTC()
 
@@ -328,23 +1235,123 @@ func (cfg *apiConfig) handleCreateFeedFollow(w http.ResponseWriter, r *http.Requ
         UserID:    user.ID,
     })
     if err != nil {
+        if isForeignKeyViolation(err) {
+            httputil.RespondWithError(w, http.StatusNotFound, "feed not found")
+            return
+        }
+        if isUniqueViolation(err) {
+            httputil.RespondWithError(w, http.StatusConflict, "already following this feed")
+            return
+        }
         httputil.RespondWithError(w, http.StatusInternalServerError, "could not create feed follow")
         return
     }
 
-    httputil.RespondWithJSON(w, http.StatusCreated, follow)
+    cfg.respondWithFeedFollow(w, r, http.StatusCreated, follow)
 }
 
 func (cfg *apiConfig) handleGetFeedFollows(w http.ResponseWriter, r *http.Request, user database.User) {
-    follows, err := cfg.DB.GetFeedFollowsForUser(r.Context(), user.ID)
+    rows, err := cfg.DB.GetFeedFollowsForUser(r.Context(), user.ID)
     if err != nil {
         httputil.RespondWithError(w, http.StatusInternalServerError, "could not get feed follows")
         return
     }
 
+    follows := make([]FeedFollow, 0, len(rows))
+    for _, row := range rows {
+        follows = append(follows, databaseFeedFollowToFeedFollow(row.FeedFollow, row.Feed))
+    }
+
     httputil.RespondWithJSON(w, http.StatusOK, follows)
 }
 
+// handleUpdateFeedFollow changes the user's settings for a followed feed.
+// Fields left out are unchanged; an empty title or color clears it.
+func (cfg *apiConfig) handleUpdateFeedFollow(w http.ResponseWriter, r *http.Request, user database.User) {
+    type requestBody struct {
+        Title            *string `json:"title"`
+        Color            *string `json:"color"`
+        Priority         *int32  `json:"priority"`
+        HideFromTimeline *bool   `json:"hide_from_timeline"`
+        Notify           *bool   `json:"notify"`
+    }
+
+    followID, err := uuid.Parse(chi.URLParam(r, "feedFollowID"))
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid feedFollowID")
+        return
+    }
+
+    follow, err := cfg.DB.GetFeedFollow(r.Context(), database.GetFeedFollowParams{
+        ID:     followID,
+        UserID: user.ID,
+    })
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusNotFound, "feed follow not found")
+        return
+    }
+
+    decoder := json.NewDecoder(r.Body)
+    params := requestBody{}
+    if err := decoder.Decode(&params); err != nil {
+        httputil.RespondWithError(w, http.StatusBadRequest, "invalid JSON")
+        return
+    }
+
+    update := database.UpdateFeedFollowSettingsParams{
+        ID:               follow.ID,
+        UserID:           user.ID,
+        Title:            follow.Title,
+        Color:            follow.Color,
+        Priority:         follow.Priority,
+        HideFromTimeline: follow.HideFromTimeline,
+        Notify:           follow.Notify,
+        UpdatedAt:        time.Now().UTC(),
+    }
+    if params.Title != nil {
+        title := strings.TrimSpace(*params.Title)
+        update.Title = sql.NullString{String: title, Valid: title != ""}
+    }
+    if params.Color != nil {
+        color := strings.ToLower(strings.TrimSpace(*params.Color))
+        if color != "" && !feedColorRe.MatchString(color) {
+            httputil.RespondWithError(w, http.StatusBadRequest, "color must be a hex color like #1e90ff")
+            return
+        }
+        update.Color = sql.NullString{String: color, Valid: color != ""}
+    }
+    if params.Priority != nil {
+        update.Priority = *params.Priority
+    }
+    if params.HideFromTimeline != nil {
+        update.HideFromTimeline = *params.HideFromTimeline
+    }
+    if params.Notify != nil {
+        update.Notify = *params.Notify
+    }
+
+    updated, err := cfg.DB.UpdateFeedFollowSettings(r.Context(), update)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not update feed follow")
+        return
+    }
+
+    cfg.respondWithFeedFollow(w, r, http.StatusOK, updated)
+}
+
+var feedColorRe = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// respondWithFeedFollow responds with a follow and the feed it is for.
+func (cfg *apiConfig) respondWithFeedFollow(w http.ResponseWriter, r *http.Request, status int, follow database.FeedFollow) {
+    feed, err := cfg.DB.GetFeed(r.Context(), follow.FeedID)
+    if err != nil {
+        httputil.RespondWithError(w, http.StatusInternalServerError, "could not get feed")
+        return
+    }
+
+    httputil.RespondWithJSON(w, status, databaseFeedFollowToFeedFollow(follow, feed))
+}
+
 func (cfg *apiConfig) handleDeleteFeedFollow(w http.ResponseWriter, r *http.Request, user database.User) {
     feedFollowIDStr := chi.URLParam(r, "feedFollowID")
     if feedFollowIDStr == "" {
@@ -378,15 +1385,67 @@ func databaseFeedToFeed(f database.Feed) Feed {
         lastFetched = &t
     }
 
+    var lastBuild *time.Time
+    if f.LastBuildDate.Valid {
+        t := f.LastBuildDate.Time
+        lastBuild = &t
+    }
+
+    name := f.Name
+    if name == "" && f.Title.Valid {
+        name = f.Title.String
+    }
+
     return Feed{
         ID:            f.ID,
         CreatedAt:     f.CreatedAt,
         UpdatedAt:     f.UpdatedAt,
-        Name:          f.Name,
+        Name:          name,
         URL:           f.Url,
-        UserID:        f.UserID,
+        AddedBy:       nullUUIDPtr(f.AddedBy),
         LastFetchedAt: lastFetched,
+        FollowerCount: f.FollowerCount,
+        DeactivatedAt: nullTimePtr(f.DeactivatedAt),
+        Title:         nullStringPtr(f.Title),
+        SiteURL:       nullStringPtr(f.SiteUrl),
+        Description:   nullStringPtr(f.Description),
+        ImageURL:      nullStringPtr(f.ImageUrl),
+        Language:      nullStringPtr(f.Language),
+        Generator:     nullStringPtr(f.Generator),
+        LastBuildDate: lastBuild,
+        FetchError:    nullStringPtr(f.FetchError),
+        FetchErrorAt:  nullTimePtr(f.FetchErrorAt),
+        FetchFailures: f.FetchFailures,
+    }
+}
+
+func databaseFeedFollowToFeedFollow(ff database.FeedFollow, f database.Feed) FeedFollow {
+    feed := databaseFeedToFeed(f)
+    if ff.Title.Valid {
+        feed.Name = ff.Title.String
+    }
+
+    return FeedFollow{
+        ID:               ff.ID,
+        CreatedAt:        ff.CreatedAt,
+        UpdatedAt:        ff.UpdatedAt,
+        FeedID:           ff.FeedID,
+        UserID:           ff.UserID,
+        FolderID:         nullUUIDPtr(ff.FolderID),
+        Title:            nullStringPtr(ff.Title),
+        Color:            nullStringPtr(ff.Color),
+        Priority:         ff.Priority,
+        HideFromTimeline: ff.HideFromTimeline,
+        Notify:           ff.Notify,
+        Feed:             feed,
+    }
+}
+
+func nullStringPtr(s sql.NullString) *string {
+    if !s.Valid {
+        return nil
     }
+    return &s.String
 }
 
 func databaseFeedsToFeeds(feeds []database.Feed) []Feed {
@@ -0,0 +1,43 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, updated_at, user_id, name, prefix, key_hash, scopes, expires_at)
+VALUES (@id, @created_at, @created_at, @user_id, @name, @prefix, @key_hash, @scopes::text[], sqlc.narg('expires_at'))
+RETURNING *;
+
+-- name: GetAPIKeysByPrefix :many
+-- Unexpired keys sharing a prefix, with their users, leaving out disabled
+-- users. The caller compares hashes to find the right one.
+SELECT sqlc.embed(k), sqlc.embed(u)
+FROM api_keys k
+JOIN users u ON u.id = k.user_id
+WHERE k.prefix = @prefix
+  AND u.disabled_at IS NULL
+  AND (k.expires_at IS NULL OR k.expires_at > @now::timestamp);
+
+-- name: GetAPIKeysForUser :many
+SELECT *
+FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: TouchAPIKey :exec
+-- Only writes if the last recorded use is before @stale_before, so busy
+-- keys do not write on every request.
+UPDATE api_keys
+SET last_used_at = @now::timestamp
+WHERE id = @id
+  AND (last_used_at IS NULL OR last_used_at < @stale_before::timestamp);
+
+-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys
+WHERE id = $1 AND user_id = $2;
+
+-- name: RotateAPIKey :one
+-- Replaces a key with a new secret, keeping its id, name, scopes and
+-- expiry. The old secret stops working immediately.
+UPDATE api_keys
+SET prefix = @prefix,
+    key_hash = @key_hash,
+    last_used_at = NULL,
+    updated_at = @updated_at
+WHERE id = @id AND user_id = @user_id
+RETURNING *;
@@ -1,14 +1,48 @@
 -- name: CreateFeedFollow :one
-INSERT INTO feed_follows (id, created_at, updated_at, feed_id, user_id)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO feed_follows (id, created_at, updated_at, feed_id, user_id, title)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetFeedFollowsForUser :many
-SELECT *
-FROM feed_follows
-WHERE user_id = $1
-ORDER BY created_at DESC;
+-- The user's follows with their feeds, highest priority first.
+SELECT sqlc.embed(ff), sqlc.embed(f)
+FROM feed_follows ff
+JOIN feeds f ON f.id = ff.feed_id
+WHERE ff.user_id = $1
+ORDER BY ff.priority DESC, ff.created_at DESC;
 
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows
 WHERE id = $1 AND user_id = $2;
+
+-- name: SetFeedFollowFolder :one
+UPDATE feed_follows
+SET folder_id = $3, updated_at = $4
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: GetFeedFollow :one
+SELECT *
+FROM feed_follows
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetFeedFollowForFeed :one
+SELECT *
+FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: UpdateFeedFollowSettings :one
+UPDATE feed_follows
+SET title = sqlc.narg('title'),
+    color = sqlc.narg('color'),
+    priority = @priority,
+    hide_from_timeline = @hide_from_timeline,
+    notify = @notify,
+    updated_at = @updated_at
+WHERE id = @id AND user_id = @user_id
+RETURNING *;
+
+-- name: CountFeedFollowsForUser :one
+SELECT COUNT(*)
+FROM feed_follows
+WHERE user_id = $1;
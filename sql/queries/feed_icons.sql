@@ -0,0 +1,14 @@
+-- name: UpsertFeedIcon :exec
+INSERT INTO feed_icons (feed_id, created_at, updated_at, url, content_type, data, hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (feed_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    url = EXCLUDED.url,
+    content_type = EXCLUDED.content_type,
+    data = EXCLUDED.data,
+    hash = EXCLUDED.hash;
+
+-- name: GetFeedIcon :one
+SELECT *
+FROM feed_icons
+WHERE feed_id = $1;
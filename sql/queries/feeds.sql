@@ -1,5 +1,5 @@
 -- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, added_by)
 VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
@@ -9,8 +9,20 @@ FROM feeds
 ORDER BY created_at DESC;
 
 -- name: GetNextFeedsToFetch :many
+-- Feeds nobody follows are skipped. Feeds with an active WebSub lease get
+-- their content pushed, so they are only polled if nothing has arrived for
+-- six hours.
 SELECT *
 FROM feeds
+WHERE deactivated_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM websub_subscriptions s
+    WHERE s.feed_id = feeds.id
+      AND s.state = 'active'
+      AND s.lease_expires_at > NOW()
+      AND feeds.last_fetched_at > NOW() - INTERVAL '6 hours'
+)
 ORDER BY last_fetched_at IS NOT NULL, last_fetched_at, created_at
 LIMIT $1;
 
@@ -18,3 +30,91 @@ LIMIT $1;
 UPDATE feeds
 SET last_fetched_at = NOW(), updated_at = NOW()
 WHERE id = $1;
+
+-- name: UpdateFeedMetadata :exec
+UPDATE feeds
+SET title = $2,
+    site_url = $3,
+    description = $4,
+    image_url = $5,
+    language = $6,
+    generator = $7,
+    last_build_date = $8,
+    name = CASE WHEN name = '' THEN COALESCE($2, '') ELSE name END,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetFeedsNeedingIcon :many
+-- Feeds are only picked once fetched, since the first fetch fills in the
+-- image and homepage the icon is found from.
+SELECT *
+FROM feeds
+WHERE deactivated_at IS NULL
+  AND last_fetched_at IS NOT NULL
+  AND (icon_checked_at IS NULL OR icon_checked_at < $1)
+ORDER BY icon_checked_at IS NOT NULL, icon_checked_at, created_at
+LIMIT $2;
+
+-- name: MarkFeedIconChecked :exec
+UPDATE feeds
+SET icon_checked_at = NOW()
+WHERE id = $1;
+
+-- name: GetFeed :one
+SELECT *
+FROM feeds
+WHERE id = $1;
+
+-- name: RecordFeedFetchError :one
+-- A failed fetch still counts as a fetch for scheduling, so feeds that keep
+-- failing go to the back of the queue instead of heading every batch.
+UPDATE feeds
+SET fetch_error = $2,
+    fetch_error_at = NOW(),
+    fetch_failures = fetch_failures + 1,
+    last_fetched_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING fetch_failures;
+
+-- name: ClearFeedFetchError :execrows
+UPDATE feeds
+SET fetch_error = NULL,
+    fetch_error_at = NULL,
+    fetch_failures = 0
+WHERE id = $1 AND fetch_failures > 0;
+
+-- name: GetUnreadCountsForUser :many
+-- Unread, unhidden posts per followed feed. Feeds with nothing unread are
+-- left out.
+SELECT ff.feed_id, COUNT(*) AS unread
+FROM feed_follows ff
+JOIN posts p ON p.feed_id = ff.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1
+  AND ps.read_at IS NULL
+  AND ps.hidden_at IS NULL
+GROUP BY ff.feed_id;
+
+-- name: GetFeedByURL :one
+SELECT *
+FROM feeds
+WHERE url = $1;
+
+-- name: DeleteInactiveFeeds :execrows
+-- Deletes feeds nobody has followed since @deactivated_before, with their
+-- posts.
+DELETE FROM feeds
+WHERE deactivated_at < @deactivated_before::timestamp;
+
+-- name: CountFeedsAddedByUser :one
+SELECT COUNT(*)
+FROM feeds
+WHERE added_by = $1;
+
+-- name: GetFeedQueueLag :one
+-- Seconds since the least recently fetched active feed was last fetched, or
+-- added if it never has been. Zero when there are no active feeds.
+SELECT COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(COALESCE(last_fetched_at, created_at))), 0)::float8 AS lag_seconds
+FROM feeds
+WHERE deactivated_at IS NULL;
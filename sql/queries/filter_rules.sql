@@ -0,0 +1,72 @@
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules (
+    id,
+    created_at,
+    updated_at,
+    user_id,
+    name,
+    folder_id,
+    feed_follow_id,
+    field,
+    match_type,
+    pattern,
+    action,
+    tag,
+    enabled
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+RETURNING *;
+
+-- name: GetFilterRule :one
+SELECT *
+FROM filter_rules
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetFilterRulesForUser :many
+SELECT *
+FROM filter_rules
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: UpdateFilterRule :one
+UPDATE filter_rules
+SET name = $3,
+    folder_id = $4,
+    feed_follow_id = $5,
+    field = $6,
+    match_type = $7,
+    pattern = $8,
+    action = $9,
+    tag = $10,
+    enabled = $11,
+    updated_at = $12
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetActiveFilterRulesForFeed :many
+-- Enabled rules of every follower of the feed whose scope covers it: all
+-- feeds, the folder the follow is in, or the follow itself.
+SELECT r.*
+FROM filter_rules r
+JOIN feed_follows ff ON ff.user_id = r.user_id AND ff.feed_id = $1
+WHERE r.enabled
+  AND (
+      (r.folder_id IS NULL AND r.feed_follow_id IS NULL)
+      OR r.folder_id = ff.folder_id
+      OR r.feed_follow_id = ff.id
+  );
+
+-- name: GetRecentPostsInScope :many
+-- The user's most recent posts within a rule scope, for dry runs and
+-- retroactive application.
+SELECT p.*
+FROM posts p
+JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = @user_id
+WHERE (sqlc.narg('folder_id')::uuid IS NULL OR ff.folder_id = sqlc.narg('folder_id'))
+  AND (sqlc.narg('feed_follow_id')::uuid IS NULL OR ff.id = sqlc.narg('feed_follow_id'))
+ORDER BY p.published_at DESC
+LIMIT @row_limit;
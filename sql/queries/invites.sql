@@ -0,0 +1,23 @@
+-- name: CreateInvite :one
+INSERT INTO invites (id, created_at, created_by, code, note, max_uses, expires_at)
+VALUES (@id, @created_at, @created_by, @code, @note, @max_uses, sqlc.narg('expires_at'))
+RETURNING *;
+
+-- name: GetInvites :many
+SELECT *
+FROM invites
+ORDER BY created_at DESC;
+
+-- name: RedeemInvite :one
+-- Uses up one use of a valid code. No row means the code is unknown,
+-- used up or expired.
+UPDATE invites
+SET uses = uses + 1
+WHERE code = @code
+  AND uses < max_uses
+  AND (expires_at IS NULL OR expires_at > @now::timestamp)
+RETURNING *;
+
+-- name: DeleteInvite :execrows
+DELETE FROM invites
+WHERE id = $1;
@@ -0,0 +1,49 @@
+-- name: CreateSavedSearchNotifications :execrows
+-- Notifies every follower of the post's feed whose notifying saved searches
+-- match it, unless they turned notifications off for the feed or one of
+-- their rules hid the post.
+INSERT INTO notifications (id, created_at, user_id, post_id, saved_search_id)
+SELECT gen_random_uuid(), NOW(), s.user_id, p.id, s.id
+FROM posts p
+JOIN post_search idx ON idx.post_id = p.id
+JOIN feed_follows ff ON ff.feed_id = p.feed_id
+JOIN saved_searches s ON s.user_id = ff.user_id
+WHERE p.id = $1
+  AND ff.notify
+  AND s.notify
+  AND NOT EXISTS (
+    SELECT 1
+    FROM post_states ps
+    WHERE ps.post_id = p.id
+      AND ps.user_id = s.user_id
+      AND ps.hidden_at IS NOT NULL
+  )
+  AND idx.vector @@ (websearch_to_tsquery('simple', s.query)
+          || websearch_to_tsquery(search_config_for_language(s.language), s.query))
+ON CONFLICT DO NOTHING;
+
+-- name: GetNotificationsForUser :many
+SELECT n.id,
+       n.created_at,
+       n.post_id,
+       n.saved_search_id,
+       n.read_at,
+       p.title AS post_title,
+       p.url AS post_url,
+       p.feed_id,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       s.name AS saved_search_name
+FROM notifications n
+JOIN posts p ON p.id = n.post_id
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = n.user_id
+LEFT JOIN saved_searches s ON s.id = n.saved_search_id
+WHERE n.user_id = @user_id
+  AND (NOT @unread_only::boolean OR n.read_at IS NULL)
+ORDER BY n.created_at DESC
+LIMIT @row_limit OFFSET @row_offset;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
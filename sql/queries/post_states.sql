@@ -0,0 +1,45 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, read_at)
+VALUES (@user_id, @post_id, @now, @now, @now)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, EXCLUDED.read_at),
+    updated_at = EXCLUDED.updated_at;
+
+-- name: MarkPostUnread :exec
+UPDATE post_states
+SET read_at = NULL, updated_at = @now
+WHERE user_id = @user_id AND post_id = @post_id;
+
+-- name: StarPost :exec
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, starred_at)
+VALUES (@user_id, @post_id, @now, @now, @now)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred_at = COALESCE(post_states.starred_at, EXCLUDED.starred_at),
+    updated_at = EXCLUDED.updated_at;
+
+-- name: UnstarPost :exec
+UPDATE post_states
+SET starred_at = NULL, updated_at = @now
+WHERE user_id = @user_id AND post_id = @post_id;
+
+-- name: HidePost :exec
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, hidden_at)
+VALUES (@user_id, @post_id, @now, @now, @now)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET hidden_at = COALESCE(post_states.hidden_at, EXCLUDED.hidden_at),
+    updated_at = EXCLUDED.updated_at;
+
+-- name: UnhidePost :exec
+UPDATE post_states
+SET hidden_at = NULL, updated_at = @now
+WHERE user_id = @user_id AND post_id = @post_id;
+
+-- name: TagPost :exec
+INSERT INTO post_tags (user_id, post_id, tag, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
+-- name: GetPostState :one
+SELECT *
+FROM post_states
+WHERE user_id = $1 AND post_id = $2;
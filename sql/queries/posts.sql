@@ -7,9 +7,13 @@ INSERT INTO posts (
     url,
     description,
     published_at,
-    feed_id
+    feed_id,
+    content,
+    plain_text,
+    author,
+    categories
 )
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 RETURNING *;
 
 -- name: GetPosts :many
@@ -22,3 +26,81 @@ LIMIT 50;
 SELECT *
 FROM posts
 WHERE id = $1;
+
+-- name: GetTimelineForUser :many
+-- Posts from the user's followed feeds with their read, star and hidden
+-- state and tags, newest first. Hidden posts are left out unless asked for,
+-- and so are feeds hidden from the timeline unless asked for by feed_id.
+-- feed_title is the user's title for the feed if they set one.
+SELECT p.*,
+       ps.read_at,
+       ps.starred_at,
+       ps.hidden_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       ff.color AS feed_color,
+       COALESCE(
+           (SELECT array_agg(t.tag ORDER BY t.tag) FROM post_tags t WHERE t.user_id = @user_id AND t.post_id = p.id),
+           '{}'
+       )::text[] AS tags
+FROM posts p
+JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = @user_id
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = @user_id
+WHERE (@include_hidden::boolean OR ps.hidden_at IS NULL)
+  AND (NOT ff.hide_from_timeline OR sqlc.narg('feed_id')::uuid IS NOT NULL)
+  AND (NOT @unread_only::boolean OR ps.read_at IS NULL)
+  AND (NOT @starred_only::boolean OR ps.starred_at IS NOT NULL)
+  AND (sqlc.narg('folder_id')::uuid IS NULL OR ff.folder_id = sqlc.narg('folder_id'))
+  AND (sqlc.narg('feed_id')::uuid IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+  AND (sqlc.narg('tag')::text IS NULL OR EXISTS (
+      SELECT 1 FROM post_tags t
+      WHERE t.user_id = @user_id AND t.post_id = p.id AND t.tag = sqlc.narg('tag')
+  ))
+ORDER BY p.published_at DESC
+LIMIT @row_limit OFFSET @row_offset;
+
+-- name: GetTimelinePostForUser :one
+-- A single post as the user sees it, only if it is from a feed they follow
+-- and they haven't hidden it. Callers decide what to do about feeds hidden
+-- from the timeline.
+SELECT p.*,
+       ps.read_at,
+       ps.starred_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       ff.color AS feed_color,
+       ff.hide_from_timeline,
+       COALESCE(
+           (SELECT array_agg(t.tag ORDER BY t.tag) FROM post_tags t WHERE t.user_id = @user_id AND t.post_id = p.id),
+           '{}'
+       )::text[] AS tags
+FROM posts p
+JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = @user_id
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = @user_id
+WHERE p.id = @post_id
+  AND ps.hidden_at IS NULL;
+
+-- name: GetTimelineSinceForUser :many
+-- Posts from the user's followed feeds stored after the given post, oldest
+-- first, for resuming a stream. Feeds hidden from the timeline are left
+-- out.
+SELECT p.*,
+       ps.read_at,
+       ps.starred_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       ff.color AS feed_color,
+       ff.hide_from_timeline,
+       COALESCE(
+           (SELECT array_agg(t.tag ORDER BY t.tag) FROM post_tags t WHERE t.user_id = @user_id AND t.post_id = p.id),
+           '{}'
+       )::text[] AS tags
+FROM posts p
+JOIN posts after ON after.id = @after_post_id
+JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = @user_id
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = @user_id
+WHERE (p.created_at, p.id) > (after.created_at, after.id)
+  AND ps.hidden_at IS NULL
+  AND NOT ff.hide_from_timeline
+ORDER BY p.created_at, p.id
+LIMIT @row_limit;
@@ -0,0 +1,74 @@
+-- name: CreateSavedSearch :one
+INSERT INTO saved_searches (id, created_at, updated_at, user_id, name, query, language, notify)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetSavedSearch :one
+SELECT *
+FROM saved_searches
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetSavedSearchesForUser :many
+-- Each saved search comes with the number of matching posts from followed
+-- feeds that the user hasn't read.
+SELECT s.id,
+       s.created_at,
+       s.updated_at,
+       s.user_id,
+       s.name,
+       s.query,
+       s.language,
+       s.notify,
+       (
+           SELECT COUNT(*)
+           FROM posts p
+           JOIN post_search ps ON ps.post_id = p.id
+           WHERE ps.vector @@ (websearch_to_tsquery('simple', s.query)
+                   || websearch_to_tsquery(search_config_for_language(s.language), s.query))
+             AND p.feed_id IN (SELECT ff.feed_id FROM feed_follows ff WHERE ff.user_id = s.user_id)
+             AND NOT EXISTS (
+                 SELECT 1 FROM post_states st
+                 WHERE st.post_id = p.id AND st.user_id = s.user_id
+                   AND (st.read_at IS NOT NULL OR st.hidden_at IS NOT NULL)
+             )
+       ) AS unread_count
+FROM saved_searches s
+WHERE s.user_id = $1
+ORDER BY s.created_at DESC;
+
+-- name: UpdateSavedSearch :one
+UPDATE saved_searches
+SET name = $3, query = $4, language = $5, notify = $6, updated_at = $7
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteSavedSearch :execrows
+DELETE FROM saved_searches
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetSavedSearchPosts :many
+-- The saved search's timeline: matching posts from followed feeds, newest
+-- first, leaving out posts the user's rules have hidden.
+SELECT p.*,
+       ps.read_at,
+       ps.starred_at,
+       ps.hidden_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title,
+       ff.color AS feed_color,
+       COALESCE(
+           (SELECT array_agg(t.tag ORDER BY t.tag) FROM post_tags t WHERE t.user_id = s.user_id AND t.post_id = p.id),
+           '{}'
+       )::text[] AS tags
+FROM saved_searches s
+JOIN feed_follows ff ON ff.user_id = s.user_id
+JOIN posts p ON p.feed_id = ff.feed_id
+JOIN feeds f ON f.id = p.feed_id
+JOIN post_search idx ON idx.post_id = p.id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = s.user_id
+WHERE s.id = @saved_search_id
+  AND idx.vector @@ (websearch_to_tsquery('simple', s.query)
+          || websearch_to_tsquery(search_config_for_language(s.language), s.query))
+  AND ps.hidden_at IS NULL
+  AND (NOT @unread_only::boolean OR ps.read_at IS NULL)
+ORDER BY p.published_at DESC
+LIMIT @row_limit OFFSET @row_offset;
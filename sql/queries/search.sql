@@ -0,0 +1,38 @@
+-- name: SearchPosts :many
+-- Matches are ranked by relevance; snippets mark hits with \x01 and \x02 so
+-- the caller can escape the text before turning them into tags.
+-- feed_title is the user's title for the feed if they follow it and set
+-- one. Posts the user hid are left out unless include_hidden is set.
+SELECT p.id,
+       p.title,
+       p.url,
+       p.published_at,
+       p.feed_id,
+       ts_rank_cd(s.vector, q.query)::real AS rank,
+       ts_headline(
+           s.config,
+           regexp_replace(p.title || ' ' || p.description || ' ' || p.content, '<[^>]*>', ' ', 'g'),
+           q.query,
+           'StartSel=' || chr(1) || ', StopSel=' || chr(2) || ', MaxFragments=2, MaxWords=30, MinWords=10'
+       )::text AS snippet,
+       ps.read_at,
+       ps.starred_at,
+       COALESCE(ff.title, NULLIF(f.name, ''), f.title, '')::text AS feed_title
+FROM posts p
+JOIN post_search s ON s.post_id = p.id
+JOIN feeds f ON f.id = p.feed_id
+CROSS JOIN (
+    SELECT websearch_to_tsquery('simple', @query::text)
+        || websearch_to_tsquery(search_config_for_language(@language::text), @query::text) AS query
+) q
+LEFT JOIN feed_follows ff ON ff.feed_id = p.feed_id AND ff.user_id = @user_id
+LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = @user_id
+WHERE s.vector @@ q.query
+  AND (@include_hidden::boolean OR ps.hidden_at IS NULL)
+  AND (NOT @followed_only::boolean OR ff.id IS NOT NULL)
+  AND (sqlc.narg('feed_id')::uuid IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+  AND (sqlc.narg('published_after')::timestamp IS NULL OR p.published_at >= sqlc.narg('published_after'))
+  AND (sqlc.narg('published_before')::timestamp IS NULL OR p.published_at < sqlc.narg('published_before'))
+  AND (sqlc.narg('is_read')::boolean IS NULL OR (ps.read_at IS NOT NULL) = sqlc.narg('is_read'))
+ORDER BY rank DESC, p.published_at DESC
+LIMIT @row_limit OFFSET @row_offset;
@@ -0,0 +1,42 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, created_at, user_id, token_hash, csrf_token, rotated_at, expires_at, user_agent)
+VALUES (@id, @now, @user_id, @token_hash, @csrf_token, @now, @expires_at, @user_agent)
+RETURNING *;
+
+-- name: GetSessionByToken :one
+-- Finds an unexpired session by its current token, or by the token it had
+-- before its last rotation if that happened after @grace_after.
+SELECT sqlc.embed(s), sqlc.embed(u)
+FROM sessions s
+JOIN users u ON u.id = s.user_id
+WHERE s.expires_at > @now::timestamp
+  AND u.disabled_at IS NULL
+  AND (s.token_hash = @token_hash
+       OR (s.previous_token_hash = @token_hash AND s.rotated_at > @grace_after::timestamp));
+
+-- name: RotateSession :one
+-- Replaces the session token and extends the session. Only the caller that
+-- still holds the current token wins if two requests rotate at once.
+UPDATE sessions
+SET previous_token_hash = token_hash,
+    token_hash = @new_token_hash,
+    rotated_at = @now,
+    expires_at = @expires_at
+WHERE id = @id AND token_hash = @old_token_hash
+RETURNING *;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE id = $1;
+
+-- name: DeleteOtherSessionsForUser :exec
+DELETE FROM sessions
+WHERE user_id = @user_id AND id <> @keep_id;
+
+-- name: DeleteSessionsForUser :exec
+DELETE FROM sessions
+WHERE user_id = $1;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+WHERE expires_at < $1;
@@ -0,0 +1,3 @@
+-- name: NotifyStreamEvent :exec
+-- Sends a live-update event to every server instance's stream hub.
+SELECT pg_notify('stream_events', @payload::text);
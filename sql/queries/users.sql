@@ -1,9 +1,64 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name, api_key)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO users (id, created_at, updated_at, name, username, password_hash, is_admin)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 
--- name: GetUserByAPIKey :one
+-- name: GetUserByUsername :one
 SELECT *
 FROM users
-WHERE api_key = $1;
+WHERE lower(username) = lower(@username);
+
+-- name: SetUserPassword :one
+UPDATE users
+SET username = @username,
+    password_hash = @password_hash,
+    updated_at = @updated_at
+WHERE id = @id
+RETURNING *;
+
+-- name: GetUser :one
+SELECT *
+FROM users
+WHERE id = $1;
+
+-- name: CountUsers :one
+SELECT COUNT(*)
+FROM users;
+
+-- name: GetUsers :many
+SELECT *
+FROM users
+ORDER BY created_at
+LIMIT @row_limit OFFSET @row_offset;
+
+-- name: SetUserDisabled :one
+-- Pass a time to disable the user, or NULL to enable them again.
+UPDATE users
+SET disabled_at = sqlc.narg('disabled_at'),
+    updated_at = @updated_at
+WHERE id = @id
+RETURNING *;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: UpdateUserName :one
+UPDATE users
+SET name = @name,
+    updated_at = @updated_at
+WHERE id = @id
+RETURNING *;
+
+-- name: LockUserAccounts :exec
+-- Held until the transaction ends. Sign-ups and removing or disabling
+-- users take it, so deciding who is the first user or the last admin
+-- can not race.
+SELECT pg_advisory_xact_lock(7413001);
+
+-- name: CountOtherActiveAdmins :one
+SELECT COUNT(*)
+FROM users
+WHERE is_admin
+  AND disabled_at IS NULL
+  AND id <> $1;
@@ -0,0 +1,148 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, feed_id, folder_id, rule_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT *
+FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetWebhooksForUser :many
+SELECT *
+FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: UpdateWebhook :one
+-- Re-enabling a webhook clears its failure count and disabled reason.
+UPDATE webhooks
+SET url = @url,
+    enabled = @enabled,
+    consecutive_failures = CASE WHEN @enabled::boolean AND NOT enabled THEN 0 ELSE consecutive_failures END,
+    disabled_at = CASE WHEN @enabled::boolean THEN NULL ELSE COALESCE(disabled_at, @updated_at) END,
+    disabled_reason = CASE WHEN @enabled::boolean THEN '' ELSE disabled_reason END,
+    updated_at = @updated_at
+WHERE id = @id AND user_id = @user_id
+RETURNING *;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Queues a delivery of the post to each enabled webhook whose owner
+-- follows its feed and whose filter, if any, covers it: the feed itself,
+-- the folder the follow is in, or a rule that matched the post.
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, post_id, event, payload, next_attempt_at)
+SELECT gen_random_uuid(), @now, @now, w.id, @post_id, @event, @payload, @now
+FROM webhooks w
+JOIN feed_follows ff ON ff.user_id = w.user_id AND ff.feed_id = @feed_id
+WHERE w.enabled
+  AND (
+      (w.feed_id IS NULL AND w.folder_id IS NULL AND w.rule_id IS NULL)
+      OR w.feed_id = @feed_id
+      OR w.folder_id = ff.folder_id
+      OR w.rule_id = ANY(@matched_rule_ids::uuid[])
+  )
+ON CONFLICT (webhook_id, post_id, event) DO NOTHING;
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Takes due deliveries to enabled webhooks and pushes their next attempt
+-- to @lease_until, so a worker that dies mid-delivery doesn't lose them and
+-- concurrent workers don't send them twice.
+UPDATE webhook_deliveries d
+SET next_attempt_at = @lease_until,
+    updated_at = @now
+FROM webhooks w
+WHERE w.id = d.webhook_id
+  AND d.id IN (
+      SELECT dd.id
+      FROM webhook_deliveries dd
+      JOIN webhooks ww ON ww.id = dd.webhook_id
+      WHERE dd.status = 'pending'
+        AND dd.next_attempt_at <= @now
+        AND ww.enabled
+      ORDER BY dd.next_attempt_at
+      LIMIT @row_limit
+      FOR UPDATE OF dd SKIP LOCKED
+  )
+RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    attempts = attempts + 1,
+    last_attempt_at = @now::timestamp,
+    response_status = @response_status::int,
+    last_error = '',
+    delivered_at = @now::timestamp,
+    updated_at = @now
+WHERE id = @id;
+
+-- name: MarkWebhookDeliveryFailed :exec
+-- Records a failed attempt. The delivery stays pending until
+-- @next_attempt_at unless @give_up is set.
+UPDATE webhook_deliveries
+SET status = CASE WHEN @give_up::boolean THEN 'failed' ELSE 'pending' END,
+    attempts = attempts + 1,
+    last_attempt_at = @now::timestamp,
+    response_status = sqlc.narg(response_status),
+    last_error = @last_error,
+    next_attempt_at = @next_attempt_at,
+    updated_at = @now
+WHERE id = @id;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, response_status, error, duration_ms)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ResetWebhookFailures :exec
+UPDATE webhooks
+SET consecutive_failures = 0
+WHERE id = $1 AND consecutive_failures <> 0;
+
+-- name: IncrementWebhookFailures :one
+UPDATE webhooks
+SET consecutive_failures = consecutive_failures + 1
+WHERE id = $1
+RETURNING consecutive_failures;
+
+-- name: DisableWebhook :exec
+UPDATE webhooks
+SET enabled = FALSE,
+    disabled_at = @now::timestamp,
+    disabled_reason = @reason,
+    updated_at = @now
+WHERE id = @id AND enabled;
+
+-- name: GetWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE webhook_id = @webhook_id
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status))
+ORDER BY created_at DESC
+LIMIT @row_limit OFFSET @row_offset;
+
+-- name: GetWebhookDelivery :one
+SELECT *
+FROM webhook_deliveries
+WHERE id = $1 AND webhook_id = $2;
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT *
+FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at;
+
+-- name: RetryWebhookDelivery :execrows
+-- Queues a finished delivery to be sent again straight away.
+UPDATE webhook_deliveries
+SET status = 'pending',
+    next_attempt_at = @now,
+    updated_at = @now
+WHERE id = @id AND status <> 'pending';
+
+-- name: DeleteOldWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status <> 'pending' AND updated_at < $1;
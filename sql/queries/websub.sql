@@ -0,0 +1,77 @@
+-- name: UpsertWebSubSubscription :one
+-- Starts a new subscription for the feed, replacing any earlier one. The id
+-- of an existing row is kept so its callback URL stays the same.
+INSERT INTO websub_subscriptions (id, created_at, updated_at, feed_id, hub_url, topic_url, secret, state, requested_at)
+VALUES (@id, @now, @now, @feed_id, @hub_url, @topic_url, @secret, 'pending', @now)
+ON CONFLICT (feed_id) DO UPDATE
+SET hub_url = EXCLUDED.hub_url,
+    topic_url = EXCLUDED.topic_url,
+    secret = EXCLUDED.secret,
+    state = 'pending',
+    requested_at = EXCLUDED.requested_at,
+    lease_expires_at = NULL,
+    last_error = '',
+    updated_at = EXCLUDED.updated_at
+RETURNING *;
+
+-- name: GetWebSubSubscription :one
+SELECT *
+FROM websub_subscriptions
+WHERE id = $1;
+
+-- name: GetWebSubSubscriptionForFeed :one
+SELECT *
+FROM websub_subscriptions
+WHERE feed_id = $1;
+
+-- name: DeleteWebSubSubscriptionForFeed :execrows
+DELETE FROM websub_subscriptions
+WHERE feed_id = $1;
+
+-- name: MarkWebSubRequested :exec
+UPDATE websub_subscriptions
+SET requested_at = @now, updated_at = @now
+WHERE id = @id;
+
+-- name: ActivateWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = 'active',
+    lease_expires_at = @lease_expires_at::timestamp,
+    last_error = '',
+    updated_at = @now
+WHERE id = @id;
+
+-- name: DenyWebSubSubscription :exec
+UPDATE websub_subscriptions
+SET state = 'denied',
+    lease_expires_at = NULL,
+    last_error = @reason,
+    updated_at = @now
+WHERE id = @id;
+
+-- name: FailWebSubSubscription :exec
+-- A failed renewal leaves an active subscription alone until its lease
+-- runs out; anything else is marked failed.
+UPDATE websub_subscriptions
+SET state = CASE WHEN state = 'active' THEN state ELSE 'failed' END,
+    last_error = @last_error,
+    updated_at = @now
+WHERE id = @id;
+
+-- name: MarkWebSubPush :exec
+UPDATE websub_subscriptions
+SET last_push_at = @now::timestamp, updated_at = @now
+WHERE id = @id;
+
+-- name: GetWebSubSubscriptionsToRenew :many
+-- Active subscriptions whose lease ends before @renew_before, and ones that
+-- never became active, as long as nothing was requested since
+-- @retry_before. Subscriptions for feeds nobody follows are left to lapse.
+SELECT s.*
+FROM websub_subscriptions s
+JOIN feeds f ON f.id = s.feed_id
+WHERE s.requested_at < @retry_before::timestamp
+  AND (s.state <> 'active' OR s.lease_expires_at < @renew_before::timestamp)
+  AND f.deactivated_at IS NULL
+ORDER BY s.requested_at
+LIMIT @row_limit::int;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN title TEXT,
+ADD COLUMN site_url TEXT,
+ADD COLUMN description TEXT,
+ADD COLUMN image_url TEXT,
+ADD COLUMN language TEXT,
+ADD COLUMN generator TEXT,
+ADD COLUMN last_build_date TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN title,
+DROP COLUMN site_url,
+DROP COLUMN description,
+DROP COLUMN image_url,
+DROP COLUMN language,
+DROP COLUMN generator,
+DROP COLUMN last_build_date;
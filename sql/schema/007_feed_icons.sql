@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE feed_icons (
+    feed_id UUID PRIMARY KEY REFERENCES feeds(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    url TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    data BYTEA NOT NULL,
+    hash TEXT NOT NULL
+);
+
+ALTER TABLE feeds
+ADD COLUMN icon_checked_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN icon_checked_at;
+
+DROP TABLE feed_icons;
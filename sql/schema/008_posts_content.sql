@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN content TEXT NOT NULL DEFAULT '',
+ADD COLUMN plain_text TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN content,
+DROP COLUMN plain_text;
@@ -0,0 +1,91 @@
+-- +goose Up
+-- Maps a feed's language tag (e.g. "en-us", "de") to a text search config.
+-- +goose StatementBegin
+CREATE FUNCTION search_config_for_language(lang TEXT) RETURNS REGCONFIG AS $$
+    SELECT (CASE lower(split_part(split_part(coalesce(lang, ''), '-', 1), '_', 1))
+        WHEN 'ar' THEN 'arabic'
+        WHEN 'da' THEN 'danish'
+        WHEN 'de' THEN 'german'
+        WHEN 'el' THEN 'greek'
+        WHEN 'en' THEN 'english'
+        WHEN 'es' THEN 'spanish'
+        WHEN 'fi' THEN 'finnish'
+        WHEN 'fr' THEN 'french'
+        WHEN 'hu' THEN 'hungarian'
+        WHEN 'id' THEN 'indonesian'
+        WHEN 'it' THEN 'italian'
+        WHEN 'nl' THEN 'dutch'
+        WHEN 'no' THEN 'norwegian'
+        WHEN 'nb' THEN 'norwegian'
+        WHEN 'nn' THEN 'norwegian'
+        WHEN 'pt' THEN 'portuguese'
+        WHEN 'ro' THEN 'romanian'
+        WHEN 'ru' THEN 'russian'
+        WHEN 'sv' THEN 'swedish'
+        WHEN 'tr' THEN 'turkish'
+        ELSE 'simple'
+    END)::regconfig;
+$$ LANGUAGE SQL IMMUTABLE;
+-- +goose StatementEnd
+
+-- post_search holds the full-text index for posts. Each vector combines a
+-- language-aware (stemmed) rendition with a 'simple' one, so queries match
+-- both exact words and stemmed forms whatever language they are run in.
+CREATE TABLE post_search (
+    post_id UUID PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    config REGCONFIG NOT NULL,
+    vector TSVECTOR NOT NULL
+);
+
+CREATE INDEX idx_post_search_vector ON post_search USING GIN (vector);
+
+-- +goose StatementBegin
+CREATE FUNCTION post_search_document(cfg REGCONFIG, title TEXT, description TEXT, content TEXT) RETURNS TSVECTOR AS $$
+    SELECT setweight(to_tsvector(cfg, coalesce(title, '')), 'A')
+        || setweight(to_tsvector(cfg, regexp_replace(coalesce(description, ''), '<[^>]*>', ' ', 'g')), 'B')
+        || setweight(to_tsvector(cfg, regexp_replace(coalesce(content, ''), '<[^>]*>', ' ', 'g')), 'C');
+$$ LANGUAGE SQL IMMUTABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION post_search_refresh() RETURNS TRIGGER AS $$
+DECLARE
+    cfg REGCONFIG;
+BEGIN
+    cfg := search_config_for_language((SELECT language FROM feeds WHERE id = NEW.feed_id));
+
+    INSERT INTO post_search (post_id, config, vector)
+    VALUES (
+        NEW.id,
+        cfg,
+        post_search_document(cfg, NEW.title, NEW.description, NEW.content)
+            || post_search_document('simple', NEW.title, NEW.description, NEW.content)
+    )
+    ON CONFLICT (post_id) DO UPDATE
+    SET config = EXCLUDED.config,
+        vector = EXCLUDED.vector;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER posts_search_refresh
+AFTER INSERT OR UPDATE OF title, description, content ON posts
+FOR EACH ROW EXECUTE FUNCTION post_search_refresh();
+
+-- Index posts stored before search existed.
+INSERT INTO post_search (post_id, config, vector)
+SELECT p.id,
+       search_config_for_language(f.language),
+       post_search_document(search_config_for_language(f.language), p.title, p.description, p.content)
+           || post_search_document('simple', p.title, p.description, p.content)
+FROM posts p
+JOIN feeds f ON f.id = p.feed_id;
+
+-- +goose Down
+DROP TRIGGER posts_search_refresh ON posts;
+DROP FUNCTION post_search_refresh();
+DROP TABLE post_search;
+DROP FUNCTION post_search_document(REGCONFIG, TEXT, TEXT, TEXT);
+DROP FUNCTION search_config_for_language(TEXT);
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE saved_searches (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL,
+    language TEXT NOT NULL DEFAULT '',
+    notify BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX idx_saved_searches_user ON saved_searches (user_id);
+
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    saved_search_id UUID REFERENCES saved_searches(id) ON DELETE CASCADE,
+    read_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_notifications_user_post_search
+ON notifications (user_id, post_id, saved_search_id);
+
+-- +goose Down
+DROP TABLE notifications;
+DROP TABLE saved_searches;
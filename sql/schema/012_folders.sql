@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE folders (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_folders_user_name
+ON folders (user_id, name);
+
+ALTER TABLE feed_follows
+ADD COLUMN folder_id UUID REFERENCES folders(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE feed_follows
+DROP COLUMN folder_id;
+
+DROP TABLE folders;
@@ -0,0 +1,40 @@
+-- +goose Up
+-- A rule with neither folder_id nor feed_follow_id applies to all of the
+-- user's feeds.
+CREATE TABLE filter_rules (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    folder_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    feed_follow_id UUID REFERENCES feed_follows(id) ON DELETE CASCADE,
+    field TEXT NOT NULL,
+    match_type TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    action TEXT NOT NULL,
+    tag TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    CHECK (folder_id IS NULL OR feed_follow_id IS NULL)
+);
+
+CREATE INDEX idx_filter_rules_user ON filter_rules (user_id);
+
+ALTER TABLE post_states
+ADD COLUMN hidden_at TIMESTAMP;
+
+CREATE TABLE post_tags (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id, tag)
+);
+
+-- +goose Down
+DROP TABLE post_tags;
+
+ALTER TABLE post_states
+DROP COLUMN hidden_at;
+
+DROP TABLE filter_rules;
@@ -0,0 +1,65 @@
+-- +goose Up
+-- A webhook with no feed_id, folder_id or rule_id receives every new post
+-- from the user's followed feeds.
+CREATE TABLE webhooks (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    feed_id UUID REFERENCES feeds(id) ON DELETE CASCADE,
+    folder_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    rule_id UUID REFERENCES filter_rules(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    disabled_at TIMESTAMP,
+    disabled_reason TEXT NOT NULL DEFAULT '',
+    CHECK (num_nonnulls(feed_id, folder_id, rule_id) <= 1)
+);
+
+CREATE INDEX idx_webhooks_user ON webhooks (user_id);
+
+-- webhook_deliveries is the outbox: one row per post per webhook, written
+-- by the feed worker and drained by the webhook worker.
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_attempt_at TIMESTAMP,
+    response_status INT,
+    last_error TEXT NOT NULL DEFAULT '',
+    delivered_at TIMESTAMP,
+    UNIQUE (webhook_id, post_id, event)
+);
+
+CREATE INDEX idx_webhook_deliveries_due
+ON webhook_deliveries (next_attempt_at)
+WHERE status = 'pending';
+
+CREATE INDEX idx_webhook_deliveries_webhook
+ON webhook_deliveries (webhook_id, created_at DESC);
+
+CREATE TABLE webhook_delivery_attempts (
+    id UUID PRIMARY KEY,
+    delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    attempted_at TIMESTAMP NOT NULL,
+    response_status INT,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms INT NOT NULL
+);
+
+CREATE INDEX idx_webhook_delivery_attempts_delivery
+ON webhook_delivery_attempts (delivery_id, attempted_at);
+
+-- +goose Down
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
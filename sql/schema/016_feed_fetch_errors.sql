@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN fetch_error TEXT,
+ADD COLUMN fetch_error_at TIMESTAMP,
+ADD COLUMN fetch_failures INT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN fetch_failures,
+DROP COLUMN fetch_error_at,
+DROP COLUMN fetch_error;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Output feeds republish a user's posts at /u/{token}/feed.{atom,rss,json}.
+-- The token is the only credential, so each feed has its own and can be
+-- revoked on its own.
+CREATE TABLE output_feeds (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    title TEXT NOT NULL,
+    source TEXT NOT NULL,
+    folder_id UUID REFERENCES folders(id) ON DELETE CASCADE,
+    saved_search_id UUID REFERENCES saved_searches(id) ON DELETE CASCADE,
+    CHECK (folder_id IS NULL OR saved_search_id IS NULL)
+);
+
+CREATE INDEX idx_output_feeds_user ON output_feeds (user_id);
+
+-- +goose Down
+DROP TABLE output_feeds;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- WebSub subscriptions for feeds that advertise a hub. While a lease is
+-- active the hub pushes new content to /websub/{id} and the feed is only
+-- polled as a fallback; once it lapses, normal polling resumes.
+CREATE TABLE websub_subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    feed_id UUID NOT NULL UNIQUE REFERENCES feeds(id) ON DELETE CASCADE,
+    hub_url TEXT NOT NULL,
+    topic_url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    -- pending: requested, waiting for the hub to verify intent
+    -- active:  verified; lease_expires_at says until when
+    -- denied:  the hub refused the subscription
+    -- failed:  the subscription request itself failed
+    state TEXT NOT NULL DEFAULT 'pending',
+    requested_at TIMESTAMP NOT NULL,
+    lease_expires_at TIMESTAMP,
+    last_push_at TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_websub_subscriptions_requested ON websub_subscriptions (requested_at);
+
+-- +goose Down
+DROP TABLE websub_subscriptions;
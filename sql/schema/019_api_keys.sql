@@ -0,0 +1,33 @@
+-- +goose Up
+-- API keys are stored hashed. prefix is the first characters of the key,
+-- kept in the clear so a key can be recognised in listings and found
+-- without scanning every hash.
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL,
+    last_used_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_prefix ON api_keys (prefix);
+CREATE INDEX idx_api_keys_user ON api_keys (user_id);
+
+-- Existing keys keep working: they become each user's "default" key.
+INSERT INTO api_keys (id, created_at, updated_at, user_id, name, prefix, key_hash)
+SELECT gen_random_uuid(), created_at, NOW(), id, 'default',
+       left(api_key, 12), encode(sha256(convert_to(api_key, 'UTF8')), 'hex')
+FROM users;
+
+ALTER TABLE users DROP COLUMN api_key;
+
+-- +goose Down
+-- The plaintext keys are gone, so users get fresh ones.
+ALTER TABLE users ADD COLUMN api_key TEXT UNIQUE;
+UPDATE users SET api_key = gen_random_uuid()::text;
+ALTER TABLE users ALTER COLUMN api_key SET NOT NULL;
+DROP TABLE api_keys;
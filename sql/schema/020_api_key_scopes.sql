@@ -0,0 +1,11 @@
+-- +goose Up
+-- Existing keys keep full access.
+ALTER TABLE api_keys
+ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{admin}';
+
+ALTER TABLE api_keys
+ALTER COLUMN scopes DROP DEFAULT;
+
+-- +goose Down
+ALTER TABLE api_keys
+DROP COLUMN scopes;
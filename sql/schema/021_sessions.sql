@@ -0,0 +1,32 @@
+-- +goose Up
+-- Users who want to sign in from a browser pick a username and password.
+ALTER TABLE users
+ADD COLUMN username TEXT,
+ADD COLUMN password_hash TEXT;
+
+CREATE UNIQUE INDEX idx_users_username ON users (lower(username));
+
+-- Browser sessions. Only a hash of the cookie's token is stored. The token
+-- is replaced periodically; the previous one keeps working for a short
+-- grace period so requests already in flight don't fail.
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    previous_token_hash TEXT,
+    csrf_token TEXT NOT NULL,
+    rotated_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_sessions_user ON sessions (user_id);
+CREATE INDEX idx_sessions_previous_token ON sessions (previous_token_hash);
+
+-- +goose Down
+DROP TABLE sessions;
+DROP INDEX idx_users_username;
+ALTER TABLE users
+DROP COLUMN password_hash,
+DROP COLUMN username;
@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN disabled_at TIMESTAMP;
+
+-- The first user to sign up runs the instance.
+UPDATE users SET is_admin = true
+WHERE id = (SELECT id FROM users ORDER BY created_at LIMIT 1);
+
+-- Invite codes let people register when REGISTRATION_MODE is invite. A
+-- code works until it has been used max_uses times or expires.
+CREATE TABLE invites (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    code TEXT NOT NULL UNIQUE,
+    note TEXT NOT NULL DEFAULT '',
+    max_uses INT NOT NULL DEFAULT 1 CHECK (max_uses > 0),
+    uses INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE invites;
+ALTER TABLE users
+DROP COLUMN disabled_at,
+DROP COLUMN is_admin;
@@ -0,0 +1,65 @@
+-- +goose Up
+-- Feeds are shared by everyone who follows them. added_by only records who
+-- added a feed first; deleting that user no longer deletes the feed.
+ALTER TABLE feeds DROP CONSTRAINT feeds_user_id_fkey;
+ALTER TABLE feeds RENAME COLUMN user_id TO added_by;
+ALTER TABLE feeds ALTER COLUMN added_by DROP NOT NULL;
+ALTER TABLE feeds
+ADD CONSTRAINT feeds_added_by_fkey FOREIGN KEY (added_by) REFERENCES users(id) ON DELETE SET NULL;
+
+-- follower_count is kept up to date by a trigger on feed_follows, so
+-- follows deleted along with their user are counted too. A feed nobody
+-- follows is deactivated: the workers skip it, and it is deleted once it
+-- has been inactive for a while.
+ALTER TABLE feeds
+ADD COLUMN follower_count INT NOT NULL DEFAULT 0,
+ADD COLUMN deactivated_at TIMESTAMP;
+
+UPDATE feeds f
+SET follower_count = (SELECT COUNT(*) FROM feed_follows ff WHERE ff.feed_id = f.id);
+
+UPDATE feeds
+SET deactivated_at = NOW()
+WHERE follower_count = 0;
+
+CREATE INDEX idx_feeds_deactivated_at ON feeds (deactivated_at)
+WHERE deactivated_at IS NOT NULL;
+
+-- +goose StatementBegin
+CREATE FUNCTION feed_follows_count() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        UPDATE feeds
+        SET follower_count = follower_count + 1,
+            deactivated_at = NULL
+        WHERE id = NEW.feed_id;
+    ELSE
+        UPDATE feeds
+        SET follower_count = follower_count - 1,
+            deactivated_at = CASE WHEN follower_count = 1 THEN NOW() ELSE deactivated_at END
+        WHERE id = OLD.feed_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER feed_follows_count
+AFTER INSERT OR DELETE ON feed_follows
+FOR EACH ROW EXECUTE FUNCTION feed_follows_count();
+
+-- +goose Down
+DROP TRIGGER feed_follows_count ON feed_follows;
+DROP FUNCTION feed_follows_count();
+DROP INDEX idx_feeds_deactivated_at;
+ALTER TABLE feeds
+DROP COLUMN deactivated_at,
+DROP COLUMN follower_count;
+
+-- Feeds whose first user is gone have nobody to belong to.
+DELETE FROM feeds WHERE added_by IS NULL;
+ALTER TABLE feeds DROP CONSTRAINT feeds_added_by_fkey;
+ALTER TABLE feeds ALTER COLUMN added_by SET NOT NULL;
+ALTER TABLE feeds RENAME COLUMN added_by TO user_id;
+ALTER TABLE feeds
+ADD CONSTRAINT feeds_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
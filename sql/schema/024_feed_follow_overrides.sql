@@ -0,0 +1,20 @@
+-- +goose Up
+-- Per-user settings for a followed feed. title and color override how the
+-- feed is shown to this user; priority orders their feed list, highest
+-- first. Feeds with hide_from_timeline are left out of the main timeline
+-- but can still be read on their own, and notify=false stops saved
+-- searches notifying about the feed's posts.
+ALTER TABLE feed_follows
+ADD COLUMN title TEXT,
+ADD COLUMN color TEXT,
+ADD COLUMN priority INT NOT NULL DEFAULT 0,
+ADD COLUMN hide_from_timeline BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN notify BOOLEAN NOT NULL DEFAULT true;
+
+-- +goose Down
+ALTER TABLE feed_follows
+DROP COLUMN notify,
+DROP COLUMN hide_from_timeline,
+DROP COLUMN priority,
+DROP COLUMN color,
+DROP COLUMN title;
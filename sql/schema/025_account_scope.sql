@@ -0,0 +1,9 @@
+-- +goose Up
+-- The full-access key scope was called "admin", which was easy to mistake
+-- for the admin user role.
+UPDATE api_keys
+SET scopes = array_replace(scopes, 'admin', 'account');
+
+-- +goose Down
+UPDATE api_keys
+SET scopes = array_replace(scopes, 'account', 'admin');